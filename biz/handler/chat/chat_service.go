@@ -4,8 +4,18 @@ package chat
 
 import (
 	"context"
+	"errors"
 	"log"
+	"time"
+	"west2/biz/model/base"
+	chat "west2/biz/model/chat"
+	"west2/database"
+	"west2/pkg/config"
+	"west2/pkg/hub"
 	"west2/pkg/middleware"
+	"west2/pkg/model"
+	"west2/pkg/moderation"
+	"west2/pkg/repository"
 	"west2/pkg/service"
 
 	"github.com/cloudwego/hertz/pkg/app"
@@ -17,27 +27,34 @@ import (
 // @router /chat [GET]
 func Chat(ctx context.Context, c *app.RequestContext) {
 	var upgrader = websocket.HertzUpgrader{}
-	cs := service.NewChatService()
+	cs := service.NewChatService(moderation.GetEngine(), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewMessageRepository(database.GetMysqlDB()), repository.NewMessageCacheRepository(), repository.NewGroupRepository(database.GetMysqlDB()), repository.NewVideoRepository(database.GetMysqlDB()), time.Second*config.GetConfig().Chat.RecallWindow)
 	err := upgrader.Upgrade(c, func(conn *websocket.Conn) {
 		uid := middleware.GetUserFromContext(ctx, c)
 		if uid == "" {
 			c.AbortWithStatus(consts.StatusBadRequest)
 			return
 		}
+		client := hub.GetHub().Register(uid, conn)
+		defer hub.GetHub().Unregister(uid, client)
+		conn.SetReadLimit(hub.MaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(hub.PongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(hub.PongWait))
+		})
 		for {
-			mt, message, err := conn.ReadMessage()
+			_, message, err := conn.ReadMessage()
 			if err != nil {
 				log.Println("read:", err)
 				break
 			}
+			conn.SetReadDeadline(time.Now().Add(hub.PongWait))
 			message, err = cs.Chat(uid, message)
 			if err != nil {
 				c.AbortWithStatus(consts.StatusBadRequest)
 				return
 			}
-			err = conn.WriteMessage(mt, message)
-			if err != nil {
-				c.AbortWithStatus(consts.StatusBadRequest)
+			// 输入状态等消息没有回复
+			if message != nil && !client.Send(message) {
 				return
 			}
 		}
@@ -48,3 +65,86 @@ func Chat(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 }
+
+// ConversationList .
+// @router /chat/conversation/list [GET]
+func ConversationList(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req chat.ConversationListRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	cs := service.NewChatService(moderation.GetEngine(), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewMessageRepository(database.GetMysqlDB()), repository.NewMessageCacheRepository(), repository.NewGroupRepository(database.GetMysqlDB()), repository.NewVideoRepository(database.GetMysqlDB()), time.Second*config.GetConfig().Chat.RecallWindow)
+	convs, total, err := cs.GetConversations(middleware.GetUserFromContext(ctx, c), req.PageNum, req.PageSize)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &chat.ConversationListResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &chat.ConversationListResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &chat.ConversationList{
+			Items: model.ConversationsToResConversations(convs),
+			Total: total,
+		},
+	})
+}
+
+// UploadImage .
+// @router /chat/image/upload [PUT]
+func UploadImage(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req chat.UploadImageRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.JSON(consts.StatusBadRequest, &chat.UploadImageResponse{
+			Base: &base.Base{
+				Code: consts.StatusBadRequest,
+				Msg:  err.Error(),
+			},
+		})
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+
+	cs := service.NewChatService(moderation.GetEngine(), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewMessageRepository(database.GetMysqlDB()), repository.NewMessageCacheRepository(), repository.NewGroupRepository(database.GetMysqlDB()), repository.NewVideoRepository(database.GetMysqlDB()), time.Second*config.GetConfig().Chat.RecallWindow)
+	url, err := cs.UploadImage(uid, req.Data)
+	if errors.Is(err, service.ErrInvalidImage) {
+		c.JSON(consts.StatusBadRequest, &chat.UploadImageResponse{
+			Base: &base.Base{
+				Code: consts.StatusBadRequest,
+				Msg:  err.Error(),
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &chat.UploadImageResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &chat.UploadImageResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: url,
+	})
+}
@@ -4,12 +4,15 @@ package comment
 
 import (
 	"context"
+	"errors"
 
 	"west2/biz/model/base"
 	comment "west2/biz/model/comment"
 	"west2/database"
+	"west2/pkg/config"
 	"west2/pkg/middleware"
 	"west2/pkg/model"
+	"west2/pkg/moderation"
 	"west2/pkg/repository"
 	"west2/pkg/service"
 	"west2/util"
@@ -29,19 +32,31 @@ func CommentPublish(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	cs := service.NewCommentService(repository.NewCommentRepository(database.GetMysqlDB()))
-	err = cs.Publish(&model.Comment{
+	cs := newCommentService()
+	cm := &model.Comment{
 		Id:       util.GetID(),
 		VideoId:  req.VideoId,
 		Uid:      middleware.GetUserFromContext(ctx, c),
 		ParentId: req.CommentId,
 		Content:  req.Content,
-	})
+	}
+	err = cs.Publish(cm)
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &comment.CommentPublishResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &comment.CommentPublishResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "internal server error",
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	if cm.Status == model.ContentPending {
+		c.JSON(consts.StatusAccepted, &comment.CommentPublishResponse{
+			Base: &base.Base{
+				Code: consts.StatusAccepted,
+				Msg:  "pending review",
 			},
 		})
 		return
@@ -66,13 +81,22 @@ func CommentList(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	cs := service.NewCommentService(repository.NewCommentRepository(database.GetMysqlDB()))
-	comments, err := cs.GetCommentList(req.VideoId, req.CommentId, req.PageNum, req.PageSize)
+	cs := newCommentService()
+	comments, nextCursor, err := cs.GetCommentList(&service.CommentListParams{
+		Uid:       middleware.GetUserFromContext(ctx, c),
+		VideoId:   req.VideoId,
+		CommentId: req.CommentId,
+		SortBy:    req.SortBy,
+		Cursor:    req.Cursor,
+		PageNum:   req.PageNum,
+		PageSize:  req.PageSize,
+	})
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &comment.CommentListResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &comment.CommentListResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "internal server error",
+				Code: int64(code),
+				Msg:  msg,
 			},
 		})
 		return
@@ -84,7 +108,115 @@ func CommentList(ctx context.Context, c *app.RequestContext) {
 			Msg:  "success",
 		},
 		Data: &comment.CommentList{
-			Items: model.CommentsToResComments(comments),
+			Items:      model.CommentsToResComments(comments),
+			NextCursor: nextCursor,
+		},
+	})
+}
+
+// CommentTree .
+// @router /comment/tree [GET]
+func CommentTree(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req comment.CommentTreeRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	cs := newCommentService()
+	comments, nextCursor, err := cs.GetCommentTree(&service.CommentListParams{
+		Uid:      middleware.GetUserFromContext(ctx, c),
+		VideoId:  req.VideoId,
+		SortBy:   req.SortBy,
+		Cursor:   req.Cursor,
+		PageNum:  req.PageNum,
+		PageSize: req.PageSize,
+	}, req.Replies)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &comment.CommentTreeResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &comment.CommentTreeResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &comment.CommentList{
+			Items:      model.CommentsToResComments(comments),
+			NextCursor: nextCursor,
+		},
+	})
+}
+
+// Pin .
+// @router /comment/pin [POST]
+func Pin(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req comment.PinRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	cs := newCommentService()
+	err = cs.Pin(req.CommentId, middleware.GetUserFromContext(ctx, c))
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &comment.PinResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &comment.PinResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// Unpin .
+// @router /comment/pin [DELETE]
+func Unpin(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req comment.UnpinRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	cs := newCommentService()
+	err = cs.Unpin(req.VideoId, middleware.GetUserFromContext(ctx, c))
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &comment.UnpinResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &comment.UnpinResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
 		},
 	})
 }
@@ -100,18 +232,20 @@ func Delete(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	cs := service.NewCommentService(repository.NewCommentRepository(database.GetMysqlDB()))
+	cs := newCommentService()
+	uid := middleware.GetUserFromContext(ctx, c)
 	if req.CommentId != "" {
-		err = cs.DeleteById(req.CommentId)
+		err = cs.DeleteById(req.CommentId, uid)
 	} else {
-		err = cs.DeleteByVideoId(req.VideoId)
+		err = cs.DeleteByVideoId(req.VideoId, uid)
 	}
 
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &comment.DeleteResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &comment.DeleteResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "internal server error",
+				Code: int64(code),
+				Msg:  msg,
 			},
 		})
 		return
@@ -124,3 +258,36 @@ func Delete(ctx context.Context, c *app.RequestContext) {
 		},
 	})
 }
+
+func newCommentService() service.CommentService {
+	db := database.GetMysqlDB()
+	cfg := config.GetConfig()
+	return service.NewCommentService(
+		repository.NewCommentRepository(db),
+		repository.NewVideoRepository(db),
+		repository.NewLikeReposirty(db),
+		repository.NewUserRepository(db),
+		repository.NewFollowRepostory(db),
+		repository.NewNotificationRepository(db),
+		repository.NewReviewRepository(db),
+		moderation.GetEngine(),
+		cfg.Comment.MaxDepth,
+		cfg.Comment.TreeReplies,
+	)
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrVideoNotFound), errors.Is(err, service.ErrCommentNotFound):
+		return consts.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrPermissionDenied), errors.Is(err, service.ErrBlocked),
+		errors.Is(err, service.ErrPrivateAccount):
+		return consts.StatusForbidden, err.Error()
+	case errors.Is(err, service.ErrParentMismatch), errors.Is(err, service.ErrReplyTooDeep),
+		errors.Is(err, service.ErrPinReply), errors.Is(err, service.ErrInvalidSort),
+		errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrContentRejected):
+		return consts.StatusBadRequest, err.Error()
+	default:
+		return consts.StatusInternalServerError, "internal server error"
+	}
+}
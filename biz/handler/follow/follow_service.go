@@ -4,6 +4,7 @@ package follow
 
 import (
 	"context"
+	"errors"
 
 	"west2/biz/model/base"
 	follow "west2/biz/model/follow"
@@ -30,17 +31,18 @@ func FollowAction(ctx context.Context, c *app.RequestContext) {
 
 	uid := middleware.GetUserFromContext(ctx, c)
 
-	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()))
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
 	err = fr.FollowAction(&model.Follow{
 		FollowingId: req.ToUserId,
 		FollowerId:  uid,
 		Status:      req.ActionType,
 	})
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &follow.FollowActionResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &follow.FollowActionResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "internal server error",
+				Code: int64(code),
+				Msg:  msg,
 			},
 		})
 		return
@@ -64,14 +66,15 @@ func FollowerList(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()))
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
 
-	users, total, err := fr.GetFollowingList(req.UserId, req.PageNum, req.PageSize)
+	users, total, err := fr.GetFollowingList(middleware.GetUserFromContext(ctx, c), req.UserId, req.PageNum, req.PageSize)
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &follow.FollowerListResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &follow.FollowerListResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "internal server error",
+				Code: int64(code),
+				Msg:  msg,
 			},
 		})
 		return
@@ -100,14 +103,15 @@ func FollowedList(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()))
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
 
-	users, total, err := fr.GetFollowerList(req.UserId, req.PageNum, req.PageSize)
+	users, total, err := fr.GetFollowerList(middleware.GetUserFromContext(ctx, c), req.UserId, req.PageNum, req.PageSize)
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &follow.FollowedListResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &follow.FollowedListResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "internal server error",
+				Code: int64(code),
+				Msg:  msg,
 			},
 		})
 		return
@@ -137,9 +141,9 @@ func FriendList(ctx context.Context, c *app.RequestContext) {
 	}
 
 	uid := middleware.GetUserFromContext(ctx, c)
-	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()))
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
 
-	users, total, err := fr.GetFollowerList(uid, req.PageNum, req.PageSize)
+	users, total, err := fr.GetFriendList(uid, req.PageNum, req.PageSize)
 	if err != nil {
 		c.JSON(consts.StatusInternalServerError, &follow.FriendListResponse{
 			Base: &base.Base{
@@ -161,3 +165,264 @@ func FriendList(ctx context.Context, c *app.RequestContext) {
 		},
 	})
 }
+
+// BlockAction .
+// @router /relation/block [POST]
+func BlockAction(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req follow.BlockActionRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
+	err = fr.BlockAction(uid, req.ToUserId, req.ActionType)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &follow.BlockActionResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &follow.BlockActionResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// MuteAction .
+// @router /relation/mute [POST]
+func MuteAction(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req follow.MuteActionRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
+	err = fr.MuteAction(uid, req.ToUserId, req.ActionType)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &follow.MuteActionResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &follow.MuteActionResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// BlockList .
+// @router /block/list [GET]
+func BlockList(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req follow.BlockListRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
+
+	users, total, err := fr.GetBlockList(uid, req.PageNum, req.PageSize)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &follow.BlockListResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &follow.BlockListResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &follow.UserList{
+			Items: model.UsersToFollowUsers(users),
+			Total: total,
+		},
+	})
+}
+
+// MuteList .
+// @router /mute/list [GET]
+func MuteList(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req follow.MuteListRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
+
+	users, total, err := fr.GetMuteList(uid, req.PageNum, req.PageSize)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &follow.MuteListResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &follow.MuteListResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &follow.UserList{
+			Items: model.UsersToFollowUsers(users),
+			Total: total,
+		},
+	})
+}
+
+// FollowRequestList .
+// @router /follow/request/list [GET]
+func FollowRequestList(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req follow.FollowRequestListRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
+
+	users, total, err := fr.GetFollowRequestList(uid, req.PageNum, req.PageSize)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &follow.FollowRequestListResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &follow.FollowRequestListResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &follow.UserList{
+			Items: model.UsersToFollowUsers(users),
+			Total: total,
+		},
+	})
+}
+
+// FollowRequestAction .
+// @router /follow/request/action [POST]
+func FollowRequestAction(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req follow.FollowRequestActionRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
+	err = fr.HandleFollowRequest(uid, req.FromUserId, req.ActionType)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &follow.FollowRequestActionResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &follow.FollowRequestActionResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// Suggest .
+// @router /relation/suggest [GET]
+func Suggest(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req follow.SuggestRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	fr := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
+
+	users, err := fr.SuggestUsers(uid, req.Limit)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &follow.SuggestResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &follow.SuggestResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &follow.UserList{
+			Items: model.UsersToFollowUsers(users),
+			Total: int64(len(users)),
+		},
+	})
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrBlocked), errors.Is(err, service.ErrPermissionDenied),
+		errors.Is(err, service.ErrPrivateAccount):
+		return consts.StatusForbidden, err.Error()
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrFollowRequestNotFound):
+		return consts.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrInvalidAction), errors.Is(err, service.ErrSelfRelation):
+		return consts.StatusBadRequest, err.Error()
+	default:
+		return consts.StatusInternalServerError, "internal server error"
+	}
+}
@@ -0,0 +1,420 @@
+// Code generated by hertz generator.
+
+package group
+
+import (
+	"context"
+	"errors"
+
+	"west2/biz/model/base"
+	group "west2/biz/model/group"
+	"west2/database"
+	"west2/pkg/middleware"
+	"west2/pkg/model"
+	"west2/pkg/moderation"
+	"west2/pkg/repository"
+	"west2/pkg/service"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// CreateGroup .
+// @router /group/create [POST]
+func CreateGroup(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.CreateGroupRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	g, err := gs.CreateGroup(uid, req.Title, req.MemberIds)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.CreateGroupResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.CreateGroupResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: model.GroupToResGroup(g),
+	})
+}
+
+// GroupInfo .
+// @router /group/info [GET]
+func GroupInfo(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.GroupInfoRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	g, err := gs.GetGroup(uid, req.GroupId)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.GroupInfoResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.GroupInfoResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: model.GroupToResGroup(g),
+	})
+}
+
+// GroupList .
+// @router /group/list [GET]
+func GroupList(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.GroupListRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	groups, total, err := gs.GetUserGroups(uid, req.PageNum, req.PageSize)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.GroupListResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.GroupListResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &group.GroupList{
+			Items: model.GroupsToResGroups(groups),
+			Total: total,
+		},
+	})
+}
+
+// MemberList .
+// @router /group/member/list [GET]
+func MemberList(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.MemberListRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	members, total, err := gs.GetMembers(uid, req.GroupId, req.PageNum, req.PageSize)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.MemberListResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.MemberListResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &group.MemberList{
+			Items: model.GroupMembersToResMembers(members),
+			Total: total,
+		},
+	})
+}
+
+// Invite .
+// @router /group/invite [POST]
+func Invite(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.InviteRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	err = gs.Invite(uid, req.GroupId, req.UserIds)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.InviteResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.InviteResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// Join .
+// @router /group/join [POST]
+func Join(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.JoinRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	g, err := gs.Join(uid, req.InviteCode)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.JoinResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.JoinResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: model.GroupToResGroup(g),
+	})
+}
+
+// Leave .
+// @router /group/leave [POST]
+func Leave(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.LeaveRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	err = gs.Leave(uid, req.GroupId)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.LeaveResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.LeaveResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// Kick .
+// @router /group/kick [POST]
+func Kick(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.KickRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	err = gs.Kick(uid, req.GroupId, req.UserId)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.KickResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.KickResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// Transfer .
+// @router /group/transfer [POST]
+func Transfer(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.TransferRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	err = gs.Transfer(uid, req.GroupId, req.UserId)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.TransferResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.TransferResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// Rename .
+// @router /group/rename [POST]
+func Rename(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.RenameRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	err = gs.Rename(uid, req.GroupId, req.Title)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.RenameResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.RenameResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// SetRole .
+// @router /group/role [POST]
+func SetRole(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req group.SetRoleRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	gs := newGroupService()
+	err = gs.SetRole(uid, req.GroupId, req.UserId, req.Role)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &group.SetRoleResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &group.SetRoleResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+func newGroupService() service.GroupService {
+	db := database.GetMysqlDB()
+	return service.NewGroupService(
+		repository.NewGroupRepository(db),
+		repository.NewUserRepository(db),
+		repository.NewFollowRepostory(db),
+		moderation.GetEngine(),
+	)
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrPermissionDenied), errors.Is(err, service.ErrNotGroupMember),
+		errors.Is(err, service.ErrBlocked):
+		return consts.StatusForbidden, err.Error()
+	case errors.Is(err, service.ErrGroupNotFound), errors.Is(err, service.ErrUserNotFound):
+		return consts.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrInvalidGroupTitle), errors.Is(err, service.ErrInvalidGroupRole),
+		errors.Is(err, service.ErrOwnerCannotLeave), errors.Is(err, service.ErrSelfRelation),
+		errors.Is(err, service.ErrContentRejected):
+		return consts.StatusBadRequest, err.Error()
+	default:
+		return consts.StatusInternalServerError, "internal server error"
+	}
+}
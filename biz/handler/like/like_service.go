@@ -4,6 +4,7 @@ package like
 
 import (
 	"context"
+	"errors"
 
 	"west2/biz/model/base"
 	like "west2/biz/model/like"
@@ -29,7 +30,7 @@ func LikeAction(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	ls := service.NewLikeService(repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewVideoRepository(database.GetMysqlDB()))
+	ls := service.NewLikeService(repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewLikeCacheRepository(), repository.NewVideoRepository(database.GetMysqlDB()), repository.NewCommentRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()))
 	err = ls.LikeAction(&model.Like{
 		CommentId: req.CommentId,
 		VideoId:   req.VideoId,
@@ -37,10 +38,11 @@ func LikeAction(ctx context.Context, c *app.RequestContext) {
 		Status:    req.ActionType,
 	})
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &like.LikeActionResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &like.LikeActionResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "internal server error",
+				Code: int64(code),
+				Msg:  msg,
 			},
 		})
 		return
@@ -65,13 +67,19 @@ func LikeList(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	ls := service.NewLikeService(repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewVideoRepository(database.GetMysqlDB()))
-	videos, err := ls.GetVideoListByLike(req.Uid, req.PageNum, req.PageSize)
+	ls := service.NewLikeService(repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewLikeCacheRepository(), repository.NewVideoRepository(database.GetMysqlDB()), repository.NewCommentRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()))
+	viewerId := middleware.GetUserFromContext(ctx, c)
+	uid := req.Uid
+	if uid == "" {
+		uid = viewerId
+	}
+	videos, nextCursor, total, err := ls.GetVideoListByLike(viewerId, uid, req.Cursor, req.PageNum, req.PageSize)
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &like.LikeListResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &like.LikeListResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "internal server error",
+				Code: int64(code),
+				Msg:  msg,
 			},
 		})
 		return
@@ -83,7 +91,22 @@ func LikeList(ctx context.Context, c *app.RequestContext) {
 			Msg:  "success",
 		},
 		Data: &video.VideoList{
-			Items: model.VideosToResVideos(videos),
+			Items:      model.VideosToResVideos(videos),
+			Total:      &total,
+			NextCursor: nextCursor,
 		},
 	})
 }
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrVideoNotFound), errors.Is(err, service.ErrCommentNotFound):
+		return consts.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrPrivateAccount):
+		return consts.StatusForbidden, err.Error()
+	case errors.Is(err, service.ErrInvalidAction), errors.Is(err, service.ErrInvalidCursor):
+		return consts.StatusBadRequest, err.Error()
+	default:
+		return consts.StatusInternalServerError, "internal server error"
+	}
+}
@@ -0,0 +1,183 @@
+// Code generated by hertz generator.
+
+package moderation
+
+import (
+	"context"
+	"errors"
+
+	"west2/biz/model/base"
+	moderation "west2/biz/model/moderation"
+	"west2/database"
+	"west2/pkg/middleware"
+	"west2/pkg/model"
+	pkgmoderation "west2/pkg/moderation"
+	"west2/pkg/repository"
+	"west2/pkg/service"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// ReviewList .
+// @router /moderation/review [GET]
+func ReviewList(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req moderation.ReviewListRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	ms := newModerationService()
+	items, next, err := ms.ListReviews(middleware.GetUserFromContext(ctx, c), req.Status, req.Cursor, req.PageSize)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &moderation.ReviewListResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &moderation.ReviewListResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &moderation.ReviewList{
+			Items:      model.ReviewItemsToResReviewItems(items),
+			NextCursor: next,
+		},
+	})
+}
+
+// Approve .
+// @router /moderation/review/approve [POST]
+func Approve(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req moderation.ApproveRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	ms := newModerationService()
+	err = ms.Approve(middleware.GetUserFromContext(ctx, c), req.Id)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &moderation.ApproveResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &moderation.ApproveResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// Reject .
+// @router /moderation/review/reject [POST]
+func Reject(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req moderation.RejectRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	ms := newModerationService()
+	err = ms.Reject(middleware.GetUserFromContext(ctx, c), req.Id)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &moderation.RejectResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &moderation.RejectResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// ReloadWords .
+// @router /moderation/words/reload [POST]
+func ReloadWords(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req moderation.ReloadWordsRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	ms := newModerationService()
+	count, err := ms.ReloadWords(middleware.GetUserFromContext(ctx, c))
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &moderation.ReloadWordsResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &moderation.ReloadWordsResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &moderation.ReloadWords{
+			Count: int64(count),
+		},
+	})
+}
+
+func newModerationService() service.ModerationService {
+	db := database.GetMysqlDB()
+	return service.NewModerationService(
+		repository.NewReviewRepository(db),
+		repository.NewUserRepository(db),
+		repository.NewCommentRepository(db),
+		repository.NewVideoRepository(db),
+		repository.NewNotificationRepository(db),
+		repository.NewSuggestRepository(),
+		pkgmoderation.GetEngine(),
+	)
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrReviewNotFound):
+		return consts.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrPermissionDenied):
+		return consts.StatusForbidden, err.Error()
+	case errors.Is(err, service.ErrReviewResolved):
+		return consts.StatusConflict, err.Error()
+	case errors.Is(err, service.ErrInvalidReviewStatus), errors.Is(err, service.ErrInvalidCursor):
+		return consts.StatusBadRequest, err.Error()
+	default:
+		return consts.StatusInternalServerError, "internal server error"
+	}
+}
@@ -0,0 +1,134 @@
+// Code generated by hertz generator.
+
+package notification
+
+import (
+	"context"
+	"errors"
+
+	"west2/biz/model/base"
+	notification "west2/biz/model/notification"
+	"west2/database"
+	"west2/pkg/middleware"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+	"west2/pkg/service"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// NotificationList .
+// @router /notification/list [GET]
+func NotificationList(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req notification.NotificationListRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+	ns := service.NewNotificationService(repository.NewNotificationRepository(database.GetMysqlDB()))
+	notifications, next, err := ns.List(uid, req.Cursor, req.PageSize)
+	if err == nil {
+		var unread int64
+		unread, err = ns.UnreadCount(uid)
+		if err == nil {
+			c.JSON(consts.StatusOK, &notification.NotificationListResponse{
+				Base: &base.Base{
+					Code: consts.StatusOK,
+					Msg:  "success",
+				},
+				Data: &notification.NotificationList{
+					Items:       model.NotificationsToResNotifications(notifications),
+					NextCursor:  next,
+					UnreadCount: unread,
+				},
+			})
+			return
+		}
+	}
+
+	if errors.Is(err, service.ErrInvalidCursor) {
+		c.JSON(consts.StatusBadRequest, &notification.NotificationListResponse{
+			Base: &base.Base{
+				Code: consts.StatusBadRequest,
+				Msg:  err.Error(),
+			},
+		})
+		return
+	}
+	c.JSON(consts.StatusInternalServerError, &notification.NotificationListResponse{
+		Base: &base.Base{
+			Code: consts.StatusInternalServerError,
+			Msg:  "internal server error",
+		},
+	})
+}
+
+// MarkRead .
+// @router /notification/read [POST]
+func MarkRead(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req notification.MarkReadRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	ns := service.NewNotificationService(repository.NewNotificationRepository(database.GetMysqlDB()))
+	err = ns.MarkRead(middleware.GetUserFromContext(ctx, c), req.Ids, req.All)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &notification.MarkReadResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &notification.MarkReadResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// UnreadCount .
+// @router /notification/unread [GET]
+func UnreadCount(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req notification.UnreadCountRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	ns := service.NewNotificationService(repository.NewNotificationRepository(database.GetMysqlDB()))
+	count, err := ns.UnreadCount(middleware.GetUserFromContext(ctx, c))
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &notification.UnreadCountResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &notification.UnreadCountResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &notification.UnreadCount{
+			Count: count,
+		},
+	})
+}
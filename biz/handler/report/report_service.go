@@ -0,0 +1,191 @@
+// Code generated by hertz generator.
+
+package report
+
+import (
+	"context"
+	"errors"
+
+	"west2/biz/model/base"
+	report "west2/biz/model/report"
+	"west2/database"
+	"west2/pkg/config"
+	"west2/pkg/middleware"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+	"west2/pkg/service"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// Report .
+// @router /report [POST]
+func Report(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req report.ReportRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	rs := newReportService()
+	err = rs.Report(&model.Report{
+		ReporterId: middleware.GetUserFromContext(ctx, c),
+		TargetType: req.TargetType,
+		TargetId:   req.TargetId,
+		Reason:     req.Reason,
+		Detail:     req.Detail,
+	})
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &report.ReportResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusCreated, &report.ReportResponse{
+		Base: &base.Base{
+			Code: consts.StatusCreated,
+			Msg:  "success",
+		},
+	})
+}
+
+// Appeal .
+// @router /report/appeal [POST]
+func Appeal(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req report.AppealRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	rs := newReportService()
+	err = rs.Appeal(middleware.GetUserFromContext(ctx, c), req.CaseId, req.Reason)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &report.AppealResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &report.AppealResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// CaseList .
+// @router /admin/case/list [GET]
+func CaseList(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req report.CaseListRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	rs := newReportService()
+	cases, next, err := rs.ListCases(middleware.GetUserFromContext(ctx, c), req.Status, req.Cursor, req.PageSize)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &report.CaseListResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &report.CaseListResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &report.CaseList{
+			Items:      model.ReportCasesToResReportCases(cases),
+			NextCursor: next,
+		},
+	})
+}
+
+// ResolveCase .
+// @router /admin/case/resolve [POST]
+func ResolveCase(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req report.ResolveCaseRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	rs := newReportService()
+	err = rs.ResolveCase(middleware.GetUserFromContext(ctx, c), req.CaseId, req.Takedown, req.Note)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &report.ResolveCaseResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &report.ResolveCaseResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+func newReportService() service.ReportService {
+	db := database.GetMysqlDB()
+	return service.NewReportService(
+		repository.NewReportRepository(db),
+		repository.NewUserRepository(db),
+		repository.NewVideoRepository(db),
+		repository.NewCommentRepository(db),
+		repository.NewMessageRepository(db),
+		repository.NewGroupRepository(db),
+		config.GetConfig().Report.HideThreshold,
+	)
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrVideoNotFound), errors.Is(err, service.ErrCommentNotFound),
+		errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrMessageNotFound),
+		errors.Is(err, service.ErrCaseNotFound):
+		return consts.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrPermissionDenied):
+		return consts.StatusForbidden, err.Error()
+	case errors.Is(err, service.ErrReportExists), errors.Is(err, service.ErrCaseResolved),
+		errors.Is(err, service.ErrAppealNotAllowed):
+		return consts.StatusConflict, err.Error()
+	case errors.Is(err, service.ErrInvalidTarget), errors.Is(err, service.ErrInvalidReason),
+		errors.Is(err, service.ErrReportSelf), errors.Is(err, service.ErrInvalidCaseStatus),
+		errors.Is(err, service.ErrInvalidCursor):
+		return consts.StatusBadRequest, err.Error()
+	default:
+		return consts.StatusInternalServerError, "internal server error"
+	}
+}
@@ -0,0 +1,122 @@
+// Code generated by hertz generator.
+
+package tag
+
+import (
+	"context"
+
+	"west2/biz/model/base"
+	tag "west2/biz/model/tag"
+	"west2/biz/model/video"
+	"west2/database"
+	"west2/pkg/middleware"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+	"west2/pkg/service"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// TagVideos .
+// @router /tag/videos [GET]
+func TagVideos(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req tag.TagVideosRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	ts := service.NewTagService(repository.NewTagRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()))
+	videos, next, err := ts.GetVideosByTag(middleware.GetUserFromContext(ctx, c), req.Tag, req.Cursor, req.PageSize)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &tag.TagVideosResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &tag.TagVideosResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &video.VideoList{
+			Items:      model.VideosToResVideos(videos),
+			NextCursor: next,
+		},
+	})
+}
+
+// Trending .
+// @router /tag/trending [GET]
+func Trending(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req tag.TrendingRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	ts := service.NewTagService(repository.NewTagRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()))
+	stats, err := ts.GetTrendingTags(req.Limit)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &tag.TrendingResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &tag.TrendingResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &tag.TagList{
+			Items: model.TagStatsToResTags(stats),
+		},
+	})
+}
+
+// TagSuggest .
+// @router /tag/suggest [GET]
+func TagSuggest(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req tag.TagSuggestRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	ts := service.NewTagService(repository.NewTagRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()))
+	tags, err := ts.SuggestTags(req.Prefix, req.Limit)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &tag.TagSuggestResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &tag.TagSuggestResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &tag.TagList{
+			Items: model.TagsToResTags(tags),
+		},
+	})
+}
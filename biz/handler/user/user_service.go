@@ -4,6 +4,7 @@ package user
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"west2/biz/model/base"
@@ -11,6 +12,7 @@ import (
 	"west2/database"
 	"west2/pkg/config"
 	"west2/pkg/middleware"
+	"west2/pkg/model"
 	"west2/pkg/repository"
 	"west2/pkg/service"
 
@@ -38,7 +40,7 @@ func Login(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()))
+	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewSuggestRepository())
 	u, err := us.Login(req.Username, req.Password, req.Code)
 	if err != nil {
 		c.JSON(consts.StatusInternalServerError, &user.LoginResponse{
@@ -91,12 +93,13 @@ func Login(ctx context.Context, c *app.RequestContext) {
 		},
 		Data: &user.UserWithToken{
 			User: &user.User{
-				Id:        u.Id,
-				Username:  u.Username,
-				AvatarUrl: u.AvatarUrl,
-				CreatedAt: u.CreatedAt.Format(dateFormat),
-				UpdatedAt: u.UpdatedAt.Format(dateFormat),
-				DeletedAt: u.DeletedAt.Format(dateFormat),
+				Id:          u.Id,
+				Username:    u.Username,
+				DisplayName: u.DisplayName,
+				AvatarUrl:   u.AvatarUrl,
+				CreatedAt:   u.CreatedAt.Format(dateFormat),
+				UpdatedAt:   u.UpdatedAt.Format(dateFormat),
+				DeletedAt:   u.DeletedAt.Format(dateFormat),
 			},
 			Token: &user.Token{
 				AccessToken:       accessToken,
@@ -124,7 +127,7 @@ func Register(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()))
+	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewSuggestRepository())
 	ok, err := us.Register(req.Username, req.Password)
 	if err != nil {
 		c.JSON(consts.StatusInternalServerError, &user.RegisterResponse{
@@ -169,7 +172,7 @@ func GetUserInfo(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()))
+	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewSuggestRepository())
 	u, err := us.GetUserInfoById(req.UserId)
 	if err != nil {
 		c.JSON(consts.StatusInternalServerError, &user.GetUserInfoResponse{
@@ -196,12 +199,13 @@ func GetUserInfo(ctx context.Context, c *app.RequestContext) {
 			Msg:  "success",
 		},
 		Data: &user.User{
-			Id:        u.Id,
-			Username:  u.Username,
-			AvatarUrl: u.AvatarUrl,
-			CreatedAt: u.CreatedAt.Format(dateFormat),
-			UpdatedAt: u.UpdatedAt.Format(dateFormat),
-			DeletedAt: u.DeletedAt.Format(dateFormat),
+			Id:          u.Id,
+			Username:    u.Username,
+			DisplayName: u.DisplayName,
+			AvatarUrl:   u.AvatarUrl,
+			CreatedAt:   u.CreatedAt.Format(dateFormat),
+			UpdatedAt:   u.UpdatedAt.Format(dateFormat),
+			DeletedAt:   u.DeletedAt.Format(dateFormat),
 		},
 	})
 }
@@ -224,7 +228,7 @@ func UploadAvatar(ctx context.Context, c *app.RequestContext) {
 
 	uid := middleware.GetUserFromContext(ctx, c)
 
-	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()))
+	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewSuggestRepository())
 	u, err := us.UploadAvatar(uid, req.Data)
 	if err != nil {
 		c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
@@ -242,12 +246,13 @@ func UploadAvatar(ctx context.Context, c *app.RequestContext) {
 			Msg:  "success",
 		},
 		Data: &user.User{
-			Id:        u.Id,
-			Username:  u.Username,
-			AvatarUrl: u.AvatarUrl,
-			CreatedAt: u.CreatedAt.Format(dateFormat),
-			UpdatedAt: u.UpdatedAt.Format(dateFormat),
-			DeletedAt: u.DeletedAt.Format(dateFormat),
+			Id:          u.Id,
+			Username:    u.Username,
+			DisplayName: u.DisplayName,
+			AvatarUrl:   u.AvatarUrl,
+			CreatedAt:   u.CreatedAt.Format(dateFormat),
+			UpdatedAt:   u.UpdatedAt.Format(dateFormat),
+			DeletedAt:   u.DeletedAt.Format(dateFormat),
 		},
 	})
 }
@@ -300,6 +305,143 @@ func SearchImg(ctx context.Context, c *app.RequestContext) {
 	c.JSON(consts.StatusOK, resp)
 }
 
+// SearchUser .
+// @router /user/search [GET]
+func SearchUser(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req user.SearchUserRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.JSON(consts.StatusBadRequest, &user.SearchUserResponse{
+			Base: &base.Base{
+				Code: consts.StatusBadRequest,
+				Msg:  err.Error(),
+			},
+		})
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+
+	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewSuggestRepository())
+	users, following, total, err := us.Search(uid, req.Keywords, req.PageNum, req.PageSize)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &user.SearchUserResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &user.SearchUserResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &user.UserList{
+			Items: model.UsersToResUsers(users, following),
+			Total: total,
+		},
+	})
+}
+
+// UpdateProfile .
+// @router /user/profile [PUT]
+func UpdateProfile(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req user.UpdateProfileRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.JSON(consts.StatusBadRequest, &user.UpdateProfileResponse{
+			Base: &base.Base{
+				Code: consts.StatusBadRequest,
+				Msg:  err.Error(),
+			},
+		})
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+
+	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewSuggestRepository())
+	u, err := us.UpdateProfile(uid, req.DisplayName)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &user.UpdateProfileResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &user.UpdateProfileResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &user.User{
+			Id:          u.Id,
+			Username:    u.Username,
+			DisplayName: u.DisplayName,
+			AvatarUrl:   u.AvatarUrl,
+			CreatedAt:   u.CreatedAt.Format(dateFormat),
+			UpdatedAt:   u.UpdatedAt.Format(dateFormat),
+			DeletedAt:   u.DeletedAt.Format(dateFormat),
+		},
+	})
+}
+
+// UpdateSettings .
+// @router /user/settings [PUT]
+func UpdateSettings(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req user.UpdateSettingsRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.JSON(consts.StatusBadRequest, &user.UpdateSettingsResponse{
+			Base: &base.Base{
+				Code: consts.StatusBadRequest,
+				Msg:  err.Error(),
+			},
+		})
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+
+	us := service.NewUserService(repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewSuggestRepository())
+	u, err := us.UpdateSettings(uid, req.MentionPolicy, req.IsPrivate)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMentionPolicy) {
+			c.JSON(consts.StatusBadRequest, &user.UpdateSettingsResponse{
+				Base: &base.Base{
+					Code: consts.StatusBadRequest,
+					Msg:  err.Error(),
+				},
+			})
+			return
+		}
+		c.JSON(consts.StatusInternalServerError, &user.UpdateSettingsResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &user.UpdateSettingsResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: model.UserToResUser(u),
+	})
+}
+
 // Refresh .
 // @router /refresh [GET]
 func Refresh(ctx context.Context, c *app.RequestContext) {
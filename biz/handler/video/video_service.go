@@ -4,13 +4,16 @@ package video
 
 import (
 	"context"
+	"errors"
 
 	"west2/biz/model/base"
 	video "west2/biz/model/video"
 	"west2/database"
 	"west2/pkg/middleware"
 	"west2/pkg/model"
+	"west2/pkg/moderation"
 	"west2/pkg/repository"
+	"west2/pkg/search"
 	"west2/pkg/service"
 
 	"github.com/cloudwego/hertz/pkg/app"
@@ -28,8 +31,8 @@ func VideoStream(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()))
-	videos, err := vs.GetVideoStream(req.LatestTime)
+	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewTagRepository(database.GetMysqlDB()), repository.NewSuggestRepository(), search.GetIndex(), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewReviewRepository(database.GetMysqlDB()), moderation.GetEngine())
+	videos, err := vs.GetVideoStream(middleware.GetUserFromContext(ctx, c), req.LatestTime)
 	if err != nil {
 		c.JSON(consts.StatusInternalServerError, &video.VideoStreamResponse{
 			Base: &base.Base{
@@ -64,13 +67,24 @@ func Publish(ctx context.Context, c *app.RequestContext) {
 
 	uid := middleware.GetUserFromContext(ctx, c)
 
-	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()))
-	err = vs.Publish(req.Title, req.Description, req.Data, uid)
+	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewTagRepository(database.GetMysqlDB()), repository.NewSuggestRepository(), search.GetIndex(), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewReviewRepository(database.GetMysqlDB()), moderation.GetEngine())
+	status, err := vs.Publish(req.Title, req.Description, req.Data, uid)
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &video.PublishResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &video.PublishResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "inernal server error",
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	if status == model.ContentPending {
+		c.JSON(consts.StatusAccepted, &video.PublishResponse{
+			Base: &base.Base{
+				Code: consts.StatusAccepted,
+				Msg:  "pending review",
 			},
 		})
 		return
@@ -95,14 +109,15 @@ func PublishList(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()))
-	videos, total, err := vs.GetVideosByUid(req.Uid, req.PageNum, req.PageSize)
+	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewTagRepository(database.GetMysqlDB()), repository.NewSuggestRepository(), search.GetIndex(), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewReviewRepository(database.GetMysqlDB()), moderation.GetEngine())
+	videos, total, err := vs.GetVideosByUid(middleware.GetUserFromContext(ctx, c), req.Uid, req.PageNum, req.PageSize)
 
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &video.PublishListResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &video.PublishListResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "inernal server error",
+				Code: int64(code),
+				Msg:  msg,
 			},
 		})
 		return
@@ -130,8 +145,8 @@ func Popular(ctx context.Context, c *app.RequestContext) {
 		c.String(consts.StatusBadRequest, err.Error())
 		return
 	}
-	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()))
-	videos, err := vs.GetVideosByVisitCount(req.PageNum, req.PageSize)
+	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewTagRepository(database.GetMysqlDB()), repository.NewSuggestRepository(), search.GetIndex(), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewReviewRepository(database.GetMysqlDB()), moderation.GetEngine())
+	videos, err := vs.GetVideosByVisitCount(middleware.GetUserFromContext(ctx, c), req.PageNum, req.PageSize)
 
 	if err != nil {
 		c.JSON(consts.StatusInternalServerError, &video.PopularResponse{
@@ -165,14 +180,32 @@ func Search(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()))
-	videos, total, err := vs.Search(req.Keywords, req.FromDate, req.ToDate, req.Username, req.PageNum, req.PageSize)
+	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewTagRepository(database.GetMysqlDB()), repository.NewSuggestRepository(), search.GetIndex(), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewReviewRepository(database.GetMysqlDB()), moderation.GetEngine())
+	usernames := req.Usernames
+	if req.Username != "" {
+		usernames = append(usernames, req.Username)
+	}
+	videos, total, err := vs.Search(&service.SearchParams{
+		Uid:          middleware.GetUserFromContext(ctx, c),
+		Keywords:     req.Keywords,
+		FromDate:     req.FromDate,
+		ToDate:       req.ToDate,
+		Usernames:    usernames,
+		Tag:          req.Tag,
+		SortBy:       req.SortBy,
+		MinLikeCount: req.MinLikeCount,
+		MinDuration:  req.MinDuration,
+		MaxDuration:  req.MaxDuration,
+		PageNum:      req.PageNum,
+		PageSize:     req.PageSize,
+	})
 
 	if err != nil {
-		c.JSON(consts.StatusInternalServerError, &video.SearchResponse{
+		code, msg := errorStatus(err)
+		c.JSON(code, &video.SearchResponse{
 			Base: &base.Base{
-				Code: consts.StatusInternalServerError,
-				Msg:  "inernal server error",
+				Code: int64(code),
+				Msg:  msg,
 			},
 		})
 		return
@@ -189,3 +222,165 @@ func Search(ctx context.Context, c *app.RequestContext) {
 		},
 	})
 }
+
+// Suggest .
+// @router /video/search/suggest [GET]
+func Suggest(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req video.SuggestRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+
+	ss := service.NewSuggestService(repository.NewSuggestRepository())
+	items, history, err := ss.Suggest(uid, req.Prefix, req.Limit)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &video.SuggestResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &video.SuggestResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+		Data: &video.Suggestions{
+			Items:   items,
+			History: history,
+		},
+	})
+}
+
+// ClearSearchHistory .
+// @router /video/search/history [DELETE]
+func ClearSearchHistory(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req video.ClearSearchHistoryRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+
+	ss := service.NewSuggestService(repository.NewSuggestRepository())
+	err = ss.ClearHistory(uid)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, &video.ClearSearchHistoryResponse{
+			Base: &base.Base{
+				Code: consts.StatusInternalServerError,
+				Msg:  "internal server error",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &video.ClearSearchHistoryResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// Update .
+// @router /video/update [PUT]
+func Update(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req video.UpdateRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+
+	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewTagRepository(database.GetMysqlDB()), repository.NewSuggestRepository(), search.GetIndex(), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewReviewRepository(database.GetMysqlDB()), moderation.GetEngine())
+	status, err := vs.Edit(req.VideoId, uid, req.Title, req.Description)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &video.UpdateResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	if status == model.ContentPending {
+		c.JSON(consts.StatusAccepted, &video.UpdateResponse{
+			Base: &base.Base{
+				Code: consts.StatusAccepted,
+				Msg:  "pending review",
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &video.UpdateResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+// Delete .
+// @router /video/delete [DELETE]
+func Delete(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req video.DeleteRequest
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := middleware.GetUserFromContext(ctx, c)
+
+	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewTagRepository(database.GetMysqlDB()), repository.NewSuggestRepository(), search.GetIndex(), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewReviewRepository(database.GetMysqlDB()), moderation.GetEngine())
+	err = vs.Delete(req.VideoId, uid)
+	if err != nil {
+		code, msg := errorStatus(err)
+		c.JSON(code, &video.DeleteResponse{
+			Base: &base.Base{
+				Code: int64(code),
+				Msg:  msg,
+			},
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &video.DeleteResponse{
+		Base: &base.Base{
+			Code: consts.StatusOK,
+			Msg:  "success",
+		},
+	})
+}
+
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrVideoNotFound):
+		return consts.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrPermissionDenied), errors.Is(err, service.ErrPrivateAccount):
+		return consts.StatusForbidden, err.Error()
+	case errors.Is(err, service.ErrInvalidTimestamp), errors.Is(err, service.ErrInvalidSort),
+		errors.Is(err, service.ErrContentRejected):
+		return consts.StatusBadRequest, err.Error()
+	default:
+		return consts.StatusInternalServerError, "internal server error"
+	}
+}
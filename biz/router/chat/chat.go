@@ -18,4 +18,15 @@ func Register(r *server.Hertz) {
 
 	root := r.Group("/", rootMw()...)
 	root.GET("/chat", append(_chatMw(), chat.Chat)...)
+	{
+		_chat0 := root.Group("/chat", _chat0Mw()...)
+		{
+			_conversation := _chat0.Group("/conversation", _conversationMw()...)
+			_conversation.GET("/list", append(_conversationlistMw(), chat.ConversationList)...)
+		}
+		{
+			_image := _chat0.Group("/image", _imageMw()...)
+			_image.PUT("/upload", append(_uploadimageMw(), chat.UploadImage)...)
+		}
+	}
 }
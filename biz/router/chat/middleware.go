@@ -39,3 +39,64 @@ func _chatMw() []app.HandlerFunc {
 		jwtMiddleware.MiddlewareFunc(),
 	}
 }
+
+func _chat0Mw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _conversationMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _conversationlistMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
+
+func _imageMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _uploadimageMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
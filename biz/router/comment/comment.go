@@ -21,6 +21,9 @@ func Register(r *server.Hertz) {
 		_comment := root.Group("/comment", _commentMw()...)
 		_comment.DELETE("/delete", append(_deleteMw(), comment.Delete)...)
 		_comment.GET("/list", append(_commentlistMw(), comment.CommentList)...)
+		_comment.DELETE("/pin", append(_unpinMw(), comment.Unpin)...)
+		_comment.POST("/pin", append(_pinMw(), comment.Pin)...)
 		_comment.POST("/publish", append(_commentpublishMw(), comment.CommentPublish)...)
+		_comment.GET("/tree", append(_commenttreeMw(), comment.CommentTree)...)
 	}
 }
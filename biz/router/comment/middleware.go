@@ -90,3 +90,72 @@ func _commentpublishMw() []app.HandlerFunc {
 		jwtMiddleware.MiddlewareFunc(),
 	}
 }
+
+func _pinMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
+
+func _unpinMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
+
+func _commenttreeMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
@@ -17,6 +17,18 @@ import (
 func Register(r *server.Hertz) {
 
 	root := r.Group("/", rootMw()...)
+	{
+		_block := root.Group("/block", _blockMw()...)
+		_block.GET("/list", append(_blocklistMw(), follow.BlockList)...)
+	}
+	{
+		_follow := root.Group("/follow", _followMw()...)
+		{
+			_request := _follow.Group("/request", _requestMw()...)
+			_request.POST("/action", append(_followrequestactionMw(), follow.FollowRequestAction)...)
+			_request.GET("/list", append(_followrequestlistMw(), follow.FollowRequestList)...)
+		}
+	}
 	{
 		_follower := root.Group("/follower", _followerMw()...)
 		_follower.GET("/list", append(_followedlistMw(), follow.FollowedList)...)
@@ -29,8 +41,15 @@ func Register(r *server.Hertz) {
 		_friends := root.Group("/friends", _friendsMw()...)
 		_friends.GET("/list", append(_friendlistMw(), follow.FriendList)...)
 	}
+	{
+		_mute := root.Group("/mute", _muteMw()...)
+		_mute.GET("/list", append(_mutelistMw(), follow.MuteList)...)
+	}
 	{
 		_relation := root.Group("/relation", _relationMw()...)
 		_relation.POST("/action", append(_followactionMw(), follow.FollowAction)...)
+		_relation.POST("/block", append(_blockactionMw(), follow.BlockAction)...)
+		_relation.POST("/mute", append(_muteactionMw(), follow.MuteAction)...)
+		_relation.GET("/suggest", append(_suggestMw(), follow.Suggest)...)
 	}
 }
@@ -128,3 +128,184 @@ func _followactionMw() []app.HandlerFunc {
 		jwtMiddleware.MiddlewareFunc(),
 	}
 }
+
+func _blockMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _blocklistMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
+
+func _muteMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _mutelistMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
+
+func _blockactionMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
+
+func _muteactionMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
+
+func _followMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _requestMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _followrequestactionMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
+
+func _followrequestlistMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
+
+func _suggestMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		jwtMiddleware.MiddlewareFunc(),
+	}
+}
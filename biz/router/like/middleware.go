@@ -47,5 +47,23 @@ func _likeactionMw() []app.HandlerFunc {
 
 func _likelistMw() []app.HandlerFunc {
 	// your code...
-	return nil
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		middleware.OptionalAuth(jwtMiddleware),
+	}
 }
@@ -7,7 +7,12 @@ import (
 	chat "west2/biz/router/chat"
 	comment "west2/biz/router/comment"
 	follow "west2/biz/router/follow"
+	group "west2/biz/router/group"
 	like "west2/biz/router/like"
+	moderation "west2/biz/router/moderation"
+	notification "west2/biz/router/notification"
+	report "west2/biz/router/report"
+	tag "west2/biz/router/tag"
 	user "west2/biz/router/user"
 	video "west2/biz/router/video"
 )
@@ -15,6 +20,16 @@ import (
 // GeneratedRegister registers routers generated by IDL.
 func GeneratedRegister(r *server.Hertz) {
 	//INSERT_POINT: DO NOT DELETE THIS LINE!
+	group.Register(r)
+
+	report.Register(r)
+
+	moderation.Register(r)
+
+	notification.Register(r)
+
+	tag.Register(r)
+
 	chat.Register(r)
 
 	follow.Register(r)
@@ -0,0 +1,56 @@
+// Code generated by hertz generator.
+
+package tag
+
+import (
+	"context"
+	"west2/biz/model/base"
+	"west2/biz/model/user"
+	"west2/pkg/middleware"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+func rootMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _tagMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _tagsuggestMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _trendingMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _tagvideosMw() []app.HandlerFunc {
+	// your code...
+	jwtMiddleware, err := middleware.GetJWTMiddleware()
+	if err != nil {
+		return []app.HandlerFunc{
+			func(ctx context.Context, c *app.RequestContext) {
+				c.JSON(consts.StatusInternalServerError, &user.UploadAvatarResponse{
+					Base: &base.Base{
+						Code: consts.StatusInternalServerError,
+						Msg:  "internal server error",
+					},
+				})
+				c.Abort() // 中止后续处理
+			},
+		}
+
+	}
+
+	return []app.HandlerFunc{
+		middleware.OptionalAuth(jwtMiddleware),
+	}
+}
@@ -22,7 +22,10 @@ func Register(r *server.Hertz) {
 		_user := root.Group("/user", _userMw()...)
 		_user.GET("/info", append(_getuserinfoMw(), user.GetUserInfo)...)
 		_user.POST("/login", append(_loginMw(), user.Login)...)
+		_user.PUT("/profile", append(_updateprofileMw(), user.UpdateProfile)...)
 		_user.POST("/register", append(_registerMw(), user.Register)...)
+		_user.GET("/search", append(_searchuserMw(), user.SearchUser)...)
+		_user.PUT("/settings", append(_updatesettingsMw(), user.UpdateSettings)...)
 		{
 			_avatar := _user.Group("/avatar", _avatarMw()...)
 			_avatar.PUT("/upload", append(_uploadavatarMw(), user.UploadAvatar)...)
@@ -19,10 +19,17 @@ func Register(r *server.Hertz) {
 	root := r.Group("/", rootMw()...)
 	{
 		_video := root.Group("/video", _videoMw()...)
+		_video.DELETE("/delete", append(_deleteMw(), video.Delete)...)
 		_video.GET("/feed", append(_videostreamMw(), video.VideoStream)...)
 		_video.GET("/list", append(_publishlistMw(), video.PublishList)...)
 		_video.GET("/popular", append(_popularMw(), video.Popular)...)
 		_video.POST("/publish", append(_publishMw(), video.Publish)...)
 		_video.POST("/search", append(_searchMw(), video.Search)...)
+		_video.PUT("/update", append(_updateMw(), video.Update)...)
+		{
+			_search := _video.Group("/search", _search0Mw()...)
+			_search.DELETE("/history", append(_clearsearchhistoryMw(), video.ClearSearchHistory)...)
+			_search.GET("/suggest", append(_suggestMw(), video.Suggest)...)
+		}
 	}
 }
@@ -0,0 +1,46 @@
+// Command migratechat imports chat messages that older versions kept only in
+// Redis (the message:all:<from>:<to> and group:<gid> sorted sets) into the
+// messages and conversations tables. It is idempotent and leaves the old keys
+// in place; run it from the repository root once after upgrading. Old versions
+// kept no group records, so a group is created for every imported group id:
+// everyone who sent a message in it becomes a member, the earliest sender
+// becomes the owner, and the title is a placeholder the owner can rename. Members
+// who never spoke have to be invited again. Old versions did not track
+// delivery reliably, so both sides of every imported private conversation are
+// marked as having read it up to its last message.
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+	"west2/database"
+	"west2/pkg/config"
+	"west2/pkg/moderation"
+	"west2/pkg/repository"
+	"west2/pkg/service"
+)
+
+func main() {
+	ctx := context.Background()
+	if err := config.InitConfig(); err != nil {
+		log.Fatalf("failed to load config! err: %v", err)
+	}
+	cfg := config.GetConfig()
+
+	dsn := cfg.Database.Username + ":" + cfg.Database.Password + "@tcp(" + cfg.Database.Host + ":" + cfg.Database.Port + ")/" + cfg.Database.Dbname + "?charset=utf8mb4&parseTime=True&loc=Local"
+	if err := database.InitMysqlDB(dsn); err != nil {
+		log.Fatalf("failed to connect mysql! err: %v", err)
+	}
+
+	if err := database.InitRedis(ctx, cfg.Redis.Addr, cfg.Redis.Password); err != nil {
+		log.Fatalf("failed to connect redis! err: %v", err)
+	}
+
+	cs := service.NewChatService(moderation.GetEngine(), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewMessageRepository(database.GetMysqlDB()), repository.NewMessageCacheRepository(), repository.NewGroupRepository(database.GetMysqlDB()), repository.NewVideoRepository(database.GetMysqlDB()), time.Second*config.GetConfig().Chat.RecallWindow)
+	n, err := cs.ImportLegacyMessages()
+	if err != nil {
+		log.Fatalf("failed to import chat messages! err: %v", err)
+	}
+	log.Printf("%d chat messages imported", n)
+}
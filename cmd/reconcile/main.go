@@ -0,0 +1,49 @@
+// Command reconcile writes pending like changes from Redis to MySQL and then
+// recomputes every video and comment like count from the likes table, and
+// every user's follower and following count from the follows table. Run it
+// from the repository root whenever the counters are suspected to drift, and
+// once after upgrading to backfill the follow counts.
+package main
+
+import (
+	"context"
+	"log"
+	"west2/database"
+	"west2/pkg/config"
+	"west2/pkg/repository"
+	"west2/pkg/service"
+	"west2/util"
+)
+
+func main() {
+	ctx := context.Background()
+	if err := config.InitConfig(); err != nil {
+		log.Fatalf("failed to load config! err: %v", err)
+	}
+	cfg := config.GetConfig()
+
+	dsn := cfg.Database.Username + ":" + cfg.Database.Password + "@tcp(" + cfg.Database.Host + ":" + cfg.Database.Port + ")/" + cfg.Database.Dbname + "?charset=utf8mb4&parseTime=True&loc=Local"
+	if err := database.InitMysqlDB(dsn); err != nil {
+		log.Fatalf("failed to connect mysql! err: %v", err)
+	}
+
+	if err := database.InitRedis(ctx, cfg.Redis.Addr, cfg.Redis.Password); err != nil {
+		log.Fatalf("failed to connect redis! err: %v", err)
+	}
+
+	if err := util.InitSnowflake(cfg.Snowflake.NodeId); err != nil {
+		log.Fatalf("failed to set snowflake node id! err: %v", err)
+	}
+
+	ls := service.NewLikeService(repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewLikeCacheRepository(), repository.NewVideoRepository(database.GetMysqlDB()), repository.NewCommentRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()))
+	if err := ls.ReconcileLikes(); err != nil {
+		log.Fatalf("failed to reconcile likes! err: %v", err)
+	}
+	log.Printf("like counts reconciled")
+
+	fs := service.NewFollowService(repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewRelationCacheRepository())
+	if err := fs.ReconcileFollows(); err != nil {
+		log.Fatalf("failed to reconcile follows! err: %v", err)
+	}
+	log.Printf("follow counts reconciled")
+}
@@ -0,0 +1,44 @@
+// Command reindex rebuilds the video search index from MySQL and writes it
+// to the configured snapshot path. Run it from the repository root while
+// the server is stopped; the server loads the snapshot on start.
+package main
+
+import (
+	"log"
+	"west2/database"
+	"west2/pkg/config"
+	"west2/pkg/moderation"
+	"west2/pkg/repository"
+	"west2/pkg/search"
+	"west2/pkg/service"
+)
+
+func main() {
+	if err := config.InitConfig(); err != nil {
+		log.Fatalf("failed to load config! err: %v", err)
+	}
+	cfg := config.GetConfig()
+
+	dsn := cfg.Database.Username + ":" + cfg.Database.Password + "@tcp(" + cfg.Database.Host + ":" + cfg.Database.Port + ")/" + cfg.Database.Dbname + "?charset=utf8mb4&parseTime=True&loc=Local"
+	if err := database.InitMysqlDB(dsn); err != nil {
+		log.Fatalf("failed to connect mysql! err: %v", err)
+	}
+
+	if err := search.InitIndex(search.Options{
+		Path:             cfg.Search.IndexPath,
+		TitleBoost:       cfg.Search.TitleBoost,
+		DescriptionBoost: cfg.Search.DescriptionBoost,
+	}); err != nil {
+		log.Fatalf("failed to load search index! err: %v", err)
+	}
+
+	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewTagRepository(database.GetMysqlDB()), repository.NewSuggestRepository(), search.GetIndex(), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewReviewRepository(database.GetMysqlDB()), moderation.GetEngine())
+	n, err := vs.RebuildIndex()
+	if err != nil {
+		log.Fatalf("failed to rebuild search index! err: %v", err)
+	}
+	if err := search.GetIndex().Save(); err != nil {
+		log.Fatalf("failed to save search index! err: %v", err)
+	}
+	log.Printf("search index rebuilt: %d videos, path: %s", n, cfg.Search.IndexPath)
+}
@@ -11,6 +11,10 @@ import (
 
 var db *gorm.DB
 
+// followCountsStale 为 true 时说明迁移新增了计数列或删除了重复的关注记录，
+// 需要在 redis 初始化后调用 RecountFollows 回填计数并清理用户资料缓存
+var followCountsStale bool
+
 func InitMysqlDB(dsn string) error {
 	var err error
 	db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
@@ -36,9 +40,56 @@ func InitMysqlDB(dsn string) error {
 }
 
 func autoMigrate() error {
-	return db.AutoMigrate(&model.User{}, &model.Video{}, &model.Like{}, &model.Comment{}, &model.Follow{})
+	m := db.Migrator()
+	if m.HasTable(&model.User{}) && !m.HasColumn(&model.User{}, "FollowerCount") {
+		followCountsStale = true
+	}
+	deleted, err := dedupeFollows()
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		followCountsStale = true
+	}
+	backfillUnread := m.HasTable(&model.Notification{}) && !m.HasColumn(&model.Notification{}, "UnreadBucket")
+	if err := db.AutoMigrate(&model.User{}, &model.Video{}, &model.Like{}, &model.Comment{}, &model.Follow{}, &model.Block{}, &model.Mute{}, &model.Tag{}, &model.VideoTag{}, &model.Notification{}, &model.NotificationActor{}, &model.ReviewItem{}, &model.Report{}, &model.ReportCase{}, &model.Conversation{}, &model.Message{}, &model.ConversationRead{}, &model.MessageReaction{}, &model.Group{}, &model.GroupMember{}); err != nil {
+		return err
+	}
+	if backfillUnread {
+		return backfillNotificationUnread()
+	}
+	return nil
+}
+
+// backfillNotificationUnread 为已有的未读通知填上 unread_bucket，
+// 每个聚合目标只取最新的一条，更早的重复未读通知仍可读取但不再参与合并
+func backfillNotificationUnread() error {
+	return db.Exec(`UPDATE notifications AS n INNER JOIN (
+			SELECT MAX(id) AS id FROM notifications WHERE is_read = false
+			GROUP BY uid, type, target_type, target_id) AS latest ON latest.id = n.id
+		SET n.unread_bucket = ?`, model.NotificationUnread).Error
+}
+
+// dedupeFollows 在建立 (follower_id, following_id) 唯一索引之前删除重复的关注记录，
+// 每对用户保留生效中的、最近更新的一条
+func dedupeFollows() (int64, error) {
+	m := db.Migrator()
+	if !m.HasTable(&model.Follow{}) || m.HasIndex(&model.Follow{}, "idx_follow_pair") {
+		return 0, nil
+	}
+	res := db.Exec(`DELETE f1 FROM follows AS f1 INNER JOIN follows AS f2
+		ON f1.follower_id = f2.follower_id AND f1.following_id = f2.following_id
+		WHERE (f2.status = ? AND f1.status <> ?)
+			OR ((f2.status = ?) = (f1.status = ?)
+				AND (f2.updated_at > f1.updated_at OR (f2.updated_at = f1.updated_at AND f2.id > f1.id)))`,
+		model.FollowStatusActive, model.FollowStatusActive, model.FollowStatusActive, model.FollowStatusActive)
+	return res.RowsAffected, res.Error
 }
 
 func GetMysqlDB() *gorm.DB {
 	return db
 }
+
+func FollowCountsStale() bool {
+	return followCountsStale
+}
@@ -88,3 +88,37 @@ func (ri *redisInstance) ZRevRange(ctx context.Context, key string, start, stop
 func (ri *redisInstance) HGetAll(ctx context.Context, key string) (map[string]string, error) {
 	return ri.client.HGetAll(ctx, key).Result()
 }
+
+func (ri *redisInstance) MGet(ctx context.Context, keys []string) ([]interface{}, error) {
+	return ri.client.MGet(ctx, keys...).Result()
+}
+
+// MSetEx 用一次 pipeline 写入多个键，并为每个键设置相同的过期时间
+func (ri *redisInstance) MSetEx(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	_, err := ri.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for k, v := range values {
+			pipe.Set(ctx, k, v, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// ScanKeys 用 SCAN 遍历匹配 pattern 的所有键，不会像 KEYS 一样阻塞 redis
+func (ri *redisInstance) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	iter := ri.client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+func (ri *redisInstance) Publish(ctx context.Context, channel string, message interface{}) error {
+	return ri.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe 返回的订阅在连接断开后会自动重连，调用方负责 Close
+func (ri *redisInstance) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return ri.client.Subscribe(ctx, channels...)
+}
@@ -5,8 +5,14 @@ package main
 import (
 	"context"
 	"log"
+	"time"
 	"west2/database"
 	"west2/pkg/config"
+	"west2/pkg/hub"
+	"west2/pkg/moderation"
+	"west2/pkg/repository"
+	"west2/pkg/search"
+	"west2/pkg/service"
 	"west2/util"
 
 	"github.com/cloudwego/hertz/pkg/app"
@@ -30,11 +36,73 @@ func main() {
 		log.Fatalf("failed to connect redis! err: %v", err)
 	}
 
+	if database.FollowCountsStale() {
+		if err := repository.NewFollowRepostory(database.GetMysqlDB()).RecountFollows(); err != nil {
+			log.Fatalf("failed to backfill follow counts! err: %v", err)
+		}
+	}
+
 	if err := util.InitSnowflake(cfg.Snowflake.NodeId); err != nil {
 		log.Fatalf("failed to set snowflake node id! err: %v", err)
 	}
 
+	if err := search.InitIndex(search.Options{
+		Path:             cfg.Search.IndexPath,
+		TitleBoost:       cfg.Search.TitleBoost,
+		DescriptionBoost: cfg.Search.DescriptionBoost,
+		FlushInterval:    time.Second * cfg.Search.FlushInterval,
+	}); err != nil {
+		log.Fatalf("failed to load search index! err: %v", err)
+	}
+	vs := service.NewVideoService(repository.NewVideoRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewTagRepository(database.GetMysqlDB()), repository.NewSuggestRepository(), search.GetIndex(), repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()), repository.NewReviewRepository(database.GetMysqlDB()), moderation.GetEngine())
+	if search.GetIndex().Len() == 0 {
+		if _, err := vs.RebuildIndex(); err != nil {
+			log.Fatalf("failed to build search index! err: %v", err)
+		}
+	} else if _, err := vs.SyncIndex(); err != nil {
+		log.Fatalf("failed to sync search index! err: %v", err)
+	}
+	if _, err := vs.BackfillSuggestions(); err != nil {
+		log.Fatalf("failed to backfill search suggestions! err: %v", err)
+	}
+	if _, err := vs.BackfillTags(); err != nil {
+		log.Fatalf("failed to backfill video tags! err: %v", err)
+	}
+	if _, err := vs.BackfillDurations(); err != nil {
+		log.Fatalf("failed to backfill video durations! err: %v", err)
+	}
+
+	if err := moderation.InitEngine(moderation.Options{
+		Path:           cfg.Moderation.WordsPath,
+		DefaultAction:  cfg.Moderation.DefaultAction,
+		ReloadInterval: time.Second * cfg.Moderation.ReloadInterval,
+	}); err != nil {
+		log.Fatalf("failed to load sensitive words! err: %v", err)
+	}
+
+	ls := service.NewLikeService(repository.NewLikeReposirty(database.GetMysqlDB()), repository.NewLikeCacheRepository(), repository.NewVideoRepository(database.GetMysqlDB()), repository.NewCommentRepository(database.GetMysqlDB()), repository.NewUserRepository(database.GetMysqlDB()), repository.NewFollowRepostory(database.GetMysqlDB()), repository.NewNotificationRepository(database.GetMysqlDB()))
+	stopFlush := make(chan struct{})
+	go flushLikes(ls, time.Second*cfg.Like.FlushInterval, stopFlush)
+	if cfg.Search.SyncInterval > 0 {
+		go syncIndex(vs, time.Second*cfg.Search.SyncInterval, stopFlush)
+	}
+
+	hubCtx, stopHub := context.WithCancel(ctx)
+	go hub.GetHub().Run(hubCtx)
+
 	h := server.Default(server.WithHostPorts("0.0.0.0:" + cfg.Server.Port))
+	h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) {
+		if err := search.GetIndex().Save(); err != nil {
+			log.Printf("failed to save search index! err: %v", err)
+		}
+	}, func(ctx context.Context) {
+		stopHub()
+	}, func(ctx context.Context) {
+		close(stopFlush)
+		if _, err := ls.FlushLikes(); err != nil {
+			log.Printf("failed to flush likes! err: %v", err)
+		}
+	})
 
 	h.Use(cors.Default())
 
@@ -42,3 +110,34 @@ func main() {
 	register(h)
 	h.Spin()
 }
+
+// syncIndex 定期把其它实例写入 MySQL 的视频变更同步到本实例的搜索索引
+func syncIndex(vs service.VideoService, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			vs.SyncIndex()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// flushLikes 定期把 Redis 中的点赞变更写回 MySQL
+func flushLikes(ls service.LikeService, interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ls.FlushLikes()
+		case <-stop:
+			return
+		}
+	}
+}
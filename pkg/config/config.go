@@ -33,6 +33,31 @@ type config struct {
 	Snowflake struct {
 		NodeId int64 `yaml:"nodeId"`
 	} `yaml:"snowflake"`
+	Like struct {
+		FlushInterval time.Duration `yaml:"flushInterval"`
+	} `yaml:"like"`
+	Comment struct {
+		MaxDepth    int64 `yaml:"maxDepth"`
+		TreeReplies int64 `yaml:"treeReplies"`
+	} `yaml:"comment"`
+	Search struct {
+		IndexPath        string        `yaml:"indexPath"`
+		TitleBoost       float64       `yaml:"titleBoost"`
+		DescriptionBoost float64       `yaml:"descriptionBoost"`
+		FlushInterval    time.Duration `yaml:"flushInterval"`
+		SyncInterval     time.Duration `yaml:"syncInterval"`
+	} `yaml:"search"`
+	Moderation struct {
+		WordsPath      string        `yaml:"wordsPath"`
+		DefaultAction  string        `yaml:"defaultAction"`
+		ReloadInterval time.Duration `yaml:"reloadInterval"`
+	} `yaml:"moderation"`
+	Report struct {
+		HideThreshold int64 `yaml:"hideThreshold"`
+	} `yaml:"report"`
+	Chat struct {
+		RecallWindow time.Duration `yaml:"recallWindow"`
+	} `yaml:"chat"`
 }
 
 var instance *config
@@ -0,0 +1,206 @@
+// Package hub 记录每个用户打开的 /chat WebSocket 连接，供服务端其它部分向用户推送消息。
+//
+// 每次推送既投递给本实例上的连接，也发布到 Redis 频道，连接在其他实例上的用户同样能收到；
+// 每个实例会跳过自己发布的推送。
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"west2/database"
+
+	"github.com/google/uuid"
+	"github.com/hertz-contrib/websocket"
+)
+
+const (
+	pushChannel = "chat:push"
+
+	// 单个连接待发送队列的长度，队列写满说明客户端消费不过来，直接断开
+	sendQueueSize = 256
+	writeWait     = 10 * time.Second
+	// PongWait 内没有收到任何消息（包括 pong）即认为连接已断开
+	PongWait       = 60 * time.Second
+	pingPeriod     = PongWait * 9 / 10
+	MaxMessageSize = 64 * 1024
+)
+
+// Client 封装一个连接，只有写协程直接写连接，其它地方都通过有界的发送队列
+type Client struct {
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Send 把消息放入发送队列，队列已满时关闭连接并返回 false
+func (c *Client) Send(data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		log.Printf("failed to push message: send queue full, closing connection")
+		c.Close()
+		return false
+	}
+}
+
+// Close 结束写协程并关闭底层连接，读循环会因此返回
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	defer c.Close()
+	for {
+		select {
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("failed to write message: err: %v", err)
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// envelope 是通过 redis 在实例之间转发的一次推送
+type envelope struct {
+	Origin string   `json:"origin"`
+	Uids   []string `json:"uids"`
+	Data   []byte   `json:"data"`
+}
+
+type Hub struct {
+	id      string
+	mu      sync.RWMutex
+	clients map[string]map[*Client]struct{}
+}
+
+var (
+	instance *Hub
+	once     sync.Once
+)
+
+func GetHub() *Hub {
+	once.Do(func() {
+		instance = &Hub{
+			id:      uuid.NewString(),
+			clients: make(map[string]map[*Client]struct{}),
+		}
+	})
+	return instance
+}
+
+// Register 一个用户可以同时有多个连接（多端登录），注册后即启动该连接的写协程
+func (h *Hub) Register(uid string, conn *websocket.Conn) *Client {
+	c := &Client{
+		conn: conn,
+		send: make(chan []byte, sendQueueSize),
+		done: make(chan struct{}),
+	}
+	h.mu.Lock()
+	if h.clients[uid] == nil {
+		h.clients[uid] = make(map[*Client]struct{})
+	}
+	h.clients[uid][c] = struct{}{}
+	h.mu.Unlock()
+
+	go c.writePump()
+	return c
+}
+
+func (h *Hub) Unregister(uid string, c *Client) {
+	h.mu.Lock()
+	delete(h.clients[uid], c)
+	if len(h.clients[uid]) == 0 {
+		delete(h.clients, uid)
+	}
+	h.mu.Unlock()
+	c.Close()
+}
+
+// Send 推送给用户当前在线的所有连接，用户不在线时直接丢弃
+func (h *Hub) Send(uid string, data []byte) {
+	h.SendMany([]string{uid}, data)
+}
+
+// SendMany 推送给多个用户，包括连接在其他实例上的用户
+func (h *Hub) SendMany(uids []string, data []byte) {
+	h.deliver(uids, data)
+	h.publish(&envelope{Origin: h.id, Uids: uids, Data: data})
+}
+
+func (h *Hub) deliver(uids []string, data []byte) {
+	h.mu.RLock()
+	var clients []*Client
+	for _, uid := range uids {
+		for c := range h.clients[uid] {
+			clients = append(clients, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range clients {
+		c.Send(data)
+	}
+}
+
+func (h *Hub) publish(e *envelope) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("failed to marshal push envelope: err: %v", err)
+		return
+	}
+	instance := database.GetRedisInstance()
+	if err := instance.Publish(context.Background(), pushChannel, payload); err != nil {
+		log.Printf("failed to publish push: channel: %s, err: %v", pushChannel, err)
+	}
+}
+
+// Run 订阅其他实例发布的推送并投递给本实例上的连接，直到 ctx 结束
+func (h *Hub) Run(ctx context.Context) {
+	instance := database.GetRedisInstance()
+	sub := instance.Subscribe(ctx, pushChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var e envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				log.Printf("failed to unmarshal push envelope: err: %v", err)
+				continue
+			}
+			if e.Origin == h.id {
+				continue
+			}
+			h.deliver(e.Uids, e.Data)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -60,3 +60,13 @@ func GetUserFromContext(ctx context.Context, c *app.RequestContext) string {
 	}
 	return ""
 }
+
+// OptionalAuth 在携带有效 Access-Token 时解析出用户，未携带或无效时按匿名用户继续处理
+func OptionalAuth(mw *jwt.HertzJWTMiddleware) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if claims, err := mw.GetClaimsFromJWT(ctx, c); err == nil {
+			c.Set("JWT_PAYLOAD", claims)
+		}
+		c.Next(ctx)
+	}
+}
@@ -1,11 +1,62 @@
 package model
 
+import (
+	"time"
+	"west2/biz/model/chat"
+)
+
+// /chat 连接上收发的消息都是 {"type": <Type>, "data": <data>}，回执与输入状态的 data 格式如下：
+//
+//	TypeMessageAck     客户端 -> 服务端 {"messageId": "...", "state": "delivered" | "read"}
+//	                   确认收到或读到某条消息，该消息及之前的消息都视为送达或已读，read 同时包含 delivered
+//	TypeMessageReceipt 服务端 -> 客户端 {"conversationId": "...", "groupId": "...", "userId": "...",
+//	                   "messageId": "...", "state": "delivered" | "read", "time": <unix 秒>}
+//	                   推送给消息发送方以及确认方自己的其他连接，groupId 只在群聊中出现
+//	TypeTyping         客户端 -> 服务端 {"targetUserId": "...", "groupId": "...", "typing": true}
+//	                   targetUserId 与 groupId 二选一，服务端不回复
+//	                   服务端 -> 客户端 {"conversationId": "...", "groupId": "...", "userId": "...", "typing": true}
+//	                   只推送给当前在线的连接，不会保存
+//	TypeMessageRecall  客户端 -> 服务端 {"messageId": "..."}，只有发送方可以在发送后的一段时间内撤回
+//	                   服务端 -> 客户端 {"conversationId": "...", "groupId": "...", "messageId": "...", "userId": "..."}
+//	TypeMessageReaction 客户端 -> 服务端 {"messageId": "...", "emoji": "👍", "remove": false}
+//	                   服务端 -> 客户端 {"conversationId": "...", "groupId": "...", "messageId": "...", "userId": "...",
+//	                   "emoji": "👍", "removed": false}
+//	                   撤回和表情回应都推送给会话中的所有成员
+//
+// 私聊和群聊消息的内容见 MessageBody。
+const (
+	TypePrivateMessage  int = iota // 私聊发送消息，也用于推送新的私聊消息
+	TypePrivateHistory             // 获取私聊历史记录
+	TypePrivateUnread              // 获取私聊未读消息
+	TypeGroupMessage               // 群聊发送消息，也用于推送新的群聊消息
+	TypeGroupHistory               // 获取群聊历史记录
+	TypeNotification               // 服务端推送的通知
+	TypeGroupCreate                // 创建群聊
+	TypeGroupInvite                // 邀请用户入群
+	TypeGroupJoin                  // 通过邀请码入群
+	TypeGroupLeave                 // 退出群聊
+	TypeGroupKick                  // 移出群成员
+	TypeGroupTransfer              // 转让群主
+	TypeGroupRename                // 修改群名称
+	TypeGroupSetRole               // 设置或取消管理员
+	TypeGroupEvent                 // 服务端推送的群成员变动
+	TypeMessageAck                 // 客户端确认消息已送达或已读
+	TypeMessageReceipt             // 服务端推送的送达和已读回执
+	TypeTyping                     // 正在输入
+	TypeMessageRecall              // 撤回消息
+	TypeMessageReaction            // 添加或取消表情回应
+)
+
 const (
-	TypePrivateMessage int = iota // 私聊发送消息
-	TypePrivateHistory            // 获取私聊历史记录
-	TypePrivateUnread             // 获取私聊未读消息
-	TypeGroupMessage              // 群聊发送消息
-	TypeGroupHistory              // 获取群聊历史记录
+	MessageStateSent      = "sent"
+	MessageStateDelivered = "delivered"
+	MessageStateRead      = "read"
+)
+
+const (
+	MessageKindText  = "text"
+	MessageKindImage = "image" // imageUrl 为 /chat/image/upload 返回的地址
+	MessageKindVideo = "video" // 分享站内视频，content 为可选的附言
 )
 
 type WSMessage struct {
@@ -13,40 +64,385 @@ type WSMessage struct {
 	Data interface{} `json:"data"`
 }
 
+// MessageBody 是私聊和群聊消息共用的内容，发送时只读取 kind、content、imageUrl、videoId 和 replyToId，
+// kind 为空时视为 text；其余字段在返回历史消息和实时推送时填充，撤回的消息只保留 recalled
+type MessageBody struct {
+	Kind      string           `json:"kind"`
+	Content   string           `json:"content"`
+	ImageUrl  string           `json:"imageUrl,omitempty"`
+	VideoId   string           `json:"videoId,omitempty"`
+	Video     *VideoCard       `json:"video,omitempty"` // 视频已删除或不可见时为空
+	ReplyToId string           `json:"replyToId,omitempty"`
+	ReplyTo   *MessageQuote    `json:"replyTo,omitempty"`
+	Recalled  bool             `json:"recalled"`
+	Reactions []*ReactionCount `json:"reactions,omitempty"`
+}
+
+type VideoCard struct {
+	Id       string    `json:"id"`
+	Title    string    `json:"title"`
+	CoverUrl string    `json:"coverUrl"`
+	Duration int64     `json:"duration"`
+	Author   *ChatUser `json:"author,omitempty"`
+}
+
+// MessageQuote 是被回复消息的摘要
+type MessageQuote struct {
+	Id       string `json:"id"`
+	UserId   string `json:"userId"`
+	Kind     string `json:"kind"`
+	Content  string `json:"content"`
+	Recalled bool   `json:"recalled"`
+}
+
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"` // 当前用户是否添加了该表情
+}
+
 type PrivateMsg struct {
 	Id       string `json:"id"`
 	UserId   string `json:"userId"`
 	ToUserId string `json:"toUserId"`
-	Content  string `json:"content"`
-	Status   int    `json:"status"`
-	Time     int64  `json:"time"`
+	MessageBody
+	State  string    `json:"state"` // sent、delivered 或 read，由接收方的送达和已读位置决定
+	Time   int64     `json:"time"`
+	Sender *ChatUser `json:"sender,omitempty"` // 只在返回历史消息和实时推送时填充
 }
 
+// HistoryRequest 从新到旧分页，下一页的 BeforeMessageId 为上一页最后一条消息的 id
 type HistoryRequest struct {
-	TargetUserId string `json:"targetUserId"`
-	PageNum      int64  `json:"pageNum"`  // 从 1 开始
-	PageSize     int64  `json:"pageSize"` // 默认 20
+	TargetUserId    string `json:"targetUserId"`
+	BeforeMessageId string `json:"beforeMessageId"` // 为空时从最新一条开始
+	PageSize        int64  `json:"pageSize"`        // 默认 20
 }
 
 type UnreadHistoryRequest struct {
 	TargetUserId string `json:"targetUserId"`
 }
 
+type MessageAck struct {
+	MessageId string `json:"messageId"`
+	State     string `json:"state"`
+}
+
+type MessageReceipt struct {
+	ConversationId string `json:"conversationId"`
+	GroupId        string `json:"groupId,omitempty"`
+	UserId         string `json:"userId"`
+	MessageId      string `json:"messageId"`
+	State          string `json:"state"`
+	Time           int64  `json:"time"`
+}
+
+type TypingRequest struct {
+	TargetUserId string `json:"targetUserId"`
+	GroupId      string `json:"groupId"`
+	Typing       bool   `json:"typing"`
+}
+
+type TypingEvent struct {
+	ConversationId string `json:"conversationId"`
+	GroupId        string `json:"groupId,omitempty"`
+	UserId         string `json:"userId"`
+	Typing         bool   `json:"typing"`
+}
+
+type MessageRecallRequest struct {
+	MessageId string `json:"messageId"`
+}
+
+type MessageRecallEvent struct {
+	ConversationId string `json:"conversationId"`
+	GroupId        string `json:"groupId,omitempty"`
+	MessageId      string `json:"messageId"`
+	UserId         string `json:"userId"`
+}
+
+type MessageReactionRequest struct {
+	MessageId string `json:"messageId"`
+	Emoji     string `json:"emoji"`
+	Remove    bool   `json:"remove"`
+}
+
+type MessageReactionEvent struct {
+	ConversationId string `json:"conversationId"`
+	GroupId        string `json:"groupId,omitempty"`
+	MessageId      string `json:"messageId"`
+	UserId         string `json:"userId"`
+	Emoji          string `json:"emoji"`
+	Removed        bool   `json:"removed"`
+}
+
 type GroupMessage struct {
 	Id      string `json:"id"`
 	GroupId string `json:"groupId"`
 	UserId  string `json:"userId"`
-	Content string `json:"content"`
-	Time    int64  `json:"time"`
+	MessageBody
+	Time   int64     `json:"time"`
+	Sender *ChatUser `json:"sender,omitempty"` // 只在返回历史消息和实时推送时填充
+}
+
+type ChatUser struct {
+	Id          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName"`
+	AvatarUrl   string `json:"avatarUrl"`
+}
+
+func UserToChatUser(u *User) *ChatUser {
+	if u == nil {
+		return nil
+	}
+	return &ChatUser{
+		Id:          u.Id,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		AvatarUrl:   u.AvatarUrl,
+	}
 }
 
-type Group struct {
-	Id    string `json:"id"`
-	Title string `json:"title"`
+const (
+	ConversationPrivate = "private"
+	ConversationGroup   = "group"
+)
+
+// Conversation 私聊的 id 由双方 id 排序后拼接，群聊的 id 由群 id 拼接，同一会话只有一行
+type Conversation struct {
+	Id            string    `gorm:"type:varchar(100);primaryKey"`
+	Type          string    `gorm:"type:varchar(16);not null"`
+	GroupId       string    `gorm:"type:varchar(100);index"`
+	UserA         string    `gorm:"type:varchar(100);index"`
+	UserB         string    `gorm:"type:varchar(100);index"`
+	LastMessageId string    `gorm:"type:varchar(100)"`
+	LastMessageAt time.Time `gorm:"index"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+	Peer          *User     `gorm:"-"` // 私聊中的另一方，只在返回会话列表时填充
+	LastMessage   *Message  `gorm:"-"`
+	UnreadCount   int64     `gorm:"-"`
+}
+
+type Message struct {
+	Id             string     `gorm:"type:varchar(100);primaryKey;index:idx_message_conversation,priority:3"`
+	ConversationId string     `gorm:"type:varchar(100);not null;index:idx_message_conversation,priority:1"`
+	SenderId       string     `gorm:"type:varchar(100);not null"`
+	ReceiverId     string     `gorm:"type:varchar(100)"`
+	GroupId        string     `gorm:"type:varchar(100)"`
+	Kind           string     `gorm:"type:varchar(16);not null;default:text"`
+	Content        string     `gorm:"type:text;not null"`
+	ImageUrl       string     `gorm:"type:varchar(256)"`
+	VideoId        string     `gorm:"type:varchar(100)"`
+	ReplyToId      string     `gorm:"type:varchar(100)"`
+	RecalledAt     *time.Time // 撤回后内容仍保留在库中供举报审核，但不再返回给客户端
+	CreatedAt      time.Time  `gorm:"index:idx_message_conversation,priority:2"`
+}
+
+// MessageReaction 每个用户对同一条消息的同一个表情只有一行
+type MessageReaction struct {
+	MessageId string    `gorm:"type:varchar(100);primaryKey"`
+	Uid       string    `gorm:"type:varchar(100);primaryKey"`
+	Emoji     string    `gorm:"type:varchar(32);primaryKey"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index"`
+}
+
+// PeerId 返回私聊会话中 uid 之外的另一方
+func (c *Conversation) PeerId(uid string) string {
+	if c.UserA == uid {
+		return c.UserB
+	}
+	return c.UserA
+}
+
+func ConversationToResConversation(c *Conversation) *chat.Conversation {
+	res := &chat.Conversation{
+		Id:            c.Id,
+		Peer:          UserToAuthor(c.Peer),
+		LastMessageId: c.LastMessageId,
+		LastMessageAt: c.LastMessageAt.Format(dateFormat),
+		UnreadCount:   c.UnreadCount,
+	}
+	if c.LastMessage != nil {
+		body := MessageToMessageBody(c.LastMessage)
+		res.LastMessageSenderId = c.LastMessage.SenderId
+		res.LastMessageKind = body.Kind
+		res.LastMessageContent = body.Content
+		res.LastMessageRecalled = body.Recalled
+	}
+	return res
+}
+
+func ConversationsToResConversations(convs []*Conversation) []*chat.Conversation {
+	res := make([]*chat.Conversation, len(convs))
+	for i, c := range convs {
+		res[i] = ConversationToResConversation(c)
+	}
+	return res
+}
+
+// ConversationRead 是用户在会话中的送达和已读位置，位置及之前的消息都视为已送达或已读
+type ConversationRead struct {
+	ConversationId     string     `gorm:"type:varchar(100);primaryKey"`
+	Uid                string     `gorm:"type:varchar(100);primaryKey"`
+	DeliveredMessageId string     `gorm:"type:varchar(100)"`
+	DeliveredAt        *time.Time // 对应消息的发送时间
+	ReadMessageId      string     `gorm:"type:varchar(100)"`
+	ReadAt             *time.Time
+	UpdatedAt          time.Time `gorm:"autoUpdateTime"`
+}
+
+// MessageState 返回 m 相对于接收方位置 r 的状态，r 为 nil 表示接收方从未确认过
+func MessageState(m *Message, r *ConversationRead) string {
+	switch {
+	case r == nil:
+		return MessageStateSent
+	case notAfter(m, r.ReadAt, r.ReadMessageId):
+		return MessageStateRead
+	case notAfter(m, r.DeliveredAt, r.DeliveredMessageId):
+		return MessageStateDelivered
+	default:
+		return MessageStateSent
+	}
+}
+
+// notAfter 与消息列表的排序一致，按 (发送时间, id) 比较 m 是否位于位置 (at, id) 及之前
+func notAfter(m *Message, at *time.Time, id string) bool {
+	if at == nil {
+		return false
+	}
+	if !m.CreatedAt.Equal(*at) {
+		return m.CreatedAt.Before(*at)
+	}
+	return m.Id <= id
+}
+
+func PrivateConversationId(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return ConversationPrivate + ":" + a + ":" + b
+}
+
+func GroupConversationId(groupId string) string {
+	return ConversationGroup + ":" + groupId
+}
+
+// MessageToMessageBody 只转换消息本身的字段，撤回的消息不返回内容
+func MessageToMessageBody(m *Message) MessageBody {
+	kind := m.Kind
+	if kind == "" {
+		kind = MessageKindText
+	}
+	if m.RecalledAt != nil {
+		return MessageBody{Kind: kind, Recalled: true}
+	}
+	return MessageBody{
+		Kind:      kind,
+		Content:   m.Content,
+		ImageUrl:  m.ImageUrl,
+		VideoId:   m.VideoId,
+		ReplyToId: m.ReplyToId,
+	}
+}
+
+func MessageToMessageQuote(m *Message) *MessageQuote {
+	body := MessageToMessageBody(m)
+	return &MessageQuote{
+		Id:       m.Id,
+		UserId:   m.SenderId,
+		Kind:     body.Kind,
+		Content:  body.Content,
+		Recalled: body.Recalled,
+	}
+}
+
+func VideoToVideoCard(v *Video) *VideoCard {
+	return &VideoCard{
+		Id:       v.Id,
+		Title:    v.Title,
+		CoverUrl: v.CoverUrl,
+		Duration: v.Duration,
+		Author:   UserToChatUser(v.Author),
+	}
+}
+
+// ReactionsToReactionCounts 按消息分组统计表情，rs 需按添加时间排序，同一消息的表情按第一次出现的顺序排列
+func ReactionsToReactionCounts(rs []*MessageReaction, uid string) map[string][]*ReactionCount {
+	res := make(map[string][]*ReactionCount)
+	index := make(map[string]map[string]*ReactionCount)
+	for _, r := range rs {
+		if index[r.MessageId] == nil {
+			index[r.MessageId] = make(map[string]*ReactionCount)
+		}
+		rc := index[r.MessageId][r.Emoji]
+		if rc == nil {
+			rc = &ReactionCount{Emoji: r.Emoji}
+			index[r.MessageId][r.Emoji] = rc
+			res[r.MessageId] = append(res[r.MessageId], rc)
+		}
+		rc.Count++
+		if r.Uid == uid {
+			rc.Reacted = true
+		}
+	}
+	return res
+}
+
+func MessageToPrivateMsg(m *Message) *PrivateMsg {
+	return &PrivateMsg{
+		Id:          m.Id,
+		UserId:      m.SenderId,
+		ToUserId:    m.ReceiverId,
+		MessageBody: MessageToMessageBody(m),
+		State:       MessageStateSent,
+		Time:        m.CreatedAt.Unix(),
+	}
+}
+
+func MessageToGroupMessage(m *Message) *GroupMessage {
+	return &GroupMessage{
+		Id:          m.Id,
+		GroupId:     m.GroupId,
+		UserId:      m.SenderId,
+		MessageBody: MessageToMessageBody(m),
+		Time:        m.CreatedAt.Unix(),
+	}
+}
+
+// GroupActionRequest 是各个群管理消息共用的请求，每种消息只读取自己需要的字段
+type GroupActionRequest struct {
+	GroupId    string   `json:"groupId"`
+	Title      string   `json:"title"`
+	UserId     string   `json:"userId"`
+	UserIds    []string `json:"userIds"`
+	InviteCode string   `json:"inviteCode"`
+	Role       int64    `json:"role"`
+}
+
+const (
+	GroupEventCreate   = "create"
+	GroupEventInvite   = "invite"
+	GroupEventJoin     = "join"
+	GroupEventLeave    = "leave"
+	GroupEventKick     = "kick"
+	GroupEventTransfer = "transfer"
+	GroupEventRename   = "rename"
+	GroupEventSetRole  = "set_role"
+)
+
+// GroupEvent 是 TypeGroupEvent 消息的 data，UserIds 为受影响的用户
+type GroupEvent struct {
+	GroupId    string   `json:"groupId"`
+	Event      string   `json:"event"`
+	OperatorId string   `json:"operatorId"`
+	UserIds    []string `json:"userIds,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	Role       int64    `json:"role,omitempty"`
 }
 
 type GroupHistoryRequest struct {
-	GroupId  string `json:"groupId"`
-	PageNum  int64  `json:"pageNum"`  // 从 1 开始
-	PageSize int64  `json:"pageSize"` // 默认 20
+	GroupId         string `json:"groupId"`
+	BeforeMessageId string `json:"beforeMessageId"` // 为空时从最新一条开始
+	PageSize        int64  `json:"pageSize"`        // 默认 20
 }
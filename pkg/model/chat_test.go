@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessageState(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Second)
+	t2 := t0.Add(2 * time.Second)
+
+	tests := []struct {
+		name string
+		msg  *Message
+		read *ConversationRead
+		want string
+	}{
+		{"never acknowledged", &Message{Id: "a", CreatedAt: t0}, nil, MessageStateSent},
+		{"no positions yet", &Message{Id: "a", CreatedAt: t0}, &ConversationRead{}, MessageStateSent},
+		{"before read position", &Message{Id: "a", CreatedAt: t0},
+			&ConversationRead{ReadAt: &t1, ReadMessageId: "b", DeliveredAt: &t1, DeliveredMessageId: "b"}, MessageStateRead},
+		{"at read position", &Message{Id: "b", CreatedAt: t1},
+			&ConversationRead{ReadAt: &t1, ReadMessageId: "b", DeliveredAt: &t1, DeliveredMessageId: "b"}, MessageStateRead},
+		{"same time with larger id is after read position", &Message{Id: "c", CreatedAt: t1},
+			&ConversationRead{ReadAt: &t1, ReadMessageId: "b", DeliveredAt: &t2, DeliveredMessageId: "d"}, MessageStateDelivered},
+		{"same time with smaller id is before read position", &Message{Id: "a", CreatedAt: t1},
+			&ConversationRead{ReadAt: &t1, ReadMessageId: "b"}, MessageStateRead},
+		{"delivered but not read", &Message{Id: "b", CreatedAt: t1},
+			&ConversationRead{DeliveredAt: &t2, DeliveredMessageId: "c"}, MessageStateDelivered},
+		{"after delivered position", &Message{Id: "d", CreatedAt: t2},
+			&ConversationRead{ReadAt: &t0, ReadMessageId: "a", DeliveredAt: &t1, DeliveredMessageId: "b"}, MessageStateSent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MessageState(tt.msg, tt.read); got != tt.want {
+				t.Errorf("MessageState() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
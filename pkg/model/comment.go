@@ -6,16 +6,25 @@ import (
 )
 
 type Comment struct {
-	Id         string    `gorm:"type:varchar(100);primaryKey"`
-	VideoId    string    `gorm:"type:varchar(100)"`
-	Uid        string    `gorm:"type:varchar(100)"`
-	ParentId   string    `gorm:"type:varchar(100);default:null"`
-	LikeCount  int64     `gorm:"type:int;default:0"`
-	ChildCount int64     `gorm:"type:int;default:0"`
-	Content    string    `gorm:"type:varchar(1000);null not"`
-	CreatedAt  time.Time `gorm:"autoCreateTime"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
-	DeletedAt  time.Time `gorm:"type:datetime;default:null"`
+	Id         string     `gorm:"type:varchar(100);primaryKey"`
+	VideoId    string     `gorm:"type:varchar(100)"`
+	Uid        string     `gorm:"type:varchar(100)"`
+	ParentId   string     `gorm:"type:varchar(100);default:null;index"`
+	RootId     string     `gorm:"type:varchar(100);default:null"`
+	Depth      int64      `gorm:"type:int;default:0"`
+	LikeCount  int64      `gorm:"type:int;default:0"`
+	ChildCount int64      `gorm:"type:int;default:0"`
+	HotScore   float64    `gorm:"type:double;default:0;index"`
+	Content    string     `gorm:"type:varchar(1000);null not"`
+	Mentions   Mentions   `gorm:"type:json"`
+	Status     int64      `gorm:"type:tinyint;default:0"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime"`
+	DeletedAt  time.Time  `gorm:"type:datetime;default:null"`
+	Replies    []*Comment `gorm:"-"`
+	Pinned     bool       `gorm:"-"`
+	Liked      bool       `gorm:"-"`
+	Author     *User      `gorm:"-"`
 }
 
 func CommentToresComment(c *Comment) *comment.Comment {
@@ -27,14 +36,21 @@ func CommentToresComment(c *Comment) *comment.Comment {
 	return &comment.Comment{
 		Id:         c.Id,
 		Uid:        c.Uid,
-		VideoId:    c.Uid,
+		VideoId:    c.VideoId,
 		ParentId:   c.ParentId,
+		RootId:     c.RootId,
+		Depth:      c.Depth,
 		LikeCount:  &likeCount,
 		ChildCount: &childCount,
 		Content:    c.Content,
 		CreatedAt:  c.CreatedAt.Format(dateFormat),
 		UpdatedAt:  c.UpdatedAt.Format(dateFormat),
 		DeletedAt:  c.DeletedAt.Format(dateFormat),
+		Replies:    CommentsToResComments(c.Replies),
+		IsPinned:   c.Pinned,
+		IsLiked:    c.Liked,
+		Mentions:   MentionsToResMentions(c.Mentions),
+		Author:     UserToAuthor(c.Author),
 	}
 }
 
@@ -5,21 +5,47 @@ import (
 	"west2/biz/model/follow"
 )
 
+const (
+	FollowStatusNone    int64 = 0
+	FollowStatusActive  int64 = 1
+	FollowStatusPending int64 = 2 // 关注私密账号时的申请，通过后变为 FollowStatusActive
+)
+
+// Follow 每对用户只有一行，取消关注时只修改状态
 type Follow struct {
 	Id          string    `gorm:"type:varchar(100);primaryKey"`
-	FollowingId string    `gorm:"type:varchar(100);not null"`
-	FollowerId  string    `gorm:"type:varchar(100);not null"`
+	FollowingId string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_follow_pair,priority:2"`
+	FollowerId  string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_follow_pair,priority:1;index:idx_follow_recent,priority:1"`
 	Status      int64     `gorm:"type:int(2);noy null;default:0"`
 	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime;index:idx_follow_recent,priority:2"`
 	DeletedAt   time.Time `gorm:"default:null"`
 }
 
+// Block 由 BlockerId 发起，但对双方都生效：互相看不到视频和评论、不能关注和私信
+type Block struct {
+	Id        string    `gorm:"type:varchar(100);primaryKey"`
+	BlockerId string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_block_pair"`
+	BlockedId string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_block_pair;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Mute 只对 MuterId 生效：过滤其推荐流与通知中 MutedId 的内容
+type Mute struct {
+	Id        string    `gorm:"type:varchar(100);primaryKey"`
+	MuterId   string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_mute_pair"`
+	MutedId   string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_mute_pair"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
 func UserToFollowUser(u *User) *follow.User {
 	return &follow.User{
-		Id:        u.Id,
-		Username:  u.Username,
-		AvatarUrl: u.AvatarUrl,
+		Id:             u.Id,
+		Username:       u.Username,
+		AvatarUrl:      u.AvatarUrl,
+		FollowerCount:  u.FollowerCount,
+		FollowingCount: u.FollowingCount,
+		IsPrivate:      u.IsPrivate,
 	}
 }
 
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+	"west2/biz/model/group"
+)
+
+const (
+	GroupRoleMember = 1
+	GroupRoleAdmin  = 2
+	GroupRoleOwner  = 3
+)
+
+// Group InviteCode 为入群链接中携带的邀请码，持有者可以直接加入
+type Group struct {
+	Id          string    `gorm:"type:varchar(100);primaryKey"`
+	Title       string    `gorm:"type:varchar(100);not null"`
+	OwnerId     string    `gorm:"type:varchar(100);not null"`
+	InviteCode  string    `gorm:"type:varchar(32);not null;uniqueIndex"`
+	MemberCount int64     `gorm:"default:0"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	Role        int64     `gorm:"->;-:migration"` // 当前用户在群中的角色，只在查询用户加入的群时读出
+}
+
+type GroupMember struct {
+	Id        string    `gorm:"type:varchar(100);primaryKey"`
+	GroupId   string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_group_member"`
+	Uid       string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_group_member;index"`
+	Role      int64     `gorm:"type:tinyint;not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	User      *User     `gorm:"-"`
+}
+
+// GroupToResGroup 邀请码只返回给群主和管理员
+func GroupToResGroup(g *Group) *group.Group {
+	res := &group.Group{
+		Id:          g.Id,
+		Title:       g.Title,
+		OwnerId:     g.OwnerId,
+		MemberCount: g.MemberCount,
+		Role:        g.Role,
+		CreatedAt:   g.CreatedAt.Format(dateFormat),
+	}
+	if g.Role >= GroupRoleAdmin {
+		res.InviteCode = g.InviteCode
+	}
+	return res
+}
+
+func GroupsToResGroups(groups []*Group) []*group.Group {
+	res := make([]*group.Group, len(groups))
+	for i, g := range groups {
+		res[i] = GroupToResGroup(g)
+	}
+	return res
+}
+
+func GroupMembersToResMembers(members []*GroupMember) []*group.Member {
+	res := make([]*group.Member, len(members))
+	for i, m := range members {
+		res[i] = &group.Member{
+			User:     UserToAuthor(m.User),
+			Role:     m.Role,
+			JoinedAt: m.CreatedAt.Format(dateFormat),
+		}
+	}
+	return res
+}
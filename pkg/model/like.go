@@ -2,13 +2,18 @@ package model
 
 import "time"
 
+const (
+	LikeStatusLiked    int64 = 1
+	LikeStatusCanceled int64 = 2
+)
+
 type Like struct {
 	Id        string    `gorm:"type:varchar(100);primaryKey"`
-	Uid       string    `gorm:"type:varchar(100)"`
-	VideoId   string    `gorm:"type:varchar(100);default:null"`
+	Uid       string    `gorm:"type:varchar(100);index:idx_like_uid_recent,priority:1"`
+	VideoId   string    `gorm:"type:varchar(100);default:null;index:idx_like_video_recent,priority:1"`
 	CommentId string    `gorm:"type:varchar(100);default:null"`
 	Status    int64     `gorm:"type:int;default:1"`
 	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime;index:idx_like_uid_recent,priority:2;index:idx_like_video_recent,priority:2"`
 	DeletedAt time.Time `gorm:"type:datetime;default:null"`
 }
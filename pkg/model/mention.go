@@ -0,0 +1,56 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"west2/biz/model/video"
+)
+
+// Mention 是文本中一次已解析的 @，Offset 与 Length 以字符计，包含 @ 本身
+type Mention struct {
+	Uid      string `json:"uid"`
+	Username string `json:"username"`
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"`
+}
+
+// Mentions 以 JSON 形式存放在所属的视频或评论行中
+type Mentions []*Mention
+
+func (m Mentions) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func (m *Mentions) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("unsupported mentions value")
+	}
+	return json.Unmarshal(b, m)
+}
+
+func MentionsToResMentions(mentions Mentions) []*video.Mention {
+	var res []*video.Mention
+	for _, m := range mentions {
+		res = append(res, &video.Mention{
+			Uid:      m.Uid,
+			Username: m.Username,
+			Offset:   m.Offset,
+			Length:   m.Length,
+		})
+	}
+	return res
+}
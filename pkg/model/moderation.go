@@ -0,0 +1,65 @@
+package model
+
+import (
+	"strings"
+	"time"
+	"west2/biz/model/moderation"
+)
+
+// 视频与评论的审核状态，只有 ContentNormal 的内容会出现在公开列表中
+const (
+	ContentNormal   int64 = 0
+	ContentPending  int64 = 1
+	ContentRejected int64 = 2
+	// 因举报被隐藏或下架
+	ContentHidden int64 = 3
+)
+
+const (
+	ReviewPending  int64 = 0
+	ReviewApproved int64 = 1
+	ReviewRejected int64 = 2
+	// 内容再次修改后提交了新的审核，旧的审核记录不再处理
+	ReviewSuperseded int64 = 3
+)
+
+// ReviewItem 是待人工审核的一条内容，Content 为提交时的原文，Words 为命中的敏感词
+type ReviewItem struct {
+	Id         string    `gorm:"type:varchar(100);primaryKey"`
+	TargetType string    `gorm:"type:varchar(32);not null;index:idx_review_target"`
+	TargetId   string    `gorm:"type:varchar(100);not null;index:idx_review_target"`
+	Uid        string    `gorm:"type:varchar(100);not null"`
+	Content    string    `gorm:"type:text"`
+	Words      string    `gorm:"type:varchar(1000);default:''"`
+	Status     int64     `gorm:"type:tinyint;default:0;index:idx_review_status_created"`
+	ReviewerId string    `gorm:"type:varchar(100);default:null"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index:idx_review_status_created"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func ReviewItemToResReviewItem(r *ReviewItem) *moderation.ReviewItem {
+	var words []string
+	if r.Words != "" {
+		words = strings.Split(r.Words, ",")
+	}
+	return &moderation.ReviewItem{
+		Id:         r.Id,
+		TargetType: r.TargetType,
+		TargetId:   r.TargetId,
+		Uid:        r.Uid,
+		Content:    r.Content,
+		Words:      words,
+		Status:     r.Status,
+		ReviewerId: r.ReviewerId,
+		CreatedAt:  r.CreatedAt.Format(dateFormat),
+		UpdatedAt:  r.UpdatedAt.Format(dateFormat),
+	}
+}
+
+func ReviewItemsToResReviewItems(items []*ReviewItem) []*moderation.ReviewItem {
+	var res []*moderation.ReviewItem
+	for _, r := range items {
+		res = append(res, ReviewItemToResReviewItem(r))
+	}
+	return res
+}
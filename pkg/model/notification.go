@@ -0,0 +1,86 @@
+package model
+
+import (
+	"time"
+	"west2/biz/model/notification"
+)
+
+const (
+	NotificationLike    = "like"
+	NotificationComment = "comment"
+	NotificationReply   = "reply"
+	NotificationFollow  = "follow"
+	NotificationMention = "mention"
+
+	NotificationFollowRequest = "follow_request"
+	NotificationFollowAccept  = "follow_accept"
+
+	TargetVideo   = "video"
+	TargetComment = "comment"
+	TargetUser    = "user"
+	TargetMessage = "message"
+)
+
+// NotificationUnread 是未读通知的 UnreadBucket，已读后置为 NULL，
+// 唯一索引因此只约束每个聚合目标最多一条未读通知
+const NotificationUnread int64 = 1
+
+// Notification 是聚合后的一条通知：同一用户、同一类型、同一目标的未读事件合并为一条，
+// ActorId 为最近一次触发的用户，ActorCount 为去重后的触发人数
+type Notification struct {
+	Id           string    `gorm:"type:varchar(100);primaryKey"`
+	Uid          string    `gorm:"type:varchar(100);not null;index:idx_notification_uid_updated;uniqueIndex:idx_notification_unread,priority:1"`
+	Type         string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_notification_unread,priority:2"`
+	TargetType   string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_notification_unread,priority:3"`
+	TargetId     string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_notification_unread,priority:4"`
+	UnreadBucket *int64    `gorm:"uniqueIndex:idx_notification_unread,priority:5"`
+	ActorId      string    `gorm:"type:varchar(100);not null"`
+	ActorCount   int64     `gorm:"type:int;default:1"`
+	IsRead       bool      `gorm:"default:false"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime;index:idx_notification_uid_updated"`
+}
+
+type NotificationActor struct {
+	Id             string    `gorm:"type:varchar(100);primaryKey"`
+	NotificationId string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_notification_actor"`
+	ActorId        string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_notification_actor"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+// NotificationEvent 是业务代码发出的一次原始事件
+type NotificationEvent struct {
+	Uid        string
+	ActorId    string
+	Type       string
+	TargetType string
+	TargetId   string
+}
+
+// NotificationPush 是通过 /chat 连接推送的 TypeNotification 消息的 data
+type NotificationPush struct {
+	Notification *notification.Notification `json:"notification"`
+	UnreadCount  int64                      `json:"unreadCount"`
+}
+
+func NotificationToResNotification(n *Notification) *notification.Notification {
+	return &notification.Notification{
+		Id:         n.Id,
+		Type:       n.Type,
+		TargetType: n.TargetType,
+		TargetId:   n.TargetId,
+		ActorId:    n.ActorId,
+		ActorCount: n.ActorCount,
+		IsRead:     n.IsRead,
+		CreatedAt:  n.CreatedAt.Format(dateFormat),
+		UpdatedAt:  n.UpdatedAt.Format(dateFormat),
+	}
+}
+
+func NotificationsToResNotifications(notifications []*Notification) []*notification.Notification {
+	var res []*notification.Notification
+	for _, n := range notifications {
+		res = append(res, NotificationToResNotification(n))
+	}
+	return res
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"time"
+	"west2/biz/model/report"
+)
+
+// ReportReasons 举报理由及其权重，同一目标上所有举报的权重之和为案件的严重度
+var ReportReasons = map[string]int64{
+	"spam":           1,
+	"misinformation": 2,
+	"harassment":     3,
+	"hate":           3,
+	"sexual":         3,
+	"violence":       3,
+	"other":          1,
+}
+
+// 案件状态：严重度达到阈值时视频与评论会被自动隐藏，等待审核员处理；
+// 被隐藏或下架的内容作者可以申诉一次，申诉后由审核员重新处理
+const (
+	CaseOpen      int64 = 0
+	CaseHidden    int64 = 1
+	CaseTakenDown int64 = 2
+	CaseDismissed int64 = 3
+	CaseAppealed  int64 = 4
+)
+
+type Report struct {
+	Id         string    `gorm:"type:varchar(100);primaryKey"`
+	CaseId     string    `gorm:"type:varchar(100);not null;index"`
+	ReporterId string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_report_reporter_target"`
+	TargetType string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_report_reporter_target"`
+	TargetId   string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_report_reporter_target"`
+	Reason     string    `gorm:"type:varchar(32);not null"`
+	Detail     string    `gorm:"type:varchar(500);default:''"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// ReportCase 聚合同一目标的全部举报，OwnerId 为被举报内容的作者
+type ReportCase struct {
+	Id           string    `gorm:"type:varchar(100);primaryKey"`
+	TargetType   string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_case_target"`
+	TargetId     string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_case_target"`
+	OwnerId      string    `gorm:"type:varchar(100);not null;index"`
+	ReportCount  int64     `gorm:"type:int;default:0"`
+	Severity     int64     `gorm:"type:int;default:0;index:idx_case_status_severity"`
+	Status       int64     `gorm:"type:tinyint;default:0;index:idx_case_status_severity"`
+	Appealed     bool      `gorm:"default:false"`
+	AppealReason string    `gorm:"type:varchar(500);default:''"`
+	ReviewerId   string    `gorm:"type:varchar(100);default:null"`
+	Note         string    `gorm:"type:varchar(500);default:''"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+func ReportCaseToResReportCase(c *ReportCase) *report.Case {
+	return &report.Case{
+		Id:           c.Id,
+		TargetType:   c.TargetType,
+		TargetId:     c.TargetId,
+		OwnerId:      c.OwnerId,
+		ReportCount:  c.ReportCount,
+		Severity:     c.Severity,
+		Status:       c.Status,
+		Appealed:     c.Appealed,
+		AppealReason: c.AppealReason,
+		ReviewerId:   c.ReviewerId,
+		Note:         c.Note,
+		CreatedAt:    c.CreatedAt.Format(dateFormat),
+		UpdatedAt:    c.UpdatedAt.Format(dateFormat),
+	}
+}
+
+func ReportCasesToResReportCases(cases []*ReportCase) []*report.Case {
+	var res []*report.Case
+	for _, c := range cases {
+		res = append(res, ReportCaseToResReportCase(c))
+	}
+	return res
+}
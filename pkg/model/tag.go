@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+	"west2/biz/model/tag"
+)
+
+type Tag struct {
+	Id         string    `gorm:"type:varchar(100);primaryKey"`
+	Name       string    `gorm:"type:varchar(100);uniqueIndex;not null"`
+	VideoCount int64     `gorm:"type:int;default:0"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+type VideoTag struct {
+	Id        string    `gorm:"type:varchar(100);primaryKey"`
+	VideoId   string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_video_tag"`
+	TagId     string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_video_tag;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TagStat is a tag with the number of videos tagged in a recent window.
+type TagStat struct {
+	Tag
+	RecentCount int64
+}
+
+func TagToResTag(t *Tag) *tag.Tag {
+	videoCount := t.VideoCount
+	return &tag.Tag{
+		Id:         t.Id,
+		Name:       t.Name,
+		VideoCount: &videoCount,
+	}
+}
+
+func TagsToResTags(tags []*Tag) []*tag.Tag {
+	var tagsRes []*tag.Tag
+	for _, t := range tags {
+		tagsRes = append(tagsRes, TagToResTag(t))
+	}
+	return tagsRes
+}
+
+func TagStatsToResTags(stats []*TagStat) []*tag.Tag {
+	var tagsRes []*tag.Tag
+	for _, s := range stats {
+		res := TagToResTag(&s.Tag)
+		recentCount := s.RecentCount
+		res.RecentCount = &recentCount
+		tagsRes = append(tagsRes, res)
+	}
+	return tagsRes
+}
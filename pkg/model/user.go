@@ -1,14 +1,64 @@
 package model
 
-import "time"
+import (
+	"time"
+	"west2/biz/model/user"
+)
+
+// 谁可以 @ 该用户
+const (
+	MentionEveryone  int64 = 0
+	MentionFollowing int64 = 1
+	MentionNobody    int64 = 2
+)
+
+// 审核员需要直接在数据库中把 role 改为 RoleModerator，并删除 user:profile:<id> 缓存使其立即生效
+const (
+	RoleUser      int64 = 0
+	RoleModerator int64 = 1
+)
 
 type User struct {
-	Id        string    `gorm:"type:varchar(100);primaryKey"`
-	Username  string    `gorm:"type:varchar(100);unique;not null"`
-	Password  string    `gorm:"type:varchar(100);not null"`
-	AvatarUrl string    `gorm:"type:varchar(256)"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
-	DeletedAt time.Time `gorm:"default:null"`
-	Videos    []Video   `gorm:"foreignKey:Uid;references:Id"`
+	Id             string    `gorm:"type:varchar(100);primaryKey"`
+	Username       string    `gorm:"type:varchar(100);unique;not null"`
+	Password       string    `gorm:"type:varchar(100);not null"`
+	DisplayName    string    `gorm:"type:varchar(100);default:''"`
+	AvatarUrl      string    `gorm:"type:varchar(256)"`
+	MentionPolicy  int64     `gorm:"type:tinyint;default:0"`
+	Role           int64     `gorm:"type:tinyint;default:0"`
+	FollowerCount  int64     `gorm:"default:0"`
+	FollowingCount int64     `gorm:"default:0"`
+	IsPrivate      bool      `gorm:"default:false"` // 私密账号的视频和关注列表只对本人和已关注的用户可见
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+	DeletedAt      time.Time `gorm:"default:null"`
+	Videos         []Video   `gorm:"foreignKey:Uid;references:Id"`
+}
+
+func UserToResUser(u *User) *user.User {
+	followerCount, followingCount, mentionPolicy, isPrivate := u.FollowerCount, u.FollowingCount, u.MentionPolicy, u.IsPrivate
+	return &user.User{
+		Id:             u.Id,
+		Username:       u.Username,
+		DisplayName:    u.DisplayName,
+		AvatarUrl:      u.AvatarUrl,
+		FollowerCount:  &followerCount,
+		FollowingCount: &followingCount,
+		MentionPolicy:  &mentionPolicy,
+		IsPrivate:      &isPrivate,
+		CreatedAt:      u.CreatedAt.Format(dateFormat),
+		UpdatedAt:      u.UpdatedAt.Format(dateFormat),
+		DeletedAt:      u.DeletedAt.Format(dateFormat),
+	}
+}
+
+func UsersToResUsers(users []*User, following map[string]bool) []*user.User {
+	var usersRes []*user.User
+	for _, u := range users {
+		res := UserToResUser(u)
+		isFollowing := following[u.Id]
+		res.IsFollowing = &isFollowing
+		usersRes = append(usersRes, res)
+	}
+	return usersRes
 }
@@ -8,37 +8,62 @@ import (
 var dateFormat string = "2006-01-02T15:04:05.000Z"
 
 type Video struct {
-	Id           string    `gorm:"type:varchar(100);primaryKey"`
-	Uid          string    `gorm:"type:varchar(100)"`
-	Title        string    `gorm:"type:varchar(100);not null"`
-	Description  string    `gorm:"type:varchar(256);not null"`
-	VideoUrl     string    `gorm:"type:varchar(256);unique;not null"`
-	CoverUrl     string    `gorm:"type:varchar(256)"`
-	VisitCount   int64     `gorm:"type:int;default:0"`
-	LikeCount    int64     `gorm:"type:int;default:0"`
-	CommentCount int64     `gorm:"type:int;default:0"`
-	CreatedAt    time.Time `gorm:"autoCreateTime"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
-	DeletedAt    time.Time `gorm:"type:datetime;default:null"`
+	Id              string    `gorm:"type:varchar(100);primaryKey"`
+	Uid             string    `gorm:"type:varchar(100)"`
+	Title           string    `gorm:"type:varchar(100);not null"`
+	Description     string    `gorm:"type:varchar(256);not null"`
+	VideoUrl        string    `gorm:"type:varchar(256);unique;not null"`
+	CoverUrl        string    `gorm:"type:varchar(256)"`
+	VisitCount      int64     `gorm:"type:int;default:0"`
+	LikeCount       int64     `gorm:"type:int;default:0"`
+	CommentCount    int64     `gorm:"type:int;default:0"`
+	Duration        int64     `gorm:"type:int;default:0"`
+	PinnedCommentId string    `gorm:"type:varchar(100);default:null"`
+	Mentions        Mentions  `gorm:"type:json"`
+	Status          int64     `gorm:"type:tinyint;default:0"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime;index"`
+	DeletedAt       time.Time `gorm:"type:datetime;default:null"`
+	Liked           bool      `gorm:"-" json:"-"`
+	FollowingAuthor bool      `gorm:"-" json:"-"`
+	Author          *User     `gorm:"-" json:"-"`
 }
 
 func VideoToResVideo(v *Video) *video.Video {
 	visitCount := v.VisitCount
 	likeCount := v.LikeCount
 	commentCount := v.CommentCount
+	duration := v.Duration
 	return &video.Video{
-		Id:           v.Id,
-		Uid:          v.Uid,
-		VideoUrl:     v.VideoUrl,
-		CoverUrl:     v.CoverUrl,
-		Title:        v.Title,
-		Description:  v.Description,
-		VisitCount:   &visitCount,
-		LikeCount:    &likeCount,
-		CommentCount: &commentCount,
-		CreatedAt:    v.CreatedAt.Format(dateFormat),
-		UpdatedAt:    v.UpdatedAt.Format(dateFormat),
-		DeletedAt:    v.DeletedAt.Format(dateFormat),
+		Id:                v.Id,
+		Uid:               v.Uid,
+		VideoUrl:          v.VideoUrl,
+		CoverUrl:          v.CoverUrl,
+		Title:             v.Title,
+		Description:       v.Description,
+		VisitCount:        &visitCount,
+		LikeCount:         &likeCount,
+		CommentCount:      &commentCount,
+		Duration:          &duration,
+		CreatedAt:         v.CreatedAt.Format(dateFormat),
+		UpdatedAt:         v.UpdatedAt.Format(dateFormat),
+		DeletedAt:         v.DeletedAt.Format(dateFormat),
+		IsLiked:           v.Liked,
+		IsFollowingAuthor: v.FollowingAuthor,
+		Mentions:          MentionsToResMentions(v.Mentions),
+		Author:            UserToAuthor(v.Author),
+	}
+}
+
+func UserToAuthor(u *User) *video.Author {
+	if u == nil {
+		return nil
+	}
+	return &video.Author{
+		Id:          u.Id,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		AvatarUrl:   u.AvatarUrl,
 	}
 }
 
@@ -0,0 +1,98 @@
+package moderation
+
+import "unicode"
+
+type node struct {
+	next map[rune]int
+	fail int
+	// outputs are the rule indexes of every word ending here, including the
+	// ones reached through fail links.
+	outputs []int
+}
+
+type match struct {
+	start int
+	end   int
+	rule  int
+}
+
+// automaton is an Aho-Corasick automaton over lower-cased runes.
+// It is immutable once built, so it can be shared without locking.
+type automaton struct {
+	nodes []node
+	// lens[i] is the rune length of rule i
+	lens []int
+}
+
+func newAutomaton(rules []*Rule) *automaton {
+	a := &automaton{
+		nodes: []node{{next: make(map[rune]int)}},
+		lens:  make([]int, len(rules)),
+	}
+	for i, r := range rules {
+		word := []rune(r.Word)
+		a.lens[i] = len(word)
+		cur := 0
+		for _, c := range word {
+			c = unicode.ToLower(c)
+			nxt, ok := a.nodes[cur].next[c]
+			if !ok {
+				a.nodes = append(a.nodes, node{next: make(map[rune]int)})
+				nxt = len(a.nodes) - 1
+				a.nodes[cur].next[c] = nxt
+			}
+			cur = nxt
+		}
+		if cur != 0 {
+			a.nodes[cur].outputs = append(a.nodes[cur].outputs, i)
+		}
+	}
+
+	// BFS so that every fail target is finished before it is used
+	queue := make([]int, 0, len(a.nodes))
+	for _, nxt := range a.nodes[0].next {
+		queue = append(queue, nxt)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for c, nxt := range a.nodes[cur].next {
+			f := a.nodes[cur].fail
+			for f != 0 && !a.has(f, c) {
+				f = a.nodes[f].fail
+			}
+			if target, ok := a.nodes[f].next[c]; ok && target != nxt {
+				a.nodes[nxt].fail = target
+			}
+			fail := a.nodes[nxt].fail
+			a.nodes[nxt].outputs = append(a.nodes[nxt].outputs, a.nodes[fail].outputs...)
+			queue = append(queue, nxt)
+		}
+	}
+	return a
+}
+
+func (a *automaton) has(n int, c rune) bool {
+	_, ok := a.nodes[n].next[c]
+	return ok
+}
+
+// find returns every occurrence of every word in text; start and end are
+// rune offsets, end exclusive.
+func (a *automaton) find(text []rune) []match {
+	var matches []match
+	cur := 0
+	for i, c := range text {
+		c = unicode.ToLower(c)
+		for cur != 0 && !a.has(cur, c) {
+			cur = a.nodes[cur].fail
+		}
+		if nxt, ok := a.nodes[cur].next[c]; ok {
+			cur = nxt
+		}
+		for _, rule := range a.nodes[cur].outputs {
+			matches = append(matches, match{start: i + 1 - a.lens[rule], end: i + 1, rule: rule})
+		}
+	}
+	return matches
+}
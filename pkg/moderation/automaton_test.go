@@ -0,0 +1,74 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAutomatonFind(t *testing.T) {
+	type found struct {
+		start, end int
+		word       string
+	}
+	tests := []struct {
+		name  string
+		words []string
+		text  string
+		want  []found
+	}{
+		{
+			name: "no rules",
+			text: "anything",
+		},
+		{
+			name:  "no match",
+			words: []string{"bad"},
+			text:  "good text",
+		},
+		{
+			name:  "overlapping words",
+			words: []string{"he", "she", "his", "hers"},
+			text:  "ushers",
+			want:  []found{{1, 4, "she"}, {2, 4, "he"}, {2, 6, "hers"}},
+		},
+		{
+			name:  "case insensitive",
+			words: []string{"Bad"},
+			text:  "BAD and bad",
+			want:  []found{{0, 3, "Bad"}, {8, 11, "Bad"}},
+		},
+		{
+			name:  "cjk offsets are runes",
+			words: []string{"敏感", "感词"},
+			text:  "这是敏感词",
+			want:  []found{{2, 4, "敏感"}, {3, 5, "感词"}},
+		},
+		{
+			name:  "word inside a longer word",
+			words: []string{"abcd", "bc"},
+			text:  "xabcx",
+			want:  []found{{2, 4, "bc"}},
+		},
+		{
+			name:  "empty word is ignored",
+			words: []string{"", "a"},
+			text:  "aa",
+			want:  []found{{0, 1, "a"}, {1, 2, "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := make([]*Rule, len(tt.words))
+			for i, w := range tt.words {
+				rules[i] = &Rule{Word: w, Action: ActionMask}
+			}
+			var got []found
+			for _, m := range newAutomaton(rules).find([]rune(tt.text)) {
+				got = append(got, found{m.start, m.end, tt.words[m.rule]})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("find(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package moderation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Action is what a matched rule asks for. When several rules match, the
+// strictest one wins.
+type Action int
+
+const (
+	ActionPass Action = iota
+	ActionMask
+	ActionReview
+	ActionReject
+)
+
+var actionNames = map[string]Action{
+	"mask":   ActionMask,
+	"review": ActionReview,
+	"reject": ActionReject,
+}
+
+func ParseAction(s string) (Action, error) {
+	a, ok := actionNames[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return ActionPass, fmt.Errorf("unknown moderation action %q", s)
+	}
+	return a, nil
+}
+
+type Rule struct {
+	Word   string
+	Action Action
+}
+
+// Result of checking one text. Text has every matched word masked with '*'
+// regardless of Action, so callers can store it whenever Action is ActionMask or above.
+type Result struct {
+	Action Action
+	Text   string
+	Words  []string
+}
+
+// Engine checks text against a sensitive-word list. Reload swaps in a new
+// list atomically; checks running at the same time see the old one.
+type Engine interface {
+	Check(text string) *Result
+	Reload() (int, error)
+	Len() int
+}
+
+type Options struct {
+	// Path is a word list with one rule per line: "word" or "word,action".
+	// Blank lines and lines starting with '#' are ignored.
+	Path string
+	// DefaultAction applies to lines without an action; empty means mask.
+	DefaultAction string
+	// ReloadInterval > 0 polls Path and reloads it when its mtime changes.
+	ReloadInterval time.Duration
+}
+
+var (
+	engine     Engine
+	engineOnce sync.Once
+)
+
+func InitEngine(opts Options) error {
+	var err error
+	engineOnce.Do(func() {
+		var we *wordEngine
+		we, err = newWordEngine(opts)
+		if err != nil {
+			return
+		}
+		if opts.ReloadInterval > 0 {
+			go we.watchLoop(opts.ReloadInterval)
+		}
+		engine = we
+	})
+	return err
+}
+
+func GetEngine() Engine {
+	return engine
+}
+
+type wordList struct {
+	rules []*Rule
+	ac    *automaton
+}
+
+type wordEngine struct {
+	path          string
+	defaultAction Action
+	list          atomic.Pointer[wordList]
+	mu            sync.Mutex
+	modTime       time.Time
+}
+
+func newWordEngine(opts Options) (*wordEngine, error) {
+	we := &wordEngine{path: opts.Path, defaultAction: ActionMask}
+	if opts.DefaultAction != "" {
+		a, err := ParseAction(opts.DefaultAction)
+		if err != nil {
+			return nil, err
+		}
+		we.defaultAction = a
+	}
+	we.list.Store(&wordList{ac: newAutomaton(nil)})
+	if _, err := we.Reload(); err != nil {
+		return nil, err
+	}
+	return we, nil
+}
+
+func (we *wordEngine) Check(text string) *Result {
+	res := &Result{Action: ActionPass, Text: text}
+	list := we.list.Load()
+	if len(list.rules) == 0 || text == "" {
+		return res
+	}
+
+	runes := []rune(text)
+	matches := list.ac.find(runes)
+	if len(matches) == 0 {
+		return res
+	}
+	seen := make(map[int]bool)
+	for _, m := range matches {
+		rule := list.rules[m.rule]
+		if rule.Action > res.Action {
+			res.Action = rule.Action
+		}
+		if !seen[m.rule] {
+			seen[m.rule] = true
+			res.Words = append(res.Words, rule.Word)
+		}
+		for i := m.start; i < m.end; i++ {
+			runes[i] = '*'
+		}
+	}
+	res.Text = string(runes)
+	return res
+}
+
+// Reload rereads the word list and returns the number of rules loaded.
+// A missing file loads an empty list.
+func (we *wordEngine) Reload() (int, error) {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+
+	if we.path == "" {
+		return 0, nil
+	}
+	info, err := os.Stat(we.path)
+	if errors.Is(err, os.ErrNotExist) {
+		we.list.Store(&wordList{ac: newAutomaton(nil)})
+		we.modTime = time.Time{}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	rules, err := we.readRules()
+	if err != nil {
+		return 0, err
+	}
+	we.list.Store(&wordList{rules: rules, ac: newAutomaton(rules)})
+	we.modTime = info.ModTime()
+	return len(rules), nil
+}
+
+func (we *wordEngine) Len() int {
+	return len(we.list.Load().rules)
+}
+
+func (we *wordEngine) readRules() ([]*Rule, error) {
+	f, err := os.Open(we.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []*Rule
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, action := line, we.defaultAction
+		if i := strings.LastIndex(line, ","); i >= 0 {
+			a, err := ParseAction(line[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", we.path, n, err)
+			}
+			word, action = strings.TrimSpace(line[:i]), a
+		}
+		if word == "" {
+			continue
+		}
+		// 同一个词出现多次时取最严格的动作
+		key := strings.ToLower(word)
+		if i, ok := seen[key]; ok {
+			if action > rules[i].Action {
+				rules[i].Action = action
+			}
+			continue
+		}
+		seen[key] = len(rules)
+		rules = append(rules, &Rule{Word: word, Action: action})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (we *wordEngine) watchLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		info, err := os.Stat(we.path)
+		we.mu.Lock()
+		changed := (err == nil && !info.ModTime().Equal(we.modTime)) || (errors.Is(err, os.ErrNotExist) && !we.modTime.IsZero())
+		we.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if _, err := we.Reload(); err != nil {
+			log.Printf("failed to reload sensitive words: path: %s, err: %v", we.path, err)
+		}
+	}
+}
@@ -1,37 +1,125 @@
 package repository
 
 import (
+	"time"
 	"west2/pkg/model"
 
 	"gorm.io/gorm"
 )
 
+const (
+	CommentSortNewest = "newest"
+	CommentSortOldest = "oldest"
+	CommentSortHot    = "hot"
+)
+
+// hotScoreExpr 热度 = log10(点赞数 + 2*回复数) + 发布时间/45000，
+// 时间项让新评论每 12.5 小时相当于多出 10 倍互动，分数只随计数变化，便于游标分页
+const hotScoreExpr = "LOG10(GREATEST(like_count + 2 * child_count, 1)) + UNIX_TIMESTAMP(created_at) / 45000"
+
+// CommentCursor 上一页最后一条评论的排序键
+type CommentCursor struct {
+	CreatedAt time.Time
+	HotScore  float64
+	Id        string
+}
+
+type CommentQuery struct {
+	VideoId   string
+	ParentId  string
+	SortBy    string
+	Cursor    *CommentCursor
+	ExcludeId string
+	// ExcludeUids 中用户的评论不会被返回
+	ExcludeUids []string
+	Offset      int
+	Limit       int
+}
+
 type commentRepository struct {
 	db *gorm.DB
 }
 
 type CommentRepository interface {
 	CreateComment(comment *model.Comment) error
-	GetCommentListByVideoId(videoId string, pageNum, pageSize int64) ([]*model.Comment, error)
-	GetCommentListByCommentId(commentId string, pageNum, pageSize int64) ([]*model.Comment, error)
+	GetCommentById(id string) (*model.Comment, error)
+	ListComments(query *CommentQuery) ([]*model.Comment, error)
+	GetFirstReplies(parentIds, excludeUids []string, limit int64) ([]*model.Comment, error)
 	DeleteCommentsByVideoId(videoId string) error
-	DeleteCommentById(id string) error
+	DeleteComment(comment *model.Comment) error
+	SetPinnedComment(videoId, commentId string) error
 }
 
 func NewCommentRepository(db *gorm.DB) CommentRepository {
 	return &commentRepository{db: db}
 }
 
+// CreateComment 写入评论并在同一事务中维护父评论的 child_count 与视频的 comment_count，
+// 待审核的评论在通过审核时才计数
 func (cr *commentRepository) CreateComment(comment *model.Comment) error {
-	return cr.db.Create(comment).Error
+	return cr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		if err := refreshHotScore(tx, comment.Id); err != nil {
+			return err
+		}
+		if comment.Status != model.ContentNormal {
+			return nil
+		}
+		return addCommentCounts(tx, comment)
+	})
 }
 
-func (cr *commentRepository) GetCommentListByVideoId(videoId string, pageNum, pageSize int64) ([]*model.Comment, error) {
+func (cr *commentRepository) GetCommentById(id string) (*model.Comment, error) {
+	var comment model.Comment
+	err := cr.db.Where("id = ?", id).
+		First(&comment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (cr *commentRepository) ListComments(query *CommentQuery) ([]*model.Comment, error) {
+	tx := cr.db.Model(&model.Comment{}).
+		Where("status = ?", model.ContentNormal)
+	if query.ParentId != "" {
+		tx = tx.Where("parent_id = ?", query.ParentId)
+	} else {
+		tx = tx.Where("video_id = ?", query.VideoId).
+			Where("parent_id IS NULL")
+	}
+	if query.ExcludeId != "" {
+		tx = tx.Where("id <> ?", query.ExcludeId)
+	}
+	if len(query.ExcludeUids) > 0 {
+		tx = tx.Where("uid NOT IN ?", query.ExcludeUids)
+	}
+
+	// 排序键都以 id 兜底，保证同分时顺序稳定
+	c := query.Cursor
+	switch query.SortBy {
+	case CommentSortOldest:
+		if c != nil {
+			tx = tx.Where("created_at > ? OR (created_at = ? AND id > ?)", c.CreatedAt, c.CreatedAt, c.Id)
+		}
+		tx = tx.Order("created_at ASC, id ASC")
+	case CommentSortHot:
+		if c != nil {
+			tx = tx.Where("hot_score < ? OR (hot_score = ? AND id < ?)", c.HotScore, c.HotScore, c.Id)
+		}
+		tx = tx.Order("hot_score DESC, id DESC")
+	default:
+		if c != nil {
+			tx = tx.Where("created_at < ? OR (created_at = ? AND id < ?)", c.CreatedAt, c.CreatedAt, c.Id)
+		}
+		tx = tx.Order("created_at DESC, id DESC")
+	}
+
 	var comments []*model.Comment
-	err := cr.db.Where("video_id = ?", videoId).
-		Where("parent_id IS NULL").
-		Offset((int(pageNum) - 1) * int(pageSize)).
-		Limit(int(pageSize)).
+	err := tx.Offset(query.Offset).
+		Limit(query.Limit).
 		Find(&comments).Error
 	if err != nil {
 		return nil, err
@@ -39,11 +127,23 @@ func (cr *commentRepository) GetCommentListByVideoId(videoId string, pageNum, pa
 	return comments, nil
 }
 
-func (cr *commentRepository) GetCommentListByCommentId(commentId string, pageNum, pageSize int64) ([]*model.Comment, error) {
+// GetFirstReplies 一次查询取出每个父评论最早的 limit 条回复，跳过 excludeUids 中用户的回复
+func (cr *commentRepository) GetFirstReplies(parentIds, excludeUids []string, limit int64) ([]*model.Comment, error) {
 	var comments []*model.Comment
-	err := cr.db.Where("parent_id = ?", commentId).
-		Offset((int(pageNum) - 1) * int(pageSize)).
-		Limit(int(pageSize)).
+	if len(parentIds) == 0 || limit <= 0 {
+		return comments, nil
+	}
+
+	ranked := cr.db.Model(&model.Comment{}).
+		Select("comments.*, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY created_at ASC, id ASC) AS rn").
+		Where("parent_id IN ?", parentIds).
+		Where("status = ?", model.ContentNormal)
+	if len(excludeUids) > 0 {
+		ranked = ranked.Where("uid NOT IN ?", excludeUids)
+	}
+	err := cr.db.Table("(?) AS r", ranked).
+		Where("rn <= ?", limit).
+		Order("parent_id, created_at ASC, id ASC").
 		Find(&comments).Error
 	if err != nil {
 		return nil, err
@@ -52,10 +152,121 @@ func (cr *commentRepository) GetCommentListByCommentId(commentId string, pageNum
 }
 
 func (cr *commentRepository) DeleteCommentsByVideoId(videoId string) error {
-	return cr.db.Where("video_id = ?", videoId).
-		Delete(&model.Comment{}).Error
+	return cr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("video_id = ?", videoId).
+			Delete(&model.Comment{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.Video{}).
+			Where("id = ?", videoId).
+			Updates(map[string]interface{}{
+				"comment_count":     0,
+				"pinned_comment_id": gorm.Expr("NULL"),
+			}).Error
+	})
+}
+
+// DeleteComment 删除评论及其全部回复，并回退父评论与视频上的计数
+func (cr *commentRepository) DeleteComment(comment *model.Comment) error {
+	return cr.db.Transaction(func(tx *gorm.DB) error {
+		ids := []string{comment.Id}
+		frontier := ids
+		for len(frontier) > 0 {
+			var children []string
+			err := tx.Model(&model.Comment{}).
+				Where("parent_id IN ?", frontier).
+				Pluck("id", &children).Error
+			if err != nil {
+				return err
+			}
+			ids = append(ids, children...)
+			frontier = children
+		}
+
+		// 只有已公开的评论计入过计数
+		var visible int64
+		err := tx.Model(&model.Comment{}).
+			Where("id IN ?", ids).
+			Where("status = ?", model.ContentNormal).
+			Count(&visible).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&model.Comment{}).Error; err != nil {
+			return err
+		}
+		if comment.ParentId != "" && comment.Status == model.ContentNormal {
+			err := tx.Model(&model.Comment{}).
+				Where("id = ?", comment.ParentId).
+				Where("child_count > 0").
+				Update("child_count", gorm.Expr("child_count - 1")).Error
+			if err != nil {
+				return err
+			}
+			if err := refreshHotScore(tx, comment.ParentId); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.Video{}).
+			Where("id = ?", comment.VideoId).
+			Updates(map[string]interface{}{
+				"comment_count":     gorm.Expr("GREATEST(comment_count - ?, 0)", visible),
+				"pinned_comment_id": gorm.Expr("IF(pinned_comment_id IN ?, NULL, pinned_comment_id)", ids),
+			}).Error
+	})
+}
+
+// SetPinnedComment commentId 为空时取消置顶
+func (cr *commentRepository) SetPinnedComment(videoId, commentId string) error {
+	var value interface{} = commentId
+	if commentId == "" {
+		value = gorm.Expr("NULL")
+	}
+	return cr.db.Model(&model.Video{}).
+		Where("id = ?", videoId).
+		Update("pinned_comment_id", value).Error
+}
+
+// addCommentCounts 评论公开时给父评论的 child_count 与视频的 comment_count 加一
+func addCommentCounts(tx *gorm.DB, comment *model.Comment) error {
+	if comment.ParentId != "" {
+		err := tx.Model(&model.Comment{}).
+			Where("id = ?", comment.ParentId).
+			Update("child_count", gorm.Expr("child_count + 1")).Error
+		if err != nil {
+			return err
+		}
+		if err := refreshHotScore(tx, comment.ParentId); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&model.Video{}).
+		Where("id = ?", comment.VideoId).
+		Update("comment_count", gorm.Expr("comment_count + 1")).Error
+}
+
+// subCommentCounts 评论不再公开时回退 addCommentCounts 加上的计数
+func subCommentCounts(tx *gorm.DB, comment *model.Comment) error {
+	if comment.ParentId != "" {
+		err := tx.Model(&model.Comment{}).
+			Where("id = ?", comment.ParentId).
+			Where("child_count > 0").
+			Update("child_count", gorm.Expr("child_count - 1")).Error
+		if err != nil {
+			return err
+		}
+		if err := refreshHotScore(tx, comment.ParentId); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&model.Video{}).
+		Where("id = ?", comment.VideoId).
+		Update("comment_count", gorm.Expr("GREATEST(comment_count - 1, 0)")).Error
 }
 
-func (cr *commentRepository) DeleteCommentById(id string) error {
-	return cr.db.Delete(&model.Comment{}, id).Error
+func refreshHotScore(tx *gorm.DB, id string) error {
+	return tx.Model(&model.Comment{}).
+		Where("id = ?", id).
+		Update("hot_score", gorm.Expr(hotScoreExpr)).Error
 }
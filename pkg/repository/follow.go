@@ -1,11 +1,21 @@
 package repository
 
 import (
+	"errors"
+	"time"
 	"west2/pkg/model"
+	"west2/util"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// RelationScore 是某个候选用户在一种推荐来源中的计数
+type RelationScore struct {
+	Uid   string
+	Count int64 `gorm:"column:cnt"`
+}
+
 type followRepostory struct {
 	db *gorm.DB
 }
@@ -17,14 +27,58 @@ type FollowRepostory interface {
 	GetFollowerList(followingId string, pageNum, pageSize int64) ([]*model.Follow, int64, error)
 	GetFriendList(followerId string, pageNum, pageSize int64) ([]*model.Follow, int64, error)
 	GetFollowById(followerId, followingId string) (*model.Follow, error)
+	GetFollowingIds(followerId string, ids []string) ([]string, error)
+	GetFollowerIds(followingId string, ids []string) ([]string, error)
+	Block(blockerId, blockedId string) error
+	Unblock(blockerId, blockedId string) error
+	IsBlocked(uid, otherId string) (bool, error)
+	GetBlockedIds(uid string) ([]string, error)
+	GetBlockList(blockerId string, pageNum, pageSize int64) ([]*model.Block, int64, error)
+	Mute(muterId, mutedId string) error
+	Unmute(muterId, mutedId string) error
+	GetMutedIds(muterId string) ([]string, error)
+	GetMuteList(muterId string, pageNum, pageSize int64) ([]*model.Mute, int64, error)
+	RecountFollows() error
+	GetFollowRequestList(followingId string, pageNum, pageSize int64) ([]*model.Follow, int64, error)
+	ResolveFollowRequest(followerId, followingId string, approve bool) (bool, error)
+	ApproveFollowRequests(followingId string) error
+	GetFollowedOrRequestedIds(followerId string, ids []string) ([]string, error)
+	GetTwoHopFollows(uid string, fanout, limit int) ([]*RelationScore, error)
+	GetSharedLikeUsers(uid string, recent, fanout, limit int) ([]*RelationScore, error)
+	GetNetworkPopularAuthors(uid string, since time.Time, limit int) ([]*RelationScore, error)
 }
 
 func NewFollowRepostory(db *gorm.DB) FollowRepostory {
 	return &followRepostory{db: db}
 }
 
+// Create 并发关注时同一对用户已有记录，改为更新这条记录的状态，计数只会变化一次
 func (fr *followRepostory) Create(follow *model.Follow) error {
-	return fr.db.Create(follow).Error
+	err := fr.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(follow)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			var existing model.Follow
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("follower_id = ?", follow.FollowerId).
+				Where("following_id = ?", follow.FollowingId).
+				First(&existing).Error
+			if err != nil {
+				return err
+			}
+			return setFollowStatus(tx, &existing, follow.Status)
+		}
+		if follow.Status != model.FollowStatusActive {
+			return nil
+		}
+		return addFollowCounts(tx, follow.FollowerId, follow.FollowingId, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return delCachedUsers(follow.FollowerId, follow.FollowingId)
 }
 
 func (fr *followRepostory) GetFollowById(followerId, followingId string) (*model.Follow, error) {
@@ -39,9 +93,20 @@ func (fr *followRepostory) GetFollowById(followerId, followingId string) (*model
 }
 
 func (fr *followRepostory) SetStatus(status int64, id string) error {
-	return fr.db.Model(&model.Follow{}).
-		Where("id = ?", id).
-		Update("status", status).Error
+	var follow model.Follow
+	err := fr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).
+			First(&follow).Error
+		if err != nil {
+			return err
+		}
+		return setFollowStatus(tx, &follow, status)
+	})
+	if err != nil {
+		return err
+	}
+	return delCachedUsers(follow.FollowerId, follow.FollowingId)
 }
 
 func (fr *followRepostory) GetFollowingList(followerId string, pageNum, pageSize int64) ([]*model.Follow, int64, error) {
@@ -102,13 +167,13 @@ func (fr *followRepostory) GetFriendList(followerId string, pageNum, pageSize in
 	err := fr.db.Transaction(func(tx *gorm.DB) error {
 		tx = tx.Table("follows AS f1").
 			Joins("INNER JOIN follows AS f2 ON f1.following_id = f2.follower_id AND f2.following_id = f1.follower_id").
-			Where("f1.follower_id = ? AND f1.status = 1 AND f2.status = 1", followerId).
-			Select("f1.*")
+			Where("f1.follower_id = ? AND f1.status = 1 AND f2.status = 1", followerId)
 		err := tx.Count(&total).Error
 		if err != nil {
 			return err
 		}
-		err = tx.Offset((int(pageNum) - 1) * int(pageSize)).
+		err = tx.Select("f1.*").
+			Offset((int(pageNum) - 1) * int(pageSize)).
 			Limit(int(pageSize)).
 			Find(&follows).Error
 		if err != nil {
@@ -121,3 +186,417 @@ func (fr *followRepostory) GetFriendList(followerId string, pageNum, pageSize in
 	}
 	return follows, total, err
 }
+
+func (fr *followRepostory) GetFollowingIds(followerId string, ids []string) ([]string, error) {
+	var followingIds []string
+	if len(ids) == 0 {
+		return followingIds, nil
+	}
+	err := fr.db.Model(&model.Follow{}).
+		Where("follower_id = ?", followerId).
+		Where("following_id IN ?", ids).
+		Where("status = 1").
+		Pluck("following_id", &followingIds).Error
+	if err != nil {
+		return nil, err
+	}
+	return followingIds, nil
+}
+
+// GetFollowerIds 返回 ids 中关注了 followingId 的用户
+func (fr *followRepostory) GetFollowerIds(followingId string, ids []string) ([]string, error) {
+	var followerIds []string
+	if len(ids) == 0 {
+		return followerIds, nil
+	}
+	err := fr.db.Model(&model.Follow{}).
+		Where("following_id = ?", followingId).
+		Where("follower_id IN ?", ids).
+		Where("status = 1").
+		Pluck("follower_id", &followerIds).Error
+	if err != nil {
+		return nil, err
+	}
+	return followerIds, nil
+}
+
+// Block 屏蔽的同时取消双方之间的关注和待处理的关注申请
+func (fr *followRepostory) Block(blockerId, blockedId string) error {
+	err := fr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.Block{
+				Id:        util.GetID(),
+				BlockerId: blockerId,
+				BlockedId: blockedId,
+			}).Error
+		if err != nil {
+			return err
+		}
+		var follows []*model.Follow
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("(follower_id = ? AND following_id = ?) OR (follower_id = ? AND following_id = ?)", blockerId, blockedId, blockedId, blockerId).
+			Where("status IN ?", []int64{model.FollowStatusActive, model.FollowStatusPending}).
+			Find(&follows).Error
+		if err != nil {
+			return err
+		}
+		for _, f := range follows {
+			if err := setFollowStatus(tx, f, model.FollowStatusNone); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return delCachedUsers(blockerId, blockedId)
+}
+
+func (fr *followRepostory) Unblock(blockerId, blockedId string) error {
+	return fr.db.Where("blocker_id = ?", blockerId).
+		Where("blocked_id = ?", blockedId).
+		Delete(&model.Block{}).Error
+}
+
+// IsBlocked 任意一方屏蔽了另一方都返回 true
+func (fr *followRepostory) IsBlocked(uid, otherId string) (bool, error) {
+	return isBlocked(fr.db, uid, otherId)
+}
+
+// GetBlockedIds 返回 uid 屏蔽的以及屏蔽了 uid 的用户
+func (fr *followRepostory) GetBlockedIds(uid string) ([]string, error) {
+	var ids []string
+	err := fr.db.Raw("SELECT blocked_id FROM blocks WHERE blocker_id = ? UNION SELECT blocker_id FROM blocks WHERE blocked_id = ?", uid, uid).
+		Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (fr *followRepostory) GetBlockList(blockerId string, pageNum, pageSize int64) ([]*model.Block, int64, error) {
+	var blocks []*model.Block
+	var total int64
+	tx := fr.db.Model(&model.Block{}).
+		Where("blocker_id = ?", blockerId)
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("created_at DESC, id DESC").
+		Offset((int(pageNum) - 1) * int(pageSize)).
+		Limit(int(pageSize)).
+		Find(&blocks).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return blocks, total, nil
+}
+
+func (fr *followRepostory) Mute(muterId, mutedId string) error {
+	return fr.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.Mute{
+			Id:      util.GetID(),
+			MuterId: muterId,
+			MutedId: mutedId,
+		}).Error
+}
+
+func (fr *followRepostory) Unmute(muterId, mutedId string) error {
+	return fr.db.Where("muter_id = ?", muterId).
+		Where("muted_id = ?", mutedId).
+		Delete(&model.Mute{}).Error
+}
+
+func (fr *followRepostory) GetMutedIds(muterId string) ([]string, error) {
+	var ids []string
+	err := fr.db.Model(&model.Mute{}).
+		Where("muter_id = ?", muterId).
+		Pluck("muted_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (fr *followRepostory) GetMuteList(muterId string, pageNum, pageSize int64) ([]*model.Mute, int64, error) {
+	var mutes []*model.Mute
+	var total int64
+	tx := fr.db.Model(&model.Mute{}).
+		Where("muter_id = ?", muterId)
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("created_at DESC, id DESC").
+		Offset((int(pageNum) - 1) * int(pageSize)).
+		Limit(int(pageSize)).
+		Find(&mutes).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return mutes, total, nil
+}
+
+func isBlocked(db *gorm.DB, uid, otherId string) (bool, error) {
+	var count int64
+	err := db.Model(&model.Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", uid, otherId, otherId, uid).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func isMuted(db *gorm.DB, muterId, mutedId string) (bool, error) {
+	var count int64
+	err := db.Model(&model.Mute{}).
+		Where("muter_id = ?", muterId).
+		Where("muted_id = ?", mutedId).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (fr *followRepostory) GetFollowRequestList(followingId string, pageNum, pageSize int64) ([]*model.Follow, int64, error) {
+	var follows []*model.Follow
+	var total int64
+	tx := fr.db.Model(&model.Follow{}).
+		Where("following_id = ?", followingId).
+		Where("status = ?", model.FollowStatusPending)
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("updated_at DESC, id DESC").
+		Offset((int(pageNum) - 1) * int(pageSize)).
+		Limit(int(pageSize)).
+		Find(&follows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return follows, total, nil
+}
+
+// ResolveFollowRequest 通过或拒绝一条待处理的关注申请，申请已不是待处理状态时返回 false；
+// 双方之间有屏蔽时申请会被拒绝，同样返回 false
+func (fr *followRepostory) ResolveFollowRequest(followerId, followingId string, approve bool) (bool, error) {
+	status := model.FollowStatusNone
+	if approve {
+		status = model.FollowStatusActive
+	}
+	resolved := false
+	var follow model.Follow
+	err := fr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("follower_id = ?", followerId).
+			Where("following_id = ?", followingId).
+			Where("status = ?", model.FollowStatusPending).
+			First(&follow).Error
+		if err != nil {
+			return err
+		}
+		blocked, err := isBlocked(tx, followerId, followingId)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return setFollowStatus(tx, &follow, model.FollowStatusNone)
+		}
+		resolved = true
+		return setFollowStatus(tx, &follow, status)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !resolved {
+		return false, nil
+	}
+	if approve {
+		if err := delCachedUsers(followerId, followingId); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// ApproveFollowRequests 通过 followingId 收到的全部待处理申请，在私密账号改为公开时调用；
+// 与 followingId 之间有屏蔽的申请会被拒绝
+func (fr *followRepostory) ApproveFollowRequests(followingId string) error {
+	var approved []string
+	err := fr.db.Transaction(func(tx *gorm.DB) error {
+		var follows []*model.Follow
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("following_id = ?", followingId).
+			Where("status = ?", model.FollowStatusPending).
+			Find(&follows).Error
+		if err != nil {
+			return err
+		}
+		for _, f := range follows {
+			blocked, err := isBlocked(tx, f.FollowerId, followingId)
+			if err != nil {
+				return err
+			}
+			status := model.FollowStatusActive
+			if blocked {
+				status = model.FollowStatusNone
+			}
+			if err := setFollowStatus(tx, f, status); err != nil {
+				return err
+			}
+			if !blocked {
+				approved = append(approved, f.FollowerId)
+			}
+		}
+		return nil
+	})
+	if err != nil || len(approved) == 0 {
+		return err
+	}
+	return delCachedUsers(append(approved, followingId)...)
+}
+
+// GetFollowedOrRequestedIds 返回 ids 中 followerId 已关注或已发出关注申请的用户
+func (fr *followRepostory) GetFollowedOrRequestedIds(followerId string, ids []string) ([]string, error) {
+	var followingIds []string
+	if len(ids) == 0 {
+		return followingIds, nil
+	}
+	err := fr.db.Model(&model.Follow{}).
+		Where("follower_id = ?", followerId).
+		Where("following_id IN ?", ids).
+		Where("status IN ?", []int64{model.FollowStatusActive, model.FollowStatusPending}).
+		Pluck("following_id", &followingIds).Error
+	if err != nil {
+		return nil, err
+	}
+	return followingIds, nil
+}
+
+// GetTwoHopFollows 返回 uid 关注的人所关注的用户，Count 为其中关注了该用户的人数；
+// 只看 uid 最近关注的 fanout 个人，每人只取最近关注的 fanout 个用户
+func (fr *followRepostory) GetTwoHopFollows(uid string, fanout, limit int) ([]*RelationScore, error) {
+	var scores []*RelationScore
+	err := fr.db.Raw(`SELECT hop.following_id AS uid, COUNT(*) AS cnt FROM (
+			SELECT following_id FROM follows
+			WHERE follower_id = ? AND status = ?
+			ORDER BY updated_at DESC LIMIT ?) AS f1,
+		LATERAL (
+			SELECT f2.following_id FROM follows AS f2
+			WHERE f2.follower_id = f1.following_id AND f2.status = ? AND f2.following_id <> ?
+			ORDER BY f2.updated_at DESC LIMIT ?) AS hop
+		GROUP BY hop.following_id ORDER BY cnt DESC LIMIT ?`,
+		uid, model.FollowStatusActive, fanout, model.FollowStatusActive, uid, fanout, limit).
+		Scan(&scores).Error
+	if err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+// GetSharedLikeUsers 返回与 uid 最近点赞的 recent 个视频有共同点赞的用户，Count 为共同点赞数；
+// 每个视频只取最近点赞的 fanout 个人，热门视频不会展开全部点赞记录
+func (fr *followRepostory) GetSharedLikeUsers(uid string, recent, fanout, limit int) ([]*RelationScore, error) {
+	var scores []*RelationScore
+	err := fr.db.Raw(`SELECT shared.uid AS uid, COUNT(*) AS cnt FROM (
+			SELECT video_id FROM likes
+			WHERE uid = ? AND video_id IS NOT NULL AND (comment_id IS NULL OR comment_id = '')
+				AND status = ? AND deleted_at IS NULL
+			ORDER BY updated_at DESC LIMIT ?) AS mine,
+		LATERAL (
+			SELECT l2.uid FROM likes AS l2
+			WHERE l2.video_id = mine.video_id AND l2.uid <> ? AND (l2.comment_id IS NULL OR l2.comment_id = '')
+				AND l2.status = ? AND l2.deleted_at IS NULL
+			ORDER BY l2.updated_at DESC LIMIT ?) AS shared
+		GROUP BY shared.uid ORDER BY cnt DESC LIMIT ?`,
+		uid, model.LikeStatusLiked, recent, uid, model.LikeStatusLiked, fanout, limit).
+		Scan(&scores).Error
+	if err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+// GetNetworkPopularAuthors 返回 since 之后被 uid 关注的人点赞过视频的作者，Count 为点赞过的人数
+func (fr *followRepostory) GetNetworkPopularAuthors(uid string, since time.Time, limit int) ([]*RelationScore, error) {
+	var scores []*RelationScore
+	err := fr.db.Raw(`SELECT v.uid AS uid, COUNT(DISTINCT l.uid) AS cnt FROM follows AS f
+		INNER JOIN likes AS l ON l.uid = f.following_id
+		INNER JOIN videos AS v ON v.id = l.video_id
+		WHERE f.follower_id = ? AND f.status = ?
+			AND (l.comment_id IS NULL OR l.comment_id = '')
+			AND l.status = ? AND l.deleted_at IS NULL AND l.updated_at >= ?
+			AND v.status = ? AND v.deleted_at IS NULL AND v.uid <> ?
+		GROUP BY v.uid ORDER BY cnt DESC LIMIT ?`,
+		uid, model.FollowStatusActive, model.LikeStatusLiked, since, model.ContentNormal, uid, limit).
+		Scan(&scores).Error
+	if err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+// RecountFollows 以 follows 表为准重新计算所有用户的粉丝数和关注数，并清空用户资料缓存
+func (fr *followRepostory) RecountFollows() error {
+	err := fr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE users SET follower_count = (
+			SELECT COUNT(*) FROM follows
+			WHERE follows.following_id = users.id AND follows.status = 1)`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE users SET following_count = (
+			SELECT COUNT(*) FROM follows
+			WHERE follows.follower_id = users.id AND follows.status = 1)`).Error
+	})
+	if err != nil {
+		return err
+	}
+	return flushCachedUsers()
+}
+
+// setFollowStatus 更新关注状态，进出 FollowStatusActive 时同步双方的计数，待处理的申请不计数
+func setFollowStatus(tx *gorm.DB, follow *model.Follow, status int64) error {
+	if follow.Status == status {
+		return nil
+	}
+	err := tx.Model(&model.Follow{}).
+		Where("id = ?", follow.Id).
+		Update("status", status).Error
+	if err != nil {
+		return err
+	}
+	switch {
+	case status == model.FollowStatusActive:
+		err = addFollowCounts(tx, follow.FollowerId, follow.FollowingId, 1)
+	case follow.Status == model.FollowStatusActive:
+		err = addFollowCounts(tx, follow.FollowerId, follow.FollowingId, -1)
+	}
+	if err != nil {
+		return err
+	}
+	follow.Status = status
+	return nil
+}
+
+func addFollowCounts(tx *gorm.DB, followerId, followingId string, delta int64) error {
+	following := tx.Model(&model.User{}).Where("id = ?", followerId)
+	follower := tx.Model(&model.User{}).Where("id = ?", followingId)
+	if delta < 0 {
+		following = following.Where("following_count > 0")
+		follower = follower.Where("follower_count > 0")
+	}
+	err := following.Update("following_count", gorm.Expr("following_count + ?", delta)).Error
+	if err != nil {
+		return err
+	}
+	return follower.Update("follower_count", gorm.Expr("follower_count + ?", delta)).Error
+}
@@ -0,0 +1,333 @@
+package repository
+
+import (
+	"west2/pkg/model"
+	"west2/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type groupRepository struct {
+	db *gorm.DB
+}
+
+type GroupRepository interface {
+	CreateGroup(group *model.Group, members []*model.GroupMember) error
+	ImportGroup(group *model.Group, uids []string) error
+	GetGroupById(id string) (*model.Group, error)
+	GetGroupByInviteCode(code string) (*model.Group, error)
+	GetMember(groupId, uid string) (*model.GroupMember, error)
+	GetMembers(groupId string, pageNum, pageSize int64) ([]*model.GroupMember, int64, error)
+	GetMemberIds(groupId string) ([]string, error)
+	GetUserGroups(uid string, pageNum, pageSize int64) ([]*model.Group, int64, error)
+	AddMembers(groupId string, members []*model.GroupMember) ([]string, error)
+	RemoveMember(groupId, uid string) (bool, error)
+	KickMember(groupId, operatorId, uid, inviteCode string) (bool, error)
+	SetRole(groupId, operatorId, uid string, role int64) (bool, error)
+	TransferOwner(groupId, fromUid, toUid string) (bool, error)
+	Rename(groupId, title string) error
+}
+
+func NewGroupRepository(db *gorm.DB) GroupRepository {
+	return &groupRepository{db: db}
+}
+
+func (gr *groupRepository) CreateGroup(group *model.Group, members []*model.GroupMember) error {
+	return gr.db.Transaction(func(tx *gorm.DB) error {
+		group.MemberCount = int64(len(members))
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		return tx.Create(members).Error
+	})
+}
+
+// ImportGroup 群不存在时以 group.OwnerId 为群主创建，再把 uids 中不在群里的用户加为普通成员，重复执行结果不变
+func (gr *groupRepository) ImportGroup(group *model.Group, uids []string) error {
+	return gr.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(group)
+		if res.Error != nil {
+			return res.Error
+		}
+		created := res.RowsAffected == 1
+		added := 0
+		for _, uid := range uids {
+			role := int64(model.GroupRoleMember)
+			if created && uid == group.OwnerId {
+				role = model.GroupRoleOwner
+			}
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.GroupMember{
+				Id:      util.GetID(),
+				GroupId: group.Id,
+				Uid:     uid,
+				Role:    role,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+			added += int(res.RowsAffected)
+		}
+		if added == 0 {
+			return nil
+		}
+		return tx.Model(&model.Group{}).
+			Where("id = ?", group.Id).
+			Update("member_count", gorm.Expr("member_count + ?", added)).Error
+	})
+}
+
+func (gr *groupRepository) GetGroupById(id string) (*model.Group, error) {
+	var group model.Group
+	if err := gr.db.Where("id = ?", id).First(&group).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (gr *groupRepository) GetGroupByInviteCode(code string) (*model.Group, error) {
+	var group model.Group
+	if err := gr.db.Where("invite_code = ?", code).First(&group).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (gr *groupRepository) GetMember(groupId, uid string) (*model.GroupMember, error) {
+	var member model.GroupMember
+	err := gr.db.Where("group_id = ?", groupId).
+		Where("uid = ?", uid).
+		First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetMembers 群主和管理员排在前面，同一角色按入群时间排序
+func (gr *groupRepository) GetMembers(groupId string, pageNum, pageSize int64) ([]*model.GroupMember, int64, error) {
+	var members []*model.GroupMember
+	var total int64
+	err := gr.db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Model(&model.GroupMember{}).
+			Where("group_id = ?", groupId)
+
+		err := tx.Count(&total).Error
+		if err != nil {
+			return err
+		}
+		return tx.Order("role DESC").
+			Order("created_at").
+			Offset((int(pageNum) - 1) * int(pageSize)).
+			Limit(int(pageSize)).
+			Find(&members).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return members, total, nil
+}
+
+func (gr *groupRepository) GetMemberIds(groupId string) ([]string, error) {
+	var ids []string
+	err := gr.db.Model(&model.GroupMember{}).
+		Where("group_id = ?", groupId).
+		Pluck("uid", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetUserGroups 返回用户加入的群，Role 为该用户在群中的角色
+func (gr *groupRepository) GetUserGroups(uid string, pageNum, pageSize int64) ([]*model.Group, int64, error) {
+	var groups []*model.Group
+	var total int64
+	err := gr.db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Table("`groups`").
+			Joins("JOIN group_members ON group_members.group_id = `groups`.id").
+			Where("group_members.uid = ?", uid)
+
+		err := tx.Count(&total).Error
+		if err != nil {
+			return err
+		}
+		return tx.Select("`groups`.*, group_members.role").
+			Order("group_members.created_at DESC").
+			Offset((int(pageNum) - 1) * int(pageSize)).
+			Limit(int(pageSize)).
+			Find(&groups).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+// AddMembers 已在群中的用户会被跳过，返回实际加入的用户
+func (gr *groupRepository) AddMembers(groupId string, members []*model.GroupMember) ([]string, error) {
+	var added []string
+	err := gr.db.Transaction(func(tx *gorm.DB) error {
+		for _, m := range members {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(m)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 1 {
+				added = append(added, m.Uid)
+			}
+		}
+		if len(added) == 0 {
+			return nil
+		}
+		return tx.Model(&model.Group{}).
+			Where("id = ?", groupId).
+			Update("member_count", gorm.Expr("member_count + ?", len(added))).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// RemoveMember 用户不在群中时返回 false
+func (gr *groupRepository) RemoveMember(groupId, uid string) (bool, error) {
+	removed := false
+	err := gr.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		removed, err = removeMember(tx, groupId, uid)
+		return err
+	})
+	return removed, err
+}
+
+// KickMember 在锁住群之后重新检查角色，operatorId 的角色需要是管理员以上且高于被移出的用户，
+// 同时更换邀请码；角色不满足或用户已不在群中时返回 false
+func (gr *groupRepository) KickMember(groupId, operatorId, uid, inviteCode string) (bool, error) {
+	removed := false
+	err := gr.db.Transaction(func(tx *gorm.DB) error {
+		roles, err := lockGroupRoles(tx, groupId, operatorId, uid)
+		if err != nil {
+			return err
+		}
+		operatorRole, ok := roles[operatorId]
+		if !ok || operatorRole < model.GroupRoleAdmin {
+			return nil
+		}
+		if role, ok := roles[uid]; !ok || role >= operatorRole {
+			return nil
+		}
+		err = tx.Model(&model.Group{}).
+			Where("id = ?", groupId).
+			Update("invite_code", inviteCode).Error
+		if err != nil {
+			return err
+		}
+		removed, err = removeMember(tx, groupId, uid)
+		return err
+	})
+	return removed, err
+}
+
+// SetRole 在锁住群之后重新检查 operatorId 仍是群主、uid 仍在群中，不满足时返回 false
+func (gr *groupRepository) SetRole(groupId, operatorId, uid string, role int64) (bool, error) {
+	updated := false
+	err := gr.db.Transaction(func(tx *gorm.DB) error {
+		roles, err := lockGroupRoles(tx, groupId, operatorId, uid)
+		if err != nil {
+			return err
+		}
+		if roles[operatorId] != model.GroupRoleOwner {
+			return nil
+		}
+		if _, ok := roles[uid]; !ok {
+			return nil
+		}
+		updated = true
+		return tx.Model(&model.GroupMember{}).
+			Where("group_id = ?", groupId).
+			Where("uid = ?", uid).
+			Update("role", role).Error
+	})
+	return updated, err
+}
+
+// TransferOwner 原群主降为管理员；在锁住群之后重新检查 fromUid 仍是群主、toUid 仍在群中，不满足时返回 false
+func (gr *groupRepository) TransferOwner(groupId, fromUid, toUid string) (bool, error) {
+	transferred := false
+	err := gr.db.Transaction(func(tx *gorm.DB) error {
+		roles, err := lockGroupRoles(tx, groupId, fromUid, toUid)
+		if err != nil {
+			return err
+		}
+		if roles[fromUid] != model.GroupRoleOwner {
+			return nil
+		}
+		if _, ok := roles[toUid]; !ok {
+			return nil
+		}
+		transferred = true
+		err = tx.Model(&model.GroupMember{}).
+			Where("group_id = ?", groupId).
+			Where("uid = ?", fromUid).
+			Update("role", model.GroupRoleAdmin).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&model.GroupMember{}).
+			Where("group_id = ?", groupId).
+			Where("uid = ?", toUid).
+			Update("role", model.GroupRoleOwner).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.Group{}).
+			Where("id = ?", groupId).
+			Update("owner_id", toUid).Error
+	})
+	return transferred, err
+}
+
+// lockGroupRoles 用 SELECT ... FOR UPDATE 锁住群，使同一个群的角色变动依次执行，
+// 再返回 uids 中仍在群里的用户的角色
+func lockGroupRoles(tx *gorm.DB, groupId string, uids ...string) (map[string]int64, error) {
+	var group model.Group
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", groupId).
+		First(&group).Error
+	if err != nil {
+		return nil, err
+	}
+	var members []*model.GroupMember
+	err = tx.Where("group_id = ?", groupId).
+		Where("uid IN ?", uids).
+		Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[string]int64, len(members))
+	for _, m := range members {
+		roles[m.Uid] = m.Role
+	}
+	return roles, nil
+}
+
+func removeMember(tx *gorm.DB, groupId, uid string) (bool, error) {
+	res := tx.Where("group_id = ?", groupId).
+		Where("uid = ?", uid).
+		Delete(&model.GroupMember{})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	err := tx.Model(&model.Group{}).
+		Where("id = ?", groupId).
+		Where("member_count > 0").
+		Update("member_count", gorm.Expr("member_count - 1")).Error
+	return err == nil, err
+}
+
+func (gr *groupRepository) Rename(groupId, title string) error {
+	return gr.db.Model(&model.Group{}).
+		Where("id = ?", groupId).
+		Update("title", title).Error
+}
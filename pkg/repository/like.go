@@ -1,36 +1,48 @@
 package repository
 
 import (
+	"errors"
+	"time"
 	"west2/pkg/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// videoLikeCond 视频点赞的 comment_id 为空；评论点赞可能同时带有 video_id，按视频查询时需要排除
+const videoLikeCond = "comment_id IS NULL OR comment_id = ''"
+
+// LikeCursor 点赞列表上一页最后一条点赞记录的排序键
+type LikeCursor struct {
+	UpdatedAt time.Time
+	Id        string
+}
+
 type likeRepository struct {
 	db *gorm.DB
 }
 
 type LikeRepository interface {
-	CreateLike(like *model.Like) error
 	GetLike(commentId, videoId, uid string) (*model.Like, error)
-	SetLikeStatus(id string, status int64) error
-	GetVideoLikeList(uid string, pageNum, pageSize int64) ([]*string, error)
+	ApplyLike(like *model.Like) (bool, error)
+	GetLikerIds(commentId, videoId string) ([]string, error)
+	GetVideoLikeList(uid string, cursor *LikeCursor, offset, limit int) ([]*model.Like, error)
+	CountVideoLikes(uid string) (int64, error)
+	GetLikedVideoIds(uid string, videoIds []string) (map[string]bool, error)
+	GetLikedCommentIds(uid string, commentIds []string) (map[string]bool, error)
+	RecountLikes() error
 }
 
 func NewLikeReposirty(db *gorm.DB) LikeRepository {
 	return &likeRepository{db: db}
 }
 
-func (lr *likeRepository) CreateLike(like *model.Like) error {
-	return lr.db.Create(like).Error
-}
-
 func (lr *likeRepository) GetLike(commentId, videoId, uid string) (*model.Like, error) {
 	tx := lr.db.Where("uid = ?", uid).
 		Where("deleted_at IS NULL")
 
 	if commentId == "" {
-		tx = tx.Where("video_id = ?", videoId)
+		tx = tx.Where("video_id = ?", videoId).Where(videoLikeCond)
 	} else {
 		tx = tx.Where("comment_id = ?", commentId)
 	}
@@ -43,27 +55,195 @@ func (lr *likeRepository) GetLike(commentId, videoId, uid string) (*model.Like,
 	return &like, nil
 }
 
-func (lr *likeRepository) SetLikeStatus(id string, status int64) error {
+// ApplyLike 把点赞状态写入 MySQL，只有状态真正改变时才调整计数，重复执行结果不变
+func (lr *likeRepository) ApplyLike(like *model.Like) (bool, error) {
+	changed := false
+	err := lr.db.Transaction(func(tx *gorm.DB) error {
+		q := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uid = ?", like.Uid).
+			Where("deleted_at IS NULL")
+		if like.CommentId != "" {
+			q = q.Where("comment_id = ?", like.CommentId)
+		} else {
+			q = q.Where("video_id = ?", like.VideoId).Where(videoLikeCond)
+		}
+
+		liked := like.Status == model.LikeStatusLiked
+		var l model.Like
+		err := q.First(&l).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !liked {
+				return nil
+			}
+			if err := tx.Create(like).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case (l.Status == model.LikeStatusLiked) == liked:
+			return nil
+		default:
+			err := tx.Model(&model.Like{}).
+				Where("id = ?", l.Id).
+				Update("status", like.Status).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		changed = true
+		delta := -1
+		if liked {
+			delta = 1
+		}
+		if like.CommentId != "" {
+			err := tx.Model(&model.Comment{}).
+				Where("id = ?", like.CommentId).
+				Update("like_count", gorm.Expr("GREATEST(like_count + ?, 0)", delta)).Error
+			if err != nil {
+				return err
+			}
+			return refreshHotScore(tx, like.CommentId)
+		}
+		return tx.Model(&model.Video{}).
+			Where("id = ?", like.VideoId).
+			Update("like_count", gorm.Expr("GREATEST(like_count + ?, 0)", delta)).Error
+	})
+	return changed, err
+}
+
+func (lr *likeRepository) GetLikerIds(commentId, videoId string) ([]string, error) {
+	tx := lr.db.Model(&model.Like{}).
+		Where("status = ?", model.LikeStatusLiked).
+		Where("deleted_at IS NULL")
+	if commentId != "" {
+		tx = tx.Where("comment_id = ?", commentId)
+	} else {
+		tx = tx.Where("video_id = ?", videoId).Where(videoLikeCond)
+	}
+
+	var uids []string
+	if err := tx.Pluck("uid", &uids).Error; err != nil {
+		return nil, err
+	}
+	return uids, nil
+}
+
+// GetVideoLikeList 按最近一次点赞时间倒序返回用户点赞的视频，已删除的视频不计入
+func (lr *likeRepository) GetVideoLikeList(uid string, cursor *LikeCursor, offset, limit int) ([]*model.Like, error) {
+	tx := lr.videoLikes(uid)
+	if cursor != nil {
+		tx = tx.Where("likes.updated_at < ? OR (likes.updated_at = ? AND likes.id < ?)", cursor.UpdatedAt, cursor.UpdatedAt, cursor.Id)
+	}
+
+	var likes []*model.Like
+	err := tx.Select("likes.*").
+		Order("likes.updated_at DESC, likes.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&likes).Error
+	if err != nil {
+		return nil, err
+	}
+	return likes, nil
+}
+
+func (lr *likeRepository) CountVideoLikes(uid string) (int64, error) {
+	var total int64
+	err := lr.videoLikes(uid).Count(&total).Error
+	return total, err
+}
+
+func (lr *likeRepository) videoLikes(uid string) *gorm.DB {
 	return lr.db.Model(&model.Like{}).
-		Where("id = ?", id).
+		Joins("JOIN videos ON videos.id = likes.video_id AND videos.deleted_at IS NULL AND videos.status = ?", model.ContentNormal).
+		Where("likes.uid = ?", uid).
+		Where("likes.comment_id IS NULL OR likes.comment_id = ''").
+		Where("likes.status = ?", model.LikeStatusLiked).
+		Where("likes.deleted_at IS NULL")
+}
+
+func (lr *likeRepository) GetLikedVideoIds(uid string, videoIds []string) (map[string]bool, error) {
+	liked := make(map[string]bool)
+	if uid == "" || len(videoIds) == 0 {
+		return liked, nil
+	}
+
+	var ids []string
+	err := lr.db.Model(&model.Like{}).
+		Where("uid = ?", uid).
+		Where("video_id IN ?", videoIds).
+		Where(videoLikeCond).
+		Where("status = 1").
 		Where("deleted_at IS NULL").
-		Update("status", status).Error
+		Pluck("video_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		liked[id] = true
+	}
+	return overlayPendingLikes(liked, LikeTargetVideo, uid, videoIds), nil
 }
 
-func (lr *likeRepository) GetVideoLikeList(uid string, pageNum, pageSize int64) ([]*string, error) {
-	var videoIds []*string
+func (lr *likeRepository) GetLikedCommentIds(uid string, commentIds []string) (map[string]bool, error) {
+	liked := make(map[string]bool)
+	if uid == "" || len(commentIds) == 0 {
+		return liked, nil
+	}
+
+	var ids []string
 	err := lr.db.Model(&model.Like{}).
-		Select("video_id").
 		Where("uid = ?", uid).
-		Where("video_id IS NOT NULL").
+		Where("comment_id IN ?", commentIds).
 		Where("status = 1").
 		Where("deleted_at IS NULL").
-		Offset((int(pageNum) - 1) * int(pageSize)).
-		Limit(int(pageSize)).
-		Find(&videoIds).Error
+		Pluck("comment_id", &ids).Error
 	if err != nil {
 		return nil, err
 	}
+	for _, id := range ids {
+		liked[id] = true
+	}
+	return overlayPendingLikes(liked, LikeTargetComment, uid, commentIds), nil
+}
+
+// overlayPendingLikes 点赞先写入 redis 再定时写回 MySQL，用还没写回的变更覆盖 MySQL 中的状态；
+// redis 不可用时只返回 MySQL 中的状态
+func overlayPendingLikes(liked map[string]bool, target, uid string, targetIds []string) map[string]bool {
+	pending, err := pendingLikes(target, uid, targetIds)
+	if err != nil {
+		return liked
+	}
+	for id, l := range pending {
+		if l {
+			liked[id] = true
+		} else {
+			delete(liked, id)
+		}
+	}
+	return liked
+}
 
-	return videoIds, nil
+// RecountLikes 以 likes 表为准重新计算视频和评论的点赞数
+func (lr *likeRepository) RecountLikes() error {
+	return lr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE videos SET like_count = (
+			SELECT COUNT(*) FROM likes
+			WHERE likes.video_id = videos.id AND (likes.comment_id IS NULL OR likes.comment_id = '')
+				AND likes.status = ? AND likes.deleted_at IS NULL)`,
+			model.LikeStatusLiked).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`UPDATE comments SET like_count = (
+			SELECT COUNT(*) FROM likes
+			WHERE likes.comment_id = comments.id AND likes.status = ? AND likes.deleted_at IS NULL)`,
+			model.LikeStatusLiked).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE comments SET hot_score = " + hotScoreExpr).Error
+	})
 }
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+	"west2/database"
+	"west2/pkg/model"
+
+	"github.com/google/uuid"
+)
+
+const (
+	LikeTargetVideo   = "video"
+	LikeTargetComment = "comment"
+
+	likeSetKey        = "like:"
+	likeDirtyKey      = "like:dirty"
+	likeProcessingKey = "like:dirty:processing"
+	likeFlushLockKey  = "like:flush:lock"
+	// 持久化锁的租期，持有者崩溃后锁过期，处理中的变更由下一个实例重新处理
+	likeFlushLease = 5 * time.Minute
+	// 集合中的占位成员，保证点赞集合在没有成员时也存在，从而区分“未加载”与“无人点赞”
+	likeSentinel = "-"
+)
+
+// 点赞集合不存在时返回 -1，由调用方从 MySQL 加载后重试；
+// 只有集合真的发生变化时才写入待持久化的哈希，保证重复点赞或取消不会改变计数
+const setLikeScript = `
+	if redis.call("EXISTS", KEYS[1]) == 0 then
+		return -1
+	end
+	local changed
+	if ARGV[2] == "1" then
+		changed = redis.call("SADD", KEYS[1], ARGV[1])
+	else
+		changed = redis.call("SREM", KEYS[1], ARGV[1])
+	end
+	if changed == 1 then
+		redis.call("HSET", KEYS[2], ARGV[3], ARGV[2])
+	end
+	return changed
+`
+
+const loadLikesScript = `
+	if redis.call("EXISTS", KEYS[1]) == 1 then
+		return 0
+	end
+	for i = 1, #ARGV, 1000 do
+		redis.call("SADD", KEYS[1], unpack(ARGV, i, math.min(i + 999, #ARGV)))
+	end
+	return 1
+`
+
+// 先获取持久化锁，同一时间只有一个实例处理变更；拿不到锁或没有变更时返回空。
+// 把待持久化的变更整体改名为处理中，上一轮未确认的变更会先被重新处理
+const pullLikeChangesScript = `
+	if not redis.call("SET", KEYS[3], ARGV[1], "NX", "PX", ARGV[2]) then
+		return {}
+	end
+	if redis.call("EXISTS", KEYS[2]) == 0 then
+		if redis.call("EXISTS", KEYS[1]) == 0 then
+			redis.call("DEL", KEYS[3])
+			return {}
+		end
+		redis.call("RENAME", KEYS[1], KEYS[2])
+	end
+	return redis.call("HGETALL", KEYS[2])
+`
+
+// 锁已过期并被其他实例获取时不删除处理中的变更，由新的持有者重新处理
+const ackLikeChangesScript = `
+	if redis.call("GET", KEYS[2]) ~= ARGV[1] then
+		return 0
+	end
+	redis.call("DEL", KEYS[1], KEYS[2])
+	return 1
+`
+
+const releaseLikeLockScript = `
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`
+
+// 依次在待持久化和处理中的变更里查找，返回每个字段最新的状态，没有变更时为空字符串
+const getPendingLikesScript = `
+	local res = {}
+	for i, field in ipairs(ARGV) do
+		local v = redis.call("HGET", KEYS[1], field)
+		if not v then
+			v = redis.call("HGET", KEYS[2], field)
+		end
+		res[i] = v or ""
+	end
+	return res
+`
+
+type LikeChange struct {
+	Target   string
+	TargetId string
+	Uid      string
+	Status   int64
+}
+
+type likeCacheRepository struct{}
+
+type LikeCacheRepository interface {
+	SetLike(target, targetId, uid string, liked bool) (changed bool, loaded bool, err error)
+	LoadLikes(target, targetId string, uids []string) error
+	PullChanges() ([]*LikeChange, string, error)
+	AckChanges(token string) error
+	ReleaseChanges(token string) error
+}
+
+func NewLikeCacheRepository() LikeCacheRepository {
+	return &likeCacheRepository{}
+}
+
+func (lc *likeCacheRepository) SetLike(target, targetId, uid string, liked bool) (bool, bool, error) {
+	status := "0"
+	if liked {
+		status = "1"
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	res, err := instance.Eval(ctx, setLikeScript,
+		[]string{likeSetKey + target + ":" + targetId, likeDirtyKey},
+		[]interface{}{uid, status, target + ":" + targetId + ":" + uid})
+	if err != nil {
+		return false, false, err
+	}
+	n, _ := res.(int64)
+	return n == 1, n != -1, nil
+}
+
+func (lc *likeCacheRepository) LoadLikes(target, targetId string, uids []string) error {
+	args := make([]interface{}, 0, len(uids)+1)
+	args = append(args, likeSentinel)
+	for _, uid := range uids {
+		args = append(args, uid)
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	_, err := instance.Eval(ctx, loadLikesScript, []string{likeSetKey + target + ":" + targetId}, args)
+	return err
+}
+
+// PullChanges 返回待持久化的变更以及本轮持有的锁，其他实例正在处理或没有变更时锁为空；
+// 写回 MySQL 后用 AckChanges 确认，失败时用 ReleaseChanges 释放锁
+func (lc *likeCacheRepository) PullChanges() ([]*LikeChange, string, error) {
+	token := uuid.NewString()
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	res, err := instance.Eval(ctx, pullLikeChangesScript,
+		[]string{likeDirtyKey, likeProcessingKey, likeFlushLockKey},
+		[]interface{}{token, likeFlushLease.Milliseconds()})
+	if err != nil {
+		return nil, "", err
+	}
+
+	values, _ := res.([]interface{})
+	if len(values) == 0 {
+		return nil, "", nil
+	}
+	changes := make([]*LikeChange, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		field, _ := values[i].(string)
+		parts := strings.SplitN(field, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		status := model.LikeStatusCanceled
+		if values[i+1] == "1" {
+			status = model.LikeStatusLiked
+		}
+		changes = append(changes, &LikeChange{
+			Target:   parts[0],
+			TargetId: parts[1],
+			Uid:      parts[2],
+			Status:   status,
+		})
+	}
+	return changes, token, nil
+}
+
+// pendingLikes 返回 uid 对 targetIds 中还没有写回 MySQL 的点赞状态，没有变更的 id 不在结果中
+func pendingLikes(target, uid string, targetIds []string) (map[string]bool, error) {
+	fields := make([]interface{}, len(targetIds))
+	for i, id := range targetIds {
+		fields[i] = target + ":" + id + ":" + uid
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	res, err := instance.Eval(ctx, getPendingLikesScript, []string{likeDirtyKey, likeProcessingKey}, fields)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := res.([]interface{})
+	pending := make(map[string]bool)
+	for i, v := range values {
+		if i >= len(targetIds) {
+			break
+		}
+		switch v {
+		case "1":
+			pending[targetIds[i]] = true
+		case "0":
+			pending[targetIds[i]] = false
+		}
+	}
+	return pending, nil
+}
+
+func (lc *likeCacheRepository) AckChanges(token string) error {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	_, err := instance.Eval(ctx, ackLikeChangesScript, []string{likeProcessingKey, likeFlushLockKey}, []interface{}{token})
+	return err
+}
+
+func (lc *likeCacheRepository) ReleaseChanges(token string) error {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	_, err := instance.Eval(ctx, releaseLikeLockScript, []string{likeFlushLockKey}, []interface{}{token})
+	return err
+}
@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"time"
+	"west2/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const messageImportBatch = 500
+
+type messageRepository struct {
+	db *gorm.DB
+}
+
+type MessageRepository interface {
+	CreateMessage(msg *model.Message, conv *model.Conversation) error
+	GetMessages(conversationId string, before *model.Message, limit int64) ([]*model.Message, error)
+	GetMessageById(id string) (*model.Message, error)
+	GetMessagesByIds(ids []string) ([]*model.Message, error)
+	GetUnreadMessages(conversationId, uid string) ([]*model.Message, error)
+	ImportMessages(msgs []*model.Message, convs []*model.Conversation, reads []*model.ConversationRead) error
+	GetPrivateConversations(uid string, pageNum, pageSize int64) ([]*model.Conversation, int64, error)
+	CountUnread(uid string, conversationIds []string) (map[string]int64, error)
+	MarkConversation(read *model.ConversationRead) (bool, error)
+	GetConversationReads(conversationId string, uids []string) (map[string]*model.ConversationRead, error)
+	RecallMessage(id string, at time.Time) (bool, error)
+	AddReaction(reaction *model.MessageReaction) (bool, error)
+	RemoveReaction(messageId, uid, emoji string) (bool, error)
+	GetReactions(messageIds []string) ([]*model.MessageReaction, error)
+}
+
+func NewMessageRepository(db *gorm.DB) MessageRepository {
+	return &messageRepository{db: db}
+}
+
+// CreateMessage 在同一事务中写入消息并更新所属会话的最后一条消息
+func (mr *messageRepository) CreateMessage(msg *model.Message, conv *model.Conversation) error {
+	return mr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(msg).Error; err != nil {
+			return err
+		}
+		return upsertConversation(tx, conv)
+	})
+}
+
+// GetMessages 按时间从新到旧返回会话中早于 before 的消息，before 为 nil 时从最新一条开始
+func (mr *messageRepository) GetMessages(conversationId string, before *model.Message, limit int64) ([]*model.Message, error) {
+	var msgs []*model.Message
+	tx := mr.db.Where("conversation_id = ?", conversationId)
+	if before != nil {
+		tx = tx.Where("(created_at < ?) OR (created_at = ? AND id < ?)", before.CreatedAt, before.CreatedAt, before.Id)
+	}
+	err := tx.Order("created_at DESC").
+		Order("id DESC").
+		Limit(int(limit)).
+		Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (mr *messageRepository) GetMessageById(id string) (*model.Message, error) {
+	var msg model.Message
+	if err := mr.db.Where("id = ?", id).First(&msg).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (mr *messageRepository) GetMessagesByIds(ids []string) ([]*model.Message, error) {
+	var msgs []*model.Message
+	if len(ids) == 0 {
+		return msgs, nil
+	}
+	if err := mr.db.Where("id IN ?", ids).Find(&msgs).Error; err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// GetUnreadMessages 按时间从新到旧返回会话中别人发送的、在 uid 已读位置之后的消息
+func (mr *messageRepository) GetUnreadMessages(conversationId, uid string) ([]*model.Message, error) {
+	var msgs []*model.Message
+	err := unreadMessages(mr.db, uid).
+		Where("messages.conversation_id = ?", conversationId).
+		Order("messages.created_at DESC").
+		Order("messages.id DESC").
+		Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// ImportMessages 导入已有的消息，已存在的消息会被跳过，可以重复执行
+func (mr *messageRepository) ImportMessages(msgs []*model.Message, convs []*model.Conversation, reads []*model.ConversationRead) error {
+	return mr.db.Transaction(func(tx *gorm.DB) error {
+		if len(msgs) > 0 {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				CreateInBatches(msgs, messageImportBatch).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, conv := range convs {
+			if err := upsertConversation(tx, conv); err != nil {
+				return err
+			}
+		}
+		for _, read := range reads {
+			if err := upsertConversationRead(tx, read).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPrivateConversations 按最后一条消息的时间从新到旧返回用户参与的私聊会话
+func (mr *messageRepository) GetPrivateConversations(uid string, pageNum, pageSize int64) ([]*model.Conversation, int64, error) {
+	var convs []*model.Conversation
+	var total int64
+	err := mr.db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Model(&model.Conversation{}).
+			Where("type = ?", model.ConversationPrivate).
+			Where("user_a = ? OR user_b = ?", uid, uid)
+
+		err := tx.Count(&total).Error
+		if err != nil {
+			return err
+		}
+		return tx.Order("last_message_at DESC").
+			Order("id DESC").
+			Offset((int(pageNum) - 1) * int(pageSize)).
+			Limit(int(pageSize)).
+			Find(&convs).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return convs, total, nil
+}
+
+// CountUnread 返回 uid 在每个会话中的未读消息数，没有未读的会话不在结果中
+func (mr *messageRepository) CountUnread(uid string, conversationIds []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(conversationIds))
+	if len(conversationIds) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		ConversationId string
+		Cnt            int64
+	}
+	err := unreadMessages(mr.db, uid).
+		Select("messages.conversation_id, COUNT(*) AS cnt").
+		Where("messages.conversation_id IN ?", conversationIds).
+		Group("messages.conversation_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		counts[r.ConversationId] = r.Cnt
+	}
+	return counts, nil
+}
+
+// MarkConversation 把用户在会话中的送达或已读位置向后移动，位置只会前进；
+// 返回位置是否发生了变化
+func (mr *messageRepository) MarkConversation(read *model.ConversationRead) (bool, error) {
+	res := upsertConversationRead(mr.db, read)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (mr *messageRepository) GetConversationReads(conversationId string, uids []string) (map[string]*model.ConversationRead, error) {
+	var reads []*model.ConversationRead
+	err := mr.db.Where("conversation_id = ?", conversationId).
+		Where("uid IN ?", uids).
+		Find(&reads).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*model.ConversationRead, len(reads))
+	for _, r := range reads {
+		res[r.Uid] = r
+	}
+	return res, nil
+}
+
+// RecallMessage 消息已被撤回时返回 false
+func (mr *messageRepository) RecallMessage(id string, at time.Time) (bool, error) {
+	res := mr.db.Model(&model.Message{}).
+		Where("id = ?", id).
+		Where("recalled_at IS NULL").
+		Update("recalled_at", at)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// AddReaction 已添加过同一个表情时返回 false
+func (mr *messageRepository) AddReaction(reaction *model.MessageReaction) (bool, error) {
+	res := mr.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// RemoveReaction 没有添加过该表情时返回 false
+func (mr *messageRepository) RemoveReaction(messageId, uid, emoji string) (bool, error) {
+	res := mr.db.Where("message_id = ?", messageId).
+		Where("uid = ?", uid).
+		Where("emoji = ?", emoji).
+		Delete(&model.MessageReaction{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// GetReactions 按添加时间从早到晚返回
+func (mr *messageRepository) GetReactions(messageIds []string) ([]*model.MessageReaction, error) {
+	var reactions []*model.MessageReaction
+	if len(messageIds) == 0 {
+		return reactions, nil
+	}
+	err := mr.db.Where("message_id IN ?", messageIds).
+		Order("created_at").
+		Find(&reactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+// unreadMessages 筛选别人发送的、晚于 uid 在所属会话中已读位置的消息
+func unreadMessages(db *gorm.DB, uid string) *gorm.DB {
+	return db.Model(&model.Message{}).
+		Joins("LEFT JOIN conversation_reads ON conversation_reads.conversation_id = messages.conversation_id AND conversation_reads.uid = ?", uid).
+		Where("messages.sender_id <> ?", uid).
+		Where("conversation_reads.read_at IS NULL OR messages.created_at > conversation_reads.read_at OR " +
+			"(messages.created_at = conversation_reads.read_at AND messages.id > conversation_reads.read_message_id)")
+}
+
+// upsertConversationRead 新位置按 (时间, id) 更靠后时才覆盖，消息 id 必须在对应时间之前赋值，
+// 时间相同时时间列的值不变，id 先被更新不影响结果；没有变化时不更新任何列，影响行数为 0
+func upsertConversationRead(tx *gorm.DB, read *model.ConversationRead) *gorm.DB {
+	advance := func(id, at string) clause.Set {
+		cond := at + " IS NULL OR VALUES(" + at + ") > " + at +
+			" OR (VALUES(" + at + ") = " + at + " AND VALUES(" + id + ") > " + id + ")"
+		return clause.Set{
+			{Column: clause.Column{Name: id}, Value: gorm.Expr("IF(" + cond + ", VALUES(" + id + "), " + id + ")")},
+			{Column: clause.Column{Name: at}, Value: gorm.Expr("IF(" + cond + ", VALUES(" + at + "), " + at + ")")},
+		}
+	}
+	set := append(advance("delivered_message_id", "delivered_at"), advance("read_message_id", "read_at")...)
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation_id"}, {Name: "uid"}},
+		DoUpdates: set,
+	}).Create(read)
+}
+
+// upsertConversation 只在新消息更晚时覆盖最后一条消息，
+// MySQL 按顺序执行赋值，last_message_id 必须在 last_message_at 之前更新
+func upsertConversation(tx *gorm.DB, conv *model.Conversation) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.Set{
+			{
+				Column: clause.Column{Name: "last_message_id"},
+				Value:  gorm.Expr("IF(VALUES(last_message_at) >= last_message_at, VALUES(last_message_id), last_message_id)"),
+			},
+			{
+				Column: clause.Column{Name: "last_message_at"},
+				Value:  gorm.Expr("GREATEST(VALUES(last_message_at), last_message_at)"),
+			},
+			{
+				Column: clause.Column{Name: "updated_at"},
+				Value:  gorm.Expr("VALUES(updated_at)"),
+			},
+		},
+	}).Create(conv).Error
+}
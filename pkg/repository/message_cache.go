@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"west2/database"
+	"west2/pkg/model"
+)
+
+const (
+	// ChatWindowSize 每个会话在 redis 中缓存的最近消息条数
+	ChatWindowSize = 200
+
+	chatWindowKey = "chat:window:"
+	chatWindowTTL = 7 * 24 * time.Hour
+	// 分数为 0 的占位成员，区分“未加载”与“会话没有消息”
+	chatWindowSentinel = "-"
+
+	legacyPrivateKey      = "message:all:"
+	legacyGroupKey        = "group:"
+	legacyGroupMessageKey = "group:message:"
+)
+
+// 窗口未加载时同样写入，避免与并发的加载交错时丢失消息；没有占位成员的窗口不会被当作已加载。
+// 写入后只保留占位成员和最新的 ARGV[3] 条消息
+const appendChatWindowScript = `
+	redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+	redis.call("ZREMRANGEBYRANK", KEYS[1], 1, -tonumber(ARGV[3]) - 2)
+	redis.call("EXPIRE", KEYS[1], ARGV[4])
+	return 1
+`
+
+// 与已有成员合并而不是覆盖，加载期间追加的消息不会被较旧的快照冲掉；
+// ARGV 为 ttl 秒数、窗口大小、占位成员，之后依次排列 score、member
+const loadChatWindowScript = `
+	redis.call("ZADD", KEYS[1], 0, ARGV[3])
+	for i = 4, #ARGV, 2 do
+		redis.call("ZADD", KEYS[1], ARGV[i], ARGV[i + 1])
+	end
+	redis.call("ZREMRANGEBYRANK", KEYS[1], 1, -tonumber(ARGV[2]) - 2)
+	redis.call("EXPIRE", KEYS[1], ARGV[1])
+	return 1
+`
+
+type messageCacheRepository struct{}
+
+type MessageCacheRepository interface {
+	GetWindow(conversationId string) ([]*model.Message, bool, error)
+	LoadWindow(conversationId string, msgs []*model.Message) error
+	AppendWindow(msg *model.Message) error
+	ClearWindow(conversationId string) error
+	ScanLegacyMessages(fn func(msgs []*model.Message) error) error
+}
+
+func NewMessageCacheRepository() MessageCacheRepository {
+	return &messageCacheRepository{}
+}
+
+// GetWindow 按时间从新到旧返回缓存的消息，第二个返回值表示窗口是否已加载（存在占位成员）；
+// 同一条消息从 MySQL 加载和追加时序列化结果可能不同，按 id 去重
+func (mc *messageCacheRepository) GetWindow(conversationId string) ([]*model.Message, bool, error) {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	members, err := instance.ZRevRange(ctx, chatWindowKey+conversationId, 0, -1)
+	if err != nil {
+		return nil, false, err
+	}
+	loaded := false
+	seen := make(map[string]bool, len(members))
+	msgs := make([]*model.Message, 0, len(members))
+	for _, m := range members {
+		if m == chatWindowSentinel {
+			loaded = true
+			continue
+		}
+		var msg model.Message
+		if err := json.Unmarshal([]byte(m), &msg); err != nil {
+			return nil, false, err
+		}
+		if seen[msg.Id] {
+			continue
+		}
+		seen[msg.Id] = true
+		msgs = append(msgs, &msg)
+	}
+	if !loaded {
+		return nil, false, nil
+	}
+	return msgs, true, nil
+}
+
+// LoadWindow msgs 为从 MySQL 读出的最新消息，与窗口中已有的消息合并
+func (mc *messageCacheRepository) LoadWindow(conversationId string, msgs []*model.Message) error {
+	args := make([]interface{}, 0, 2*len(msgs)+3)
+	args = append(args, int64(chatWindowTTL/time.Second), ChatWindowSize, chatWindowSentinel)
+	for _, msg := range msgs {
+		member, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		args = append(args, msg.CreatedAt.UnixMilli(), member)
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	_, err := instance.Eval(ctx, loadChatWindowScript, []string{chatWindowKey + conversationId}, args)
+	return err
+}
+
+func (mc *messageCacheRepository) AppendWindow(msg *model.Message) error {
+	member, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	_, err = instance.Eval(ctx, appendChatWindowScript,
+		[]string{chatWindowKey + msg.ConversationId},
+		[]interface{}{msg.CreatedAt.UnixMilli(), member, ChatWindowSize, int64(chatWindowTTL / time.Second)})
+	return err
+}
+
+func (mc *messageCacheRepository) ClearWindow(conversationId string) error {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.Del(ctx, []string{chatWindowKey + conversationId})
+}
+
+// ScanLegacyMessages 遍历旧版本写入 redis 的私聊（message:all:<from>:<to>）和群聊（group:<gid>）消息，
+// 每个有序集合中的消息调用一次 fn；旧的键不会被删除
+func (mc *messageCacheRepository) ScanLegacyMessages(fn func(msgs []*model.Message) error) error {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+
+	privateKeys, err := instance.ScanKeys(ctx, legacyPrivateKey+"*")
+	if err != nil {
+		return err
+	}
+	for _, key := range privateKeys {
+		msgs, err := readLegacyMessages(ctx, key, "")
+		if err != nil {
+			return err
+		}
+		if err := fn(msgs); err != nil {
+			return err
+		}
+	}
+
+	groupKeys, err := instance.ScanKeys(ctx, legacyGroupKey+"*")
+	if err != nil {
+		return err
+	}
+	for _, key := range groupKeys {
+		if strings.HasPrefix(key, legacyGroupMessageKey) {
+			continue
+		}
+		msgs, err := readLegacyMessages(ctx, key, strings.TrimPrefix(key, legacyGroupKey))
+		if err != nil {
+			return err
+		}
+		if err := fn(msgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLegacyMessages groupId 为空时按私聊消息解析，缺少字段的哈希会被跳过
+func readLegacyMessages(ctx context.Context, key, groupId string) ([]*model.Message, error) {
+	instance := database.GetRedisInstance()
+	members, err := instance.ZRange(ctx, key, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*model.Message, 0, len(members))
+	for _, member := range members {
+		hash, err := instance.HGetAll(ctx, member)
+		if err != nil {
+			return nil, err
+		}
+		id, senderId := hash["Id"], hash["UserId"]
+		t, err := strconv.ParseInt(hash["Time"], 10, 64)
+		if id == "" || senderId == "" || err != nil {
+			log.Printf("skip malformed legacy message: key: %s, member: %s", key, member)
+			continue
+		}
+		msg := &model.Message{
+			Id:        id,
+			SenderId:  senderId,
+			Kind:      model.MessageKindText,
+			Content:   hash["Content"],
+			CreatedAt: time.Unix(t, 0),
+		}
+		if groupId != "" {
+			msg.ConversationId = model.GroupConversationId(groupId)
+			msg.GroupId = groupId
+		} else {
+			msg.ReceiverId = hash["ToUserId"]
+			msg.ConversationId = model.PrivateConversationId(senderId, msg.ReceiverId)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
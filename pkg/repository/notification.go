@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"time"
+	"west2/pkg/model"
+	"west2/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationCursor 通知列表上一页最后一条的排序键
+type NotificationCursor struct {
+	UpdatedAt time.Time
+	Id        string
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+type NotificationRepository interface {
+	AddEvent(event *model.NotificationEvent) (*model.Notification, bool, error)
+	ListNotifications(uid string, cursor *NotificationCursor, limit int) ([]*model.Notification, error)
+	CountUnread(uid string) (int64, error)
+	MarkRead(uid string, ids []string) error
+	MarkAllRead(uid string) error
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+// AddEvent 把事件合并进该用户同类型、同目标的未读通知，没有则新建，
+// 依靠 idx_notification_unread 唯一索引保证并发时只有一条未读通知；
+// 同一个人重复触发不会增加人数，此时返回的 changed 为 false；
+// 接收者静音了触发者或双方之间有屏蔽时丢弃事件，返回的通知为 nil
+func (nr *notificationRepository) AddEvent(event *model.NotificationEvent) (*model.Notification, bool, error) {
+	muted, err := isMuted(nr.db, event.Uid, event.ActorId)
+	if err != nil || muted {
+		return nil, false, err
+	}
+	blocked, err := isBlocked(nr.db, event.Uid, event.ActorId)
+	if err != nil || blocked {
+		return nil, false, err
+	}
+
+	unread := model.NotificationUnread
+	n := model.Notification{
+		Id:           util.GetID(),
+		Uid:          event.Uid,
+		Type:         event.Type,
+		TargetType:   event.TargetType,
+		TargetId:     event.TargetId,
+		UnreadBucket: &unread,
+		ActorId:      event.ActorId,
+		ActorCount:   1,
+	}
+	changed := false
+	err = nr.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&n)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			changed = true
+			return tx.Create(&model.NotificationActor{
+				Id:             util.GetID(),
+				NotificationId: n.Id,
+				ActorId:        event.ActorId,
+			}).Error
+		}
+
+		n = model.Notification{}
+		err := tx.Where("uid = ?", event.Uid).
+			Where("type = ?", event.Type).
+			Where("target_type = ?", event.TargetType).
+			Where("target_id = ?", event.TargetId).
+			Where("unread_bucket = ?", unread).
+			First(&n).Error
+		if err != nil {
+			return err
+		}
+
+		res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.NotificationActor{
+			Id:             util.GetID(),
+			NotificationId: n.Id,
+			ActorId:        event.ActorId,
+		})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		changed = true
+		n.ActorId = event.ActorId
+		n.ActorCount++
+		return tx.Model(&n).Updates(map[string]interface{}{
+			"actor_id":    n.ActorId,
+			"actor_count": gorm.Expr("actor_count + 1"),
+		}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &n, changed, nil
+}
+
+func (nr *notificationRepository) ListNotifications(uid string, cursor *NotificationCursor, limit int) ([]*model.Notification, error) {
+	tx := nr.db.Where("uid = ?", uid)
+	if cursor != nil {
+		tx = tx.Where("updated_at < ? OR (updated_at = ? AND id < ?)", cursor.UpdatedAt, cursor.UpdatedAt, cursor.Id)
+	}
+
+	var notifications []*model.Notification
+	err := tx.Order("updated_at DESC, id DESC").
+		Limit(limit).
+		Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (nr *notificationRepository) CountUnread(uid string) (int64, error) {
+	var count int64
+	err := nr.db.Model(&model.Notification{}).
+		Where("uid = ?", uid).
+		Where("is_read = ?", false).
+		Count(&count).Error
+	return count, err
+}
+
+func (nr *notificationRepository) MarkRead(uid string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return nr.db.Model(&model.Notification{}).
+		Where("uid = ?", uid).
+		Where("id IN ?", ids).
+		Where("is_read = ?", false).
+		UpdateColumns(map[string]interface{}{"is_read": true, "unread_bucket": nil}).Error
+}
+
+func (nr *notificationRepository) MarkAllRead(uid string) error {
+	return nr.db.Model(&model.Notification{}).
+		Where("uid = ?", uid).
+		Where("is_read = ?", false).
+		UpdateColumns(map[string]interface{}{"is_read": true, "unread_bucket": nil}).Error
+}
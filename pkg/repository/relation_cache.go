@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"west2/database"
+)
+
+const (
+	relationSuggestKey = "relation:suggest:"
+	// 有序集合中的占位成员，使没有推荐结果的用户也能命中缓存
+	relationSentinel = "-"
+)
+
+// 整体替换推荐结果并设置过期时间，ARGV 为 ttl 秒数之后依次排列的 score、member
+const setRelationSuggestScript = `
+	redis.call("DEL", KEYS[1])
+	redis.call("ZADD", KEYS[1], -1, ARGV[2])
+	for i = 3, #ARGV, 2 do
+		redis.call("ZADD", KEYS[1], ARGV[i], ARGV[i + 1])
+	end
+	redis.call("EXPIRE", KEYS[1], ARGV[1])
+	return 1
+`
+
+type relationCacheRepository struct{}
+
+type RelationCacheRepository interface {
+	GetSuggestions(uid string) ([]string, bool, error)
+	SetSuggestions(uid string, ids []string, scores []float64, ttl time.Duration) error
+}
+
+func NewRelationCacheRepository() RelationCacheRepository {
+	return &relationCacheRepository{}
+}
+
+// GetSuggestions 按分数从高到低返回缓存的推荐用户，第二个返回值表示缓存是否存在
+func (rc *relationCacheRepository) GetSuggestions(uid string) ([]string, bool, error) {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	members, err := instance.ZRevRange(ctx, relationSuggestKey+uid, 0, -1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(members) == 0 {
+		return nil, false, nil
+	}
+	ids := make([]string, 0, len(members)-1)
+	for _, m := range members {
+		if m != relationSentinel {
+			ids = append(ids, m)
+		}
+	}
+	return ids, true, nil
+}
+
+func (rc *relationCacheRepository) SetSuggestions(uid string, ids []string, scores []float64, ttl time.Duration) error {
+	args := make([]interface{}, 0, 2*len(ids)+2)
+	args = append(args, int64(ttl/time.Second), relationSentinel)
+	for i, id := range ids {
+		args = append(args, scores[i], id)
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	_, err := instance.Eval(ctx, setRelationSuggestScript, []string{relationSuggestKey + uid}, args)
+	return err
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"west2/database"
+	"west2/pkg/model"
+	"west2/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CaseCursor 上一页最后一个案件的排序键
+type CaseCursor struct {
+	Severity int64
+	Id       string
+}
+
+type reportRepository struct {
+	db *gorm.DB
+}
+
+type ReportRepository interface {
+	AddReport(report *model.Report, ownerId string, weight, threshold int64) (bool, error)
+	GetCase(id string) (*model.ReportCase, error)
+	ListCases(status int64, cursor *CaseCursor, limit int) ([]*model.ReportCase, error)
+	ResolveCase(c *model.ReportCase, reviewerId string, takedown bool, note string) (bool, error)
+	Appeal(c *model.ReportCase, reason string) (bool, error)
+}
+
+func NewReportRepository(db *gorm.DB) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+// AddReport 把举报计入目标的案件，同一用户对同一目标的重复举报返回 false；
+// 未处理的案件严重度达到 threshold 时自动隐藏视频或评论，用户与私信只进入案件等待审核员处理；
+// 已驳回的案件收到新举报时重新打开，严重度从新举报开始重新累计
+func (rr *reportRepository) AddReport(report *model.Report, ownerId string, weight, threshold int64) (bool, error) {
+	added, hidden := false, false
+	err := rr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.ReportCase{
+				Id:         util.GetID(),
+				TargetType: report.TargetType,
+				TargetId:   report.TargetId,
+				OwnerId:    ownerId,
+			}).Error
+		if err != nil {
+			return err
+		}
+		var c model.ReportCase
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("target_type = ? AND target_id = ?", report.TargetType, report.TargetId).
+			First(&c).Error
+		if err != nil {
+			return err
+		}
+
+		report.CaseId = c.Id
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(report)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		added = true
+
+		updates := map[string]interface{}{
+			"report_count": gorm.Expr("report_count + 1"),
+			"severity":     gorm.Expr("severity + ?", weight),
+		}
+		if c.Status == model.CaseDismissed {
+			c.Status, c.Severity = model.CaseOpen, 0
+			updates["status"] = model.CaseOpen
+			updates["severity"] = weight
+			updates["appealed"] = false
+			updates["appeal_reason"] = ""
+			updates["reviewer_id"] = nil
+			updates["note"] = ""
+		}
+		if c.Status == model.CaseOpen && c.Severity+weight >= threshold &&
+			(c.TargetType == model.TargetVideo || c.TargetType == model.TargetComment) {
+			updates["status"] = model.CaseHidden
+			if hidden, err = setContentHidden(tx, c.TargetType, c.TargetId, true); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.ReportCase{}).
+			Where("id = ?", c.Id).
+			Updates(updates).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	if hidden {
+		return true, clearVideoCache()
+	}
+	return added, nil
+}
+
+func (rr *reportRepository) GetCase(id string) (*model.ReportCase, error) {
+	var c model.ReportCase
+	err := rr.db.Where("id = ?", id).
+		First(&c).Error
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListCases 严重度高的案件排在前面
+func (rr *reportRepository) ListCases(status int64, cursor *CaseCursor, limit int) ([]*model.ReportCase, error) {
+	tx := rr.db.Model(&model.ReportCase{}).
+		Where("status = ?", status)
+	if cursor != nil {
+		tx = tx.Where("severity < ? OR (severity = ? AND id < ?)", cursor.Severity, cursor.Severity, cursor.Id)
+	}
+
+	var cases []*model.ReportCase
+	err := tx.Order("severity DESC, id DESC").
+		Limit(limit).
+		Find(&cases).Error
+	if err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// ResolveCase 下架时隐藏内容，驳回时恢复被自动隐藏的内容；案件已经处理过时返回 false
+func (rr *reportRepository) ResolveCase(c *model.ReportCase, reviewerId string, takedown bool, note string) (bool, error) {
+	status := model.CaseDismissed
+	if takedown {
+		status = model.CaseTakenDown
+	}
+
+	resolved, changed := false, false
+	err := rr.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.ReportCase{}).
+			Where("id = ?", c.Id).
+			Where("status IN ?", []int64{model.CaseOpen, model.CaseHidden, model.CaseAppealed}).
+			Updates(map[string]interface{}{
+				"status":      status,
+				"reviewer_id": reviewerId,
+				"note":        note,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		resolved = true
+
+		var err error
+		changed, err = setContentHidden(tx, c.TargetType, c.TargetId, takedown)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		return true, clearVideoCache()
+	}
+	return resolved, nil
+}
+
+// Appeal 只有被隐藏或下架、且没有申诉过的案件可以申诉
+func (rr *reportRepository) Appeal(c *model.ReportCase, reason string) (bool, error) {
+	res := rr.db.Model(&model.ReportCase{}).
+		Where("id = ?", c.Id).
+		Where("status IN ?", []int64{model.CaseHidden, model.CaseTakenDown}).
+		Where("appealed = ?", false).
+		Updates(map[string]interface{}{
+			"status":        model.CaseAppealed,
+			"appealed":      true,
+			"appeal_reason": reason,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// setContentHidden 在公开与隐藏之间切换视频或评论，其它状态的内容不受影响；
+// 返回视频是否发生变化，调用方据此清理播放量排行缓存
+func setContentHidden(tx *gorm.DB, targetType, targetId string, hidden bool) (bool, error) {
+	from, to := model.ContentHidden, model.ContentNormal
+	if hidden {
+		from, to = model.ContentNormal, model.ContentHidden
+	}
+
+	switch targetType {
+	case model.TargetVideo:
+		res := tx.Model(&model.Video{}).
+			Where("id = ?", targetId).
+			Where("status = ?", from).
+			Update("status", to)
+		return res.RowsAffected > 0, res.Error
+	case model.TargetComment:
+		var comment model.Comment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", targetId).
+			Where("status = ?", from).
+			Limit(1).
+			Find(&comment).Error
+		if err != nil || comment.Id == "" {
+			return false, err
+		}
+		err = tx.Model(&model.Comment{}).
+			Where("id = ?", comment.Id).
+			Update("status", to).Error
+		if err != nil {
+			return false, err
+		}
+		if hidden {
+			return false, subCommentCounts(tx, &comment)
+		}
+		return false, addCommentCounts(tx, &comment)
+	}
+	return false, nil
+}
+
+func clearVideoCache() error {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.Del(ctx, []string{key})
+}
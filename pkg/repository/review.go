@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"time"
+	"west2/pkg/model"
+
+	"gorm.io/gorm"
+)
+
+// ReviewCursor 上一页最后一条审核记录的排序键
+type ReviewCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+type ReviewRepository interface {
+	CreateReviewItem(item *model.ReviewItem) error
+	GetReviewItem(id string) (*model.ReviewItem, error)
+	ListReviewItems(status int64, cursor *ReviewCursor, limit int) ([]*model.ReviewItem, error)
+	ResolveReview(item *model.ReviewItem, reviewerId string, approved bool) (bool, error)
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
+
+// CreateReviewItem 同一内容只保留最新的一条待审核记录，之前未处理的记录标记为已被替代，
+// 避免审核员通过旧记录放行没有看过的内容
+func (rr *reviewRepository) CreateReviewItem(item *model.ReviewItem) error {
+	return rr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.ReviewItem{}).
+			Where("target_type = ?", item.TargetType).
+			Where("target_id = ?", item.TargetId).
+			Where("status = ?", model.ReviewPending).
+			Update("status", model.ReviewSuperseded).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(item).Error
+	})
+}
+
+func (rr *reviewRepository) GetReviewItem(id string) (*model.ReviewItem, error) {
+	var item model.ReviewItem
+	err := rr.db.Where("id = ?", id).
+		First(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ListReviewItems 按提交时间先后排列，先提交的先审
+func (rr *reviewRepository) ListReviewItems(status int64, cursor *ReviewCursor, limit int) ([]*model.ReviewItem, error) {
+	tx := rr.db.Model(&model.ReviewItem{}).
+		Where("status = ?", status)
+	if cursor != nil {
+		tx = tx.Where("created_at > ? OR (created_at = ? AND id > ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.Id)
+	}
+
+	var items []*model.ReviewItem
+	err := tx.Order("created_at ASC, id ASC").
+		Limit(limit).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ResolveReview 在同一事务中结束审核并更新内容状态；审核记录已被处理过时返回 false。
+// 评论通过审核时才计入父评论与视频的计数
+func (rr *reviewRepository) ResolveReview(item *model.ReviewItem, reviewerId string, approved bool) (bool, error) {
+	reviewStatus, contentStatus := model.ReviewRejected, model.ContentRejected
+	if approved {
+		reviewStatus, contentStatus = model.ReviewApproved, model.ContentNormal
+	}
+
+	resolved := false
+	err := rr.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.ReviewItem{}).
+			Where("id = ?", item.Id).
+			Where("status = ?", model.ReviewPending).
+			Updates(map[string]interface{}{
+				"status":      reviewStatus,
+				"reviewer_id": reviewerId,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		resolved = true
+
+		switch item.TargetType {
+		case model.TargetComment:
+			var comment model.Comment
+			err := tx.Where("id = ?", item.TargetId).
+				Where("status = ?", model.ContentPending).
+				Limit(1).
+				Find(&comment).Error
+			if err != nil || comment.Id == "" {
+				return err
+			}
+			err = tx.Model(&model.Comment{}).
+				Where("id = ?", comment.Id).
+				Update("status", contentStatus).Error
+			if err != nil {
+				return err
+			}
+			if !approved {
+				return nil
+			}
+			comment.Status = contentStatus
+			return addCommentCounts(tx, &comment)
+		case model.TargetVideo:
+			return tx.Model(&model.Video{}).
+				Where("id = ?", item.TargetId).
+				Where("status = ?", model.ContentPending).
+				Update("status", contentStatus).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if resolved && approved && item.TargetType == model.TargetVideo {
+		return true, clearVideoCache()
+	}
+	return resolved, nil
+}
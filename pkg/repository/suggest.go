@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+	"west2/database"
+)
+
+const (
+	suggestPrefixKey   = "search:suggest:"
+	suggestBackfillKey = "search:suggest_backfilled"
+	searchHistoryKey   = "search:history:"
+	suggestMaxPrefix   = 20
+	suggestKeepPerKey  = 200
+	historyKeep        = 20
+
+	// 旧的回填标记放在前缀集合的命名空间里，会和前缀 "backfilled" 冲突
+	legacySuggestBackfillKey = "search:suggest:backfilled"
+)
+
+// 每个前缀对应一个按频次排序的有序集合，查询时只需一次 ZREVRANGE
+const addSuggestTermScript = `
+	for i, key in ipairs(KEYS) do
+		redis.call("ZINCRBY", key, ARGV[1], ARGV[2])
+		redis.call("ZREMRANGEBYRANK", key, 0, -tonumber(ARGV[3]) - 1)
+	end
+	return 1
+`
+
+// 减去权重，分数不大于 0 的词从前缀集合中移除
+const removeSuggestTermScript = `
+	for i, key in ipairs(KEYS) do
+		local score = redis.call("ZINCRBY", key, -tonumber(ARGV[1]), ARGV[2])
+		if tonumber(score) <= 0 then
+			redis.call("ZREM", key, ARGV[2])
+		end
+	end
+	return 1
+`
+
+// 旧标记存在说明已经回填过，迁移到新标记后不再重复回填
+const claimBackfillScript = `
+	if redis.call("TYPE", KEYS[2]).ok == "string" then
+		redis.call("DEL", KEYS[2])
+		redis.call("SET", KEYS[1], "1")
+		return 0
+	end
+	if redis.call("SET", KEYS[1], "1", "NX") then
+		return 1
+	end
+	return 0
+`
+
+const addSearchHistoryScript = `
+	redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+	redis.call("ZREMRANGEBYRANK", KEYS[1], 0, -tonumber(ARGV[3]) - 1)
+	return 1
+`
+
+type suggestRepository struct{}
+
+type SuggestRepository interface {
+	AddTerm(term string, weight float64) error
+	RemoveTerm(term string, weight float64) error
+	ClaimBackfill() (bool, error)
+	ResetBackfill() error
+	GetSuggestions(prefix string, limit int64) ([]string, error)
+	AddHistory(uid, query string) error
+	GetHistory(uid string) ([]string, error)
+	ClearHistory(uid string) error
+}
+
+func NewSuggestRepository() SuggestRepository {
+	return &suggestRepository{}
+}
+
+func (sr *suggestRepository) AddTerm(term string, weight float64) error {
+	keys := suggestKeys(term)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	_, err := instance.Eval(ctx, addSuggestTermScript, keys, []interface{}{weight, term, suggestKeepPerKey})
+	return err
+}
+
+func (sr *suggestRepository) RemoveTerm(term string, weight float64) error {
+	keys := suggestKeys(term)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	_, err := instance.Eval(ctx, removeSuggestTermScript, keys, []interface{}{weight, term})
+	return err
+}
+
+// ClaimBackfill 只有第一个调用者返回 true，由它把已有的视频标题和用户名写入联想词
+func (sr *suggestRepository) ClaimBackfill() (bool, error) {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	res, err := instance.Eval(ctx, claimBackfillScript, []string{suggestBackfillKey, legacySuggestBackfillKey}, nil)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.(int64)
+	return n == 1, nil
+}
+
+// ResetBackfill 回填失败时调用，下一次启动重新回填
+func (sr *suggestRepository) ResetBackfill() error {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.Del(ctx, []string{suggestBackfillKey})
+}
+
+func suggestKeys(term string) []string {
+	runes := []rune(term)
+	n := len(runes)
+	if n > suggestMaxPrefix {
+		n = suggestMaxPrefix
+	}
+	keys := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		keys = append(keys, suggestPrefixKey+string(runes[:i]))
+	}
+	return keys
+}
+
+func (sr *suggestRepository) GetSuggestions(prefix string, limit int64) ([]string, error) {
+	runes := []rune(prefix)
+	if len(runes) > suggestMaxPrefix {
+		runes = runes[:suggestMaxPrefix]
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.ZRevRange(ctx, suggestPrefixKey+string(runes), 0, limit-1)
+}
+
+func (sr *suggestRepository) AddHistory(uid, query string) error {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	score := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	_, err := instance.Eval(ctx, addSearchHistoryScript, []string{searchHistoryKey + uid}, []interface{}{score, query, historyKeep})
+	return err
+}
+
+func (sr *suggestRepository) GetHistory(uid string) ([]string, error) {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.ZRevRange(ctx, searchHistoryKey+uid, 0, historyKeep-1)
+}
+
+func (sr *suggestRepository) ClearHistory(uid string) error {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.Del(ctx, []string{searchHistoryKey + uid})
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"time"
+	"west2/pkg/model"
+	"west2/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type tagRepository struct {
+	db *gorm.DB
+}
+
+type TagRepository interface {
+	SetVideoTags(videoId string, names []string) error
+	HasVideoTags() (bool, error)
+	GetVideosByTag(name, cursor string, limit int) ([]*model.Video, error)
+	GetTrendingTags(since time.Time, limit int) ([]*model.TagStat, error)
+	GetTagsByPrefix(prefix string, limit int) ([]*model.Tag, error)
+}
+
+func NewTagRepository(db *gorm.DB) TagRepository {
+	return &tagRepository{db: db}
+}
+
+// SetVideoTags replaces the tags of a video and keeps each tag's video
+// count in step with the links added and removed.
+func (tr *tagRepository) SetVideoTags(videoId string, names []string) error {
+	return tr.db.Transaction(func(tx *gorm.DB) error {
+		return setVideoTags(tx, videoId, names)
+	})
+}
+
+// HasVideoTags reports whether any video has been tagged yet.
+func (tr *tagRepository) HasVideoTags() (bool, error) {
+	var links []*model.VideoTag
+	if err := tr.db.Limit(1).Find(&links).Error; err != nil {
+		return false, err
+	}
+	return len(links) > 0, nil
+}
+
+// setVideoTags runs inside the caller's transaction so that tags change
+// together with the video they belong to.
+func setVideoTags(tx *gorm.DB, videoId string, names []string) error {
+	var tags []*model.Tag
+	if len(names) > 0 {
+		for _, name := range names {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&model.Tag{Id: util.GetID(), Name: name}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("name IN ?", names).Find(&tags).Error; err != nil {
+			return err
+		}
+	}
+
+	var current []*model.VideoTag
+	if err := tx.Where("video_id = ?", videoId).Find(&current).Error; err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		wanted[t.Id] = true
+	}
+	existing := make(map[string]bool, len(current))
+	var removed []string
+	for _, vt := range current {
+		existing[vt.TagId] = true
+		if !wanted[vt.TagId] {
+			removed = append(removed, vt.TagId)
+		}
+	}
+	var added []string
+	for _, t := range tags {
+		if !existing[t.Id] {
+			added = append(added, t.Id)
+		}
+	}
+
+	if len(removed) > 0 {
+		err := tx.Where("video_id = ?", videoId).
+			Where("tag_id IN ?", removed).
+			Delete(&model.VideoTag{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&model.Tag{}).
+			Where("id IN ?", removed).
+			Update("video_count", gorm.Expr("video_count - ?", 1)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(added) > 0 {
+		links := make([]*model.VideoTag, len(added))
+		for i, tagId := range added {
+			links[i] = &model.VideoTag{Id: util.GetID(), VideoId: videoId, TagId: tagId}
+		}
+		if err := tx.Create(&links).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&model.Tag{}).
+			Where("id IN ?", added).
+			Update("video_count", gorm.Expr("video_count + ?", 1)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetVideosByTag pages backwards by video id; cursor is the last id of the
+// previous page, empty for the first page.
+func (tr *tagRepository) GetVideosByTag(name, cursor string, limit int) ([]*model.Video, error) {
+	var videos []*model.Video
+	tx := tr.db.Model(&model.Video{}).
+		Select("videos.*").
+		Joins("INNER JOIN video_tags ON video_tags.video_id = videos.id").
+		Joins("INNER JOIN tags ON tags.id = video_tags.tag_id").
+		Where("tags.name = ?", name).
+		Where("videos.status = ?", model.ContentNormal).
+		Where("videos.deleted_at IS NULL")
+	if cursor != "" {
+		tx = tx.Where("videos.id < ?", cursor)
+	}
+	err := tx.Order("videos.id desc").
+		Limit(limit).
+		Find(&videos).Error
+	if err != nil {
+		return nil, err
+	}
+	return videos, nil
+}
+
+func (tr *tagRepository) GetTrendingTags(since time.Time, limit int) ([]*model.TagStat, error) {
+	var stats []*model.TagStat
+	err := tr.db.Model(&model.Tag{}).
+		Select("tags.*, COUNT(*) AS recent_count").
+		Joins("INNER JOIN video_tags ON video_tags.tag_id = tags.id").
+		Joins("INNER JOIN videos ON videos.id = video_tags.video_id").
+		Where("video_tags.created_at >= ?", since).
+		Where("videos.status = ?", model.ContentNormal).
+		Where("videos.deleted_at IS NULL").
+		Group("tags.id").
+		Order("recent_count desc").
+		Order("tags.video_count desc").
+		Limit(limit).
+		Find(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (tr *tagRepository) GetTagsByPrefix(prefix string, limit int) ([]*model.Tag, error) {
+	var tags []*model.Tag
+	err := tr.db.Where("name LIKE ?", escapeLike(prefix)+"%").
+		Where("video_count > 0").
+		Order("video_count desc").
+		Limit(limit).
+		Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"strings"
 	"west2/pkg/model"
 
 	"gorm.io/gorm"
@@ -13,8 +14,15 @@ type userRepository struct {
 type UserRepository interface {
 	CreateUser(user *model.User) error
 	GetUserByUsername(username string) (*model.User, error)
+	GetUsersByUsernames(usernames []string) ([]*model.User, error)
 	GetUserById(id string) (*model.User, error)
+	GetUsersByIds(ids []string) ([]*model.User, error)
 	SetAvatar(id string, url string) error
+	SetDisplayName(id string, displayName string) error
+	SetMentionPolicy(id string, policy int64) error
+	SetPrivate(id string, private bool) error
+	SearchUsers(keywords string, excludeIds []string, pageNum, pageSize int64) ([]*model.User, int64, error)
+	GetUsersAfterId(lastId string, limit int) ([]*model.User, error)
 }
 
 func NewUserRepository(db *gorm.DB) UserRepository {
@@ -35,14 +43,173 @@ func (ur *userRepository) GetUserByUsername(username string) (*model.User, error
 	return &user, nil
 }
 
+func (ur *userRepository) GetUsersByUsernames(usernames []string) ([]*model.User, error) {
+	var users []*model.User
+	if err := ur.db.Where("username IN ?", usernames).
+		Where("deleted_at IS NULL").
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUserById 经过用户资料缓存读取，返回的用户不带密码；用户不存在时返回 gorm.ErrRecordNotFound
 func (ur *userRepository) GetUserById(id string) (*model.User, error) {
-	var user model.User
-	if err := ur.db.First(&user, id).Error; err != nil {
+	users, err := ur.GetUsersByIds([]string{id})
+	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	if len(users) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return users[0], nil
+}
+
+// GetUsersByIds 按 ids 的顺序返回用户，先读缓存，未命中的用一次查询从 MySQL 加载并回填缓存；
+// 重复的 id 只返回一次，查不到的 id 会被跳过
+func (ur *userRepository) GetUsersByIds(ids []string) ([]*model.User, error) {
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, nil
+	}
+
+	byId, missing := getCachedUsers(unique)
+	if len(missing) > 0 {
+		var loaded []*model.User
+		err := ur.db.Omit("password").
+			Where("id IN ?", missing).
+			Find(&loaded).Error
+		if err != nil {
+			return nil, err
+		}
+		setCachedUsers(loaded)
+		for _, u := range loaded {
+			byId[u.Id] = u
+		}
+	}
+
+	users := make([]*model.User, 0, len(unique))
+	for _, id := range unique {
+		if u, ok := byId[id]; ok {
+			users = append(users, u)
+		}
+	}
+	return users, nil
 }
 
 func (ur *userRepository) SetAvatar(id string, url string) error {
-	return ur.db.Model(&model.User{}).Where("id = ?", id).Update("avatar_url", url).Error
+	err := ur.db.Model(&model.User{}).Where("id = ?", id).Update("avatar_url", url).Error
+	if err != nil {
+		return err
+	}
+	return delCachedUsers(id)
+}
+
+func (ur *userRepository) SetDisplayName(id string, displayName string) error {
+	err := ur.db.Model(&model.User{}).Where("id = ?", id).Update("display_name", displayName).Error
+	if err != nil {
+		return err
+	}
+	return delCachedUsers(id)
+}
+
+func (ur *userRepository) SetMentionPolicy(id string, policy int64) error {
+	err := ur.db.Model(&model.User{}).Where("id = ?", id).Update("mention_policy", policy).Error
+	if err != nil {
+		return err
+	}
+	return delCachedUsers(id)
+}
+
+func (ur *userRepository) SetPrivate(id string, private bool) error {
+	err := ur.db.Model(&model.User{}).Where("id = ?", id).Update("is_private", private).Error
+	if err != nil {
+		return err
+	}
+	return delCachedUsers(id)
+}
+
+// SearchUsers 按用户名和昵称匹配用户：完全匹配 > 前缀匹配 > 包含 > 按顺序包含全部字符，
+// 同一档内按粉丝数排序，excludeIds 中的用户不会出现在结果中
+func (ur *userRepository) SearchUsers(keywords string, excludeIds []string, pageNum, pageSize int64) ([]*model.User, int64, error) {
+	var users []*model.User
+	var total int64
+
+	escaped := escapeLike(strings.ToLower(keywords))
+	prefix := escaped + "%"
+	contains := "%" + escaped + "%"
+	var fuzzy strings.Builder
+	fuzzy.WriteString("%")
+	for _, r := range escapeLikeRunes(strings.ToLower(keywords)) {
+		fuzzy.WriteString(r)
+		fuzzy.WriteString("%")
+	}
+
+	match := func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Where("users.deleted_at IS NULL").
+			Where("LOWER(users.username) LIKE ? OR LOWER(users.display_name) LIKE ?", fuzzy.String(), fuzzy.String())
+		if len(excludeIds) > 0 {
+			tx = tx.Where("users.id NOT IN ?", excludeIds)
+		}
+		return tx
+	}
+
+	err := match(ur.db.Model(&model.User{})).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = match(ur.db.Model(&model.User{})).
+		Order(gorm.Expr(`CASE
+			WHEN LOWER(users.username) = ? OR LOWER(users.display_name) = ? THEN 0
+			WHEN LOWER(users.username) LIKE ? OR LOWER(users.display_name) LIKE ? THEN 1
+			WHEN LOWER(users.username) LIKE ? OR LOWER(users.display_name) LIKE ? THEN 2
+			ELSE 3 END`, strings.ToLower(keywords), strings.ToLower(keywords), prefix, prefix, contains, contains)).
+		Order("users.follower_count desc").
+		Order("users.id").
+		Offset((int(pageNum) - 1) * int(pageSize)).
+		Limit(int(pageSize)).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func escapeLike(s string) string {
+	return strings.Join(escapeLikeRunes(s), "")
+}
+
+func escapeLikeRunes(s string) []string {
+	var runes []string
+	for _, r := range s {
+		switch r {
+		case '%', '_', '\\':
+			runes = append(runes, "\\"+string(r))
+		default:
+			runes = append(runes, string(r))
+		}
+	}
+	return runes
+}
+
+func (ur *userRepository) GetUsersAfterId(lastId string, limit int) ([]*model.User, error) {
+	var users []*model.User
+	err := ur.db.Where("id > ?", lastId).
+		Where("deleted_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
 }
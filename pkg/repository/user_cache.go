@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+	"west2/database"
+	"west2/pkg/model"
+)
+
+const (
+	userProfileKey = "user:profile:"
+	userProfileTTL = 30 * time.Minute
+)
+
+// getCachedUsers 返回缓存中命中的用户和未命中的 id；缓存不可用时全部视为未命中，由调用方回源 MySQL
+func getCachedUsers(ids []string) (map[string]*model.User, []string) {
+	hits := make(map[string]*model.User, len(ids))
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = userProfileKey + id
+	}
+
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	values, err := instance.MGet(ctx, keys)
+	if err != nil {
+		return hits, ids
+	}
+
+	var missing []string
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			missing = append(missing, ids[i])
+			continue
+		}
+		var u model.User
+		if err := json.Unmarshal([]byte(s), &u); err != nil {
+			missing = append(missing, ids[i])
+			continue
+		}
+		hits[ids[i]] = &u
+	}
+	return hits, missing
+}
+
+// setCachedUsers 缓存中不保存密码，写入失败只影响下一次读取的命中率
+func setCachedUsers(users []*model.User) {
+	if len(users) == 0 {
+		return
+	}
+	values := make(map[string]interface{}, len(users))
+	for _, u := range users {
+		profile := *u
+		profile.Password = ""
+		profile.Videos = nil
+		j, err := json.Marshal(&profile)
+		if err != nil {
+			continue
+		}
+		values[userProfileKey+u.Id] = j
+	}
+
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	_ = instance.MSetEx(ctx, values, userProfileTTL)
+}
+
+// delCachedUsers 在用户资料或关注计数变化后调用，下一次读取时重新从 MySQL 加载
+func delCachedUsers(ids ...string) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = userProfileKey + id
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.Del(ctx, keys)
+}
+
+// flushCachedUsers 删除所有用户资料缓存，用于批量修正计数之后
+func flushCachedUsers() error {
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	keys, err := instance.ScanKeys(ctx, userProfileKey+"*")
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(keys); start += 1000 {
+		end := min(start+1000, len(keys))
+		if err := instance.Del(ctx, keys[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -13,21 +13,57 @@ import (
 
 const (
 	key string = "video:list:visitCount"
+
+	filterIdsBatchSize = 500
+)
+
+const (
+	SortByRelevance  = "relevance"
+	SortByNewest     = "newest"
+	SortByMostLiked  = "most_liked"
+	SortByMostViewed = "most_viewed"
 )
 
+// VideoFilter narrows a video search. Zero values mean "no restriction",
+// except Ids: a non-nil empty slice matches nothing.
+type VideoFilter struct {
+	Ids         []string
+	Uids        []string
+	ExcludeUids []string
+	// HidePrivate drops videos by private accounts that ViewerId does not
+	// follow. An empty ViewerId is an anonymous viewer.
+	HidePrivate  bool
+	ViewerId     string
+	Tag          string
+	FromDate     time.Time
+	ToDate       time.Time
+	MinLikeCount int64
+	MinDuration  int64
+	MaxDuration  int64
+}
+
 type videoRepository struct {
 	db *gorm.DB
 }
 
 type VideoRepository interface {
 	GetVideosByLatestTime(latestTime string) ([]*model.Video, error)
-	CreateVideo(video *model.Video) error
+	CreateVideo(video *model.Video, tags []string) error
 	GetVideosByUid(uid string, pageNum, pageSize int64) ([]*model.Video, int64, error)
 	GetVideosGroupByVisitCount(pageNum, pageSize int64) ([]*model.Video, error)
-	GetVideosByKeywords(keywords, fromDate, toDate, username string, pageNum, pageSize int64) ([]*model.Video, int64, error)
+	SearchVideos(filter *VideoFilter, sortBy string, pageNum, pageSize int64) ([]*model.Video, int64, error)
+	FilterVideoIds(filter *VideoFilter) ([]string, error)
 	AddLikeCount(id string) error
 	SubtractLikeCount(id string) error
-	GetVideosByIds(ids []*string) ([]*model.Video, error)
+	GetVideosByIds(ids []string) ([]*model.Video, error)
+	GetVideoById(id string) (*model.Video, error)
+	UpdateVideo(id, title, description string, mentions model.Mentions, status int64, tags []string) error
+	SetVideoStatus(id string, status int64) error
+	DeleteVideo(id string) error
+	GetVideosAfterId(lastId string, limit int) ([]*model.Video, error)
+	GetVideosUpdatedAfter(since time.Time, lastId string, limit int) ([]*model.Video, error)
+	GetVideosWithoutDuration(lastId string, limit int) ([]*model.Video, error)
+	SetDuration(id string, duration int64) error
 }
 
 func NewVideoRepository(db *gorm.DB) VideoRepository {
@@ -43,6 +79,7 @@ func (vr *videoRepository) GetVideosByLatestTime(latestTime string) ([]*model.Vi
 	}
 	err = vr.db.Where("created_at > ?", time.Unix(t, 0)).
 		Where("deleted_at IS NULL").
+		Where("status = ?", model.ContentNormal).
 		Find(&videos).Error
 	if err != nil {
 		return nil, err
@@ -51,8 +88,14 @@ func (vr *videoRepository) GetVideosByLatestTime(latestTime string) ([]*model.Vi
 	return videos, nil
 }
 
-func (vr *videoRepository) CreateVideo(video *model.Video) error {
-	err := vr.db.Create(video).Error
+// CreateVideo 在同一个事务中写入视频和它的标签
+func (vr *videoRepository) CreateVideo(video *model.Video, tags []string) error {
+	err := vr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(video).Error; err != nil {
+			return err
+		}
+		return setVideoTags(tx, video.Id, tags)
+	})
 	if err != nil {
 		return err
 	}
@@ -68,7 +111,8 @@ func (vr *videoRepository) GetVideosByUid(uid string, pageNum, pageSize int64) (
 
 	tx := vr.db.Model(&model.Video{}).
 		Where("uid = ?", uid).
-		Where("deleted_at IS NULL")
+		Where("deleted_at IS NULL").
+		Where("status = ?", model.ContentNormal)
 
 	err = tx.Count(&total).Error
 	if err != nil {
@@ -77,6 +121,7 @@ func (vr *videoRepository) GetVideosByUid(uid string, pageNum, pageSize int64) (
 
 	err = vr.db.Where("uid = ?", uid).
 		Where("deleted_at IS NULL").
+		Where("status = ?", model.ContentNormal).
 		Offset((int(pageNum) - 1) * int(pageSize)).
 		Limit(int(pageSize)).
 		Find(&videos).Error
@@ -108,6 +153,7 @@ func (vr *videoRepository) GetVideosGroupByVisitCount(pageNum, pageSize int64) (
 	}
 
 	err = vr.db.Where("deleted_at IS NULL").
+		Where("status = ?", model.ContentNormal).
 		Order("visit_count desc").
 		Offset(int(first)).
 		Limit(int(pageSize)).
@@ -132,29 +178,23 @@ func (vr *videoRepository) GetVideosGroupByVisitCount(pageNum, pageSize int64) (
 	return videos, nil
 }
 
-func (vr *videoRepository) GetVideosByKeywords(keywords, fromDate, toDate, uid string, pageNum, pageSize int64) ([]*model.Video, int64, error) {
+func (vr *videoRepository) SearchVideos(filter *VideoFilter, sortBy string, pageNum, pageSize int64) ([]*model.Video, int64, error) {
 	var videos []*model.Video
 	var total int64
 	var err error
-	tx := vr.db.Model(&model.Video{}).
-		Where("title LIKE ? or description LIKE ?", "%"+keywords+"%", "%"+keywords+"%")
-
-	if fromDate != "" {
-		tx = tx.Where("from_date > ?", fromDate)
-	}
-	if toDate != "" {
-		tx = tx.Where("to_date < ?", toDate)
-	}
-	if uid != "" {
-		tx = tx.Where("uid = ?", uid)
+	if filter.Ids != nil && len(filter.Ids) == 0 {
+		return nil, 0, nil
 	}
 
-	err = tx.Count(&total).Error
+	err = vr.filter(filter).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = tx.Offset((int(pageNum) - 1) * int(pageSize)).
+	err = vr.filter(filter).
+		Order(orderBy(sortBy)).
+		Order("id desc").
+		Offset((int(pageNum) - 1) * int(pageSize)).
 		Limit(int(pageSize)).
 		Find(&videos).Error
 
@@ -165,6 +205,73 @@ func (vr *videoRepository) GetVideosByKeywords(keywords, fromDate, toDate, uid s
 	return videos, total, nil
 }
 
+// FilterVideoIds 按 filterIdsBatchSize 分批查询 filter.Ids，每条语句的 id IN 列表长度有上限
+func (vr *videoRepository) FilterVideoIds(filter *VideoFilter) ([]string, error) {
+	var matched []string
+	ids := filter.Ids
+	batch := *filter
+	for start := 0; start < len(ids); start += filterIdsBatchSize {
+		batch.Ids = ids[start:min(start+filterIdsBatchSize, len(ids))]
+		var part []string
+		if err := vr.filter(&batch).Pluck("id", &part).Error; err != nil {
+			return nil, err
+		}
+		matched = append(matched, part...)
+	}
+
+	return matched, nil
+}
+
+func (vr *videoRepository) filter(filter *VideoFilter) *gorm.DB {
+	tx := vr.db.Model(&model.Video{}).
+		Where("deleted_at IS NULL").
+		Where("status = ?", model.ContentNormal)
+
+	if filter.Ids != nil {
+		tx = tx.Where("id IN ?", filter.Ids)
+	}
+	if len(filter.Uids) > 0 {
+		tx = tx.Where("uid IN ?", filter.Uids)
+	}
+	if len(filter.ExcludeUids) > 0 {
+		tx = tx.Where("uid NOT IN ?", filter.ExcludeUids)
+	}
+	if filter.HidePrivate {
+		tx = tx.Where("uid NOT IN (SELECT users.id FROM users WHERE users.is_private = ? AND users.id <> ? AND users.id NOT IN (SELECT follows.following_id FROM follows WHERE follows.follower_id = ? AND follows.status = ?))",
+			true, filter.ViewerId, filter.ViewerId, model.FollowStatusActive)
+	}
+	if filter.Tag != "" {
+		tx = tx.Where("id IN (SELECT video_tags.video_id FROM video_tags INNER JOIN tags ON tags.id = video_tags.tag_id WHERE tags.name = ?)", filter.Tag)
+	}
+	if !filter.FromDate.IsZero() {
+		tx = tx.Where("created_at >= ?", filter.FromDate)
+	}
+	if !filter.ToDate.IsZero() {
+		tx = tx.Where("created_at <= ?", filter.ToDate)
+	}
+	if filter.MinLikeCount > 0 {
+		tx = tx.Where("like_count >= ?", filter.MinLikeCount)
+	}
+	if filter.MinDuration > 0 {
+		tx = tx.Where("duration >= ?", filter.MinDuration)
+	}
+	if filter.MaxDuration > 0 {
+		tx = tx.Where("duration <= ?", filter.MaxDuration)
+	}
+	return tx
+}
+
+func orderBy(sortBy string) string {
+	switch sortBy {
+	case SortByMostLiked:
+		return "like_count desc"
+	case SortByMostViewed:
+		return "visit_count desc"
+	default:
+		return "created_at desc"
+	}
+}
+
 func (vr *videoRepository) AddLikeCount(id string) error {
 	return vr.db.Model(&model.Video{}).Where("id = ?", id).Update("like_count", gorm.Expr("like_count + ?", 1)).Error
 }
@@ -173,12 +280,137 @@ func (vr *videoRepository) SubtractLikeCount(id string) error {
 	return vr.db.Model(&model.Video{}).Where("id = ?", id).Update("like_count", gorm.Expr("like_count - ?", 1)).Error
 }
 
-func (vr *videoRepository) GetVideosByIds(ids []*string) ([]*model.Video, error) {
+func (vr *videoRepository) GetVideosByIds(ids []string) ([]*model.Video, error) {
+	var videos []*model.Video
+	err := vr.db.Where("id IN ?", ids).
+		Where("status = ?", model.ContentNormal).
+		Where("deleted_at IS NULL").
+		Find(&videos).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+func (vr *videoRepository) GetVideoById(id string) (*model.Video, error) {
+	var video model.Video
+	err := vr.db.Where("id = ?", id).
+		Where("deleted_at IS NULL").
+		First(&video).Error
+	if err != nil {
+		return nil, err
+	}
+	return &video, nil
+}
+
+// UpdateVideo 在同一个事务中更新视频内容、审核状态和标签
+func (vr *videoRepository) UpdateVideo(id, title, description string, mentions model.Mentions, status int64, tags []string) error {
+	err := vr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Video{}).
+			Where("id = ?", id).
+			Where("deleted_at IS NULL").
+			Updates(map[string]interface{}{
+				"title":       title,
+				"description": description,
+				"mentions":    mentions,
+				"status":      status,
+			}).Error
+		if err != nil {
+			return err
+		}
+		return setVideoTags(tx, id, tags)
+	})
+	if err != nil {
+		return err
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.Del(ctx, []string{key})
+}
+
+func (vr *videoRepository) SetVideoStatus(id string, status int64) error {
+	err := vr.db.Model(&model.Video{}).
+		Where("id = ?", id).
+		Update("status", status).Error
+	if err != nil {
+		return err
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.Del(ctx, []string{key})
+}
+
+func (vr *videoRepository) SetDuration(id string, duration int64) error {
+	err := vr.db.Model(&model.Video{}).
+		Where("id = ?", id).
+		Update("duration", duration).Error
+	if err != nil {
+		return err
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.Del(ctx, []string{key})
+}
+
+// DeleteVideo 删除视频的同时移除它的标签
+func (vr *videoRepository) DeleteVideo(id string) error {
+	err := vr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Video{}).
+			Where("id = ?", id).
+			Where("deleted_at IS NULL").
+			Update("deleted_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return setVideoTags(tx, id, nil)
+	})
+	if err != nil {
+		return err
+	}
+	instance := database.GetRedisInstance()
+	ctx := context.Background()
+	return instance.Del(ctx, []string{key})
+}
+
+func (vr *videoRepository) GetVideosAfterId(lastId string, limit int) ([]*model.Video, error) {
 	var videos []*model.Video
-	err := vr.db.Where("id IN ?", ids).Find(&videos).Error
+	err := vr.db.Where("id > ?", lastId).
+		Where("deleted_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&videos).Error
 	if err != nil {
 		return nil, err
 	}
+	return videos, nil
+}
 
+// GetVideosUpdatedAfter 返回 since 之后修改过的视频，包括已删除的视频，用于把变更重放到搜索索引
+func (vr *videoRepository) GetVideosUpdatedAfter(since time.Time, lastId string, limit int) ([]*model.Video, error) {
+	var videos []*model.Video
+	err := vr.db.Where("updated_at > ?", since).
+		Where("id > ?", lastId).
+		Order("id").
+		Limit(limit).
+		Find(&videos).Error
+	if err != nil {
+		return nil, err
+	}
+	return videos, nil
+}
+
+// GetVideosWithoutDuration 返回时长为 0 的视频，即加入时长字段之前上传、或发布时未能读出时长的视频
+func (vr *videoRepository) GetVideosWithoutDuration(lastId string, limit int) ([]*model.Video, error) {
+	var videos []*model.Video
+	err := vr.db.Where("id > ?", lastId).
+		Where("duration = 0").
+		Where("deleted_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&videos).Error
+	if err != nil {
+		return nil, err
+	}
 	return videos, nil
 }
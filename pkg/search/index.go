@@ -0,0 +1,64 @@
+package search
+
+import (
+	"sync"
+	"time"
+)
+
+// Document 视频中参与搜索的字段
+type Document struct {
+	Id          string
+	Title       string
+	Description string
+}
+
+// Hit 命中的视频 id 及其相关度得分
+type Hit struct {
+	Id    string
+	Score float64
+}
+
+// SearchIndex 视频的全文索引，Search 按得分从高到低返回全部命中，分页和过滤由调用方处理
+type SearchIndex interface {
+	Index(doc *Document) error
+	Delete(id string) error
+	Search(query string) ([]*Hit, error)
+	Reset(docs []*Document) error
+	Len() int
+	Save() error
+	// Mark 返回随快照保存的同步点，此后 MySQL 中变更的视频需要重放到索引；从未同步过时为零值
+	Mark() time.Time
+	SetMark(t time.Time)
+}
+
+type Options struct {
+	Path             string
+	TitleBoost       float64
+	DescriptionBoost float64
+	FlushInterval    time.Duration
+}
+
+var (
+	index     SearchIndex
+	indexOnce sync.Once
+)
+
+func InitIndex(opts Options) error {
+	var err error
+	indexOnce.Do(func() {
+		var ii *invertedIndex
+		ii, err = newInvertedIndex(opts)
+		if err != nil {
+			return
+		}
+		if opts.FlushInterval > 0 {
+			go ii.flushLoop(opts.FlushInterval)
+		}
+		index = ii
+	})
+	return err
+}
+
+func GetIndex() SearchIndex {
+	return index
+}
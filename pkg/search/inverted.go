@@ -0,0 +1,310 @@
+package search
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+const (
+	fieldTitle = iota
+	fieldDescription
+	fieldCount
+)
+
+type posting struct {
+	tf [fieldCount]int
+}
+
+type docEntry struct {
+	doc   *Document
+	len   [fieldCount]int
+	terms []string
+}
+
+// invertedIndex 内存中的倒排索引，按字段分别计算 BM25 得分；
+// 快照只保存文档，倒排表在加载时重新建立
+type invertedIndex struct {
+	mu       sync.RWMutex
+	saveMu   sync.Mutex
+	path     string
+	boosts   [fieldCount]float64
+	postings map[string]map[string]*posting
+	docs     map[string]*docEntry
+	totalLen [fieldCount]int
+	// version 每次修改加一，saved 为最近一次成功写入快照时的 version，两者不同说明有未保存的修改
+	version uint64
+	saved   uint64
+	mark    time.Time
+}
+
+// snapshot 为写入磁盘的内容，旧版本的快照只有 []*Document
+type snapshot struct {
+	Docs []*Document
+	Mark time.Time
+}
+
+func newInvertedIndex(opts Options) (*invertedIndex, error) {
+	ii := &invertedIndex{
+		path:     opts.Path,
+		postings: make(map[string]map[string]*posting),
+		docs:     make(map[string]*docEntry),
+	}
+	ii.boosts[fieldTitle] = opts.TitleBoost
+	ii.boosts[fieldDescription] = opts.DescriptionBoost
+	if ii.boosts[fieldTitle] == 0 {
+		ii.boosts[fieldTitle] = 2
+	}
+	if ii.boosts[fieldDescription] == 0 {
+		ii.boosts[fieldDescription] = 1
+	}
+
+	if err := ii.load(); err != nil {
+		return nil, err
+	}
+	return ii, nil
+}
+
+func (ii *invertedIndex) Index(doc *Document) error {
+	ii.mu.Lock()
+	defer ii.mu.Unlock()
+	ii.remove(doc.Id)
+	ii.add(doc)
+	ii.version++
+	return nil
+}
+
+func (ii *invertedIndex) Delete(id string) error {
+	ii.mu.Lock()
+	defer ii.mu.Unlock()
+	ii.remove(id)
+	ii.version++
+	return nil
+}
+
+func (ii *invertedIndex) Reset(docs []*Document) error {
+	ii.mu.Lock()
+	defer ii.mu.Unlock()
+	ii.postings = make(map[string]map[string]*posting)
+	ii.docs = make(map[string]*docEntry)
+	ii.totalLen = [fieldCount]int{}
+	for _, d := range docs {
+		ii.add(d)
+	}
+	ii.version++
+	return nil
+}
+
+func (ii *invertedIndex) Mark() time.Time {
+	ii.mu.RLock()
+	defer ii.mu.RUnlock()
+	return ii.mark
+}
+
+func (ii *invertedIndex) SetMark(t time.Time) {
+	ii.mu.Lock()
+	defer ii.mu.Unlock()
+	ii.mark = t
+	ii.version++
+}
+
+func (ii *invertedIndex) Len() int {
+	ii.mu.RLock()
+	defer ii.mu.RUnlock()
+	return len(ii.docs)
+}
+
+func (ii *invertedIndex) Search(query string) ([]*Hit, error) {
+	terms := TokenizeQuery(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	ii.mu.RLock()
+	defer ii.mu.RUnlock()
+
+	n := float64(len(ii.docs))
+	var avgLen [fieldCount]float64
+	for f := 0; f < fieldCount; f++ {
+		if n > 0 {
+			avgLen[f] = float64(ii.totalLen[f]) / n
+		}
+	}
+
+	scores := make(map[string]float64)
+	seen := make(map[string]bool)
+	for _, t := range terms {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		postings := ii.postings[t]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, p := range postings {
+			entry := ii.docs[id]
+			var s float64
+			for f := 0; f < fieldCount; f++ {
+				if p.tf[f] == 0 {
+					continue
+				}
+				tf := float64(p.tf[f])
+				norm := 1 - bm25B
+				if avgLen[f] > 0 {
+					norm += bm25B * float64(entry.len[f]) / avgLen[f]
+				}
+				s += ii.boosts[f] * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+			}
+			scores[id] += idf * s
+		}
+	}
+
+	hits := make([]*Hit, 0, len(scores))
+	for id, s := range scores {
+		hits = append(hits, &Hit{Id: id, Score: s})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Id > hits[j].Id
+	})
+	return hits, nil
+}
+
+// Save 同一时间只有一次写入；写入期间的修改会留到下一次保存，写入失败时修改仍视为未保存
+func (ii *invertedIndex) Save() error {
+	ii.saveMu.Lock()
+	defer ii.saveMu.Unlock()
+
+	ii.mu.RLock()
+	if ii.version == ii.saved || ii.path == "" {
+		ii.mu.RUnlock()
+		return nil
+	}
+	snap := &snapshot{Docs: make([]*Document, 0, len(ii.docs)), Mark: ii.mark}
+	for _, e := range ii.docs {
+		snap.Docs = append(snap.Docs, e.doc)
+	}
+	version := ii.version
+	ii.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(ii.path), 0755); err != nil {
+		return err
+	}
+	tmp := ii.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(snap); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, ii.path); err != nil {
+		return err
+	}
+
+	ii.mu.Lock()
+	ii.saved = version
+	ii.mu.Unlock()
+	return nil
+}
+
+func (ii *invertedIndex) load() error {
+	if ii.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(ii.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		// 旧格式的快照没有同步点，启动时会重放全部视频
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap.Docs); err != nil {
+			return err
+		}
+	}
+	for _, d := range snap.Docs {
+		ii.add(d)
+	}
+	ii.mark = snap.Mark
+	return nil
+}
+
+func (ii *invertedIndex) flushLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := ii.Save(); err != nil {
+			log.Printf("failed to save search index: path: %s, err: %v", ii.path, err)
+		}
+	}
+}
+
+func (ii *invertedIndex) add(doc *Document) {
+	entry := &docEntry{doc: doc}
+	fields := [fieldCount][]string{
+		fieldTitle:       Tokenize(doc.Title),
+		fieldDescription: Tokenize(doc.Description),
+	}
+	for f, terms := range fields {
+		entry.len[f] = len(terms)
+		ii.totalLen[f] += len(terms)
+		for _, t := range terms {
+			postings, ok := ii.postings[t]
+			if !ok {
+				postings = make(map[string]*posting)
+				ii.postings[t] = postings
+			}
+			p, ok := postings[doc.Id]
+			if !ok {
+				p = &posting{}
+				postings[doc.Id] = p
+				entry.terms = append(entry.terms, t)
+			}
+			p.tf[f]++
+		}
+	}
+	ii.docs[doc.Id] = entry
+}
+
+func (ii *invertedIndex) remove(id string) {
+	entry, ok := ii.docs[id]
+	if !ok {
+		return
+	}
+	for _, t := range entry.terms {
+		delete(ii.postings[t], id)
+		if len(ii.postings[t]) == 0 {
+			delete(ii.postings, t)
+		}
+	}
+	for f := 0; f < fieldCount; f++ {
+		ii.totalLen[f] -= entry.len[f]
+	}
+	delete(ii.docs, id)
+}
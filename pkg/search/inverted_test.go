@@ -0,0 +1,223 @@
+package search
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestIndex(t *testing.T, path string, docs []*Document) *invertedIndex {
+	t.Helper()
+	ii, err := newInvertedIndex(Options{Path: path})
+	if err != nil {
+		t.Fatalf("newInvertedIndex: %v", err)
+	}
+	for _, d := range docs {
+		if err := ii.Index(d); err != nil {
+			t.Fatalf("Index(%s): %v", d.Id, err)
+		}
+	}
+	return ii
+}
+
+func hitIds(hits []*Hit) []string {
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.Id
+	}
+	return ids
+}
+
+func TestSearchScoring(t *testing.T) {
+	tests := []struct {
+		name  string
+		docs  []*Document
+		query string
+		want  []string
+	}{
+		{
+			name: "no match",
+			docs: []*Document{
+				{Id: "1", Title: "golang"},
+			},
+			query: "rust",
+			want:  []string{},
+		},
+		{
+			name: "title outranks description",
+			docs: []*Document{
+				{Id: "1", Description: "golang"},
+				{Id: "2", Title: "golang"},
+			},
+			query: "golang",
+			want:  []string{"2", "1"},
+		},
+		{
+			name: "terms are matched with or",
+			docs: []*Document{
+				{Id: "1", Title: "golang"},
+				{Id: "2", Title: "rust"},
+				{Id: "3", Title: "golang rust"},
+				{Id: "4", Title: "python"},
+			},
+			query: "golang rust",
+			want:  []string{"3", "2", "1"},
+		},
+		{
+			name: "rare term weighs more",
+			docs: []*Document{
+				{Id: "1", Title: "cat"},
+				{Id: "2", Title: "dog"},
+				{Id: "3", Title: "cat"},
+				{Id: "4", Title: "cat"},
+			},
+			query: "cat dog",
+			want:  []string{"2", "4", "3", "1"},
+		},
+		{
+			name: "shorter field scores higher",
+			docs: []*Document{
+				{Id: "1", Title: "go tutorial for beginners"},
+				{Id: "2", Title: "go"},
+			},
+			query: "go",
+			want:  []string{"2", "1"},
+		},
+		{
+			name: "ties are ordered by id descending",
+			docs: []*Document{
+				{Id: "1", Title: "same"},
+				{Id: "2", Title: "same"},
+				{Id: "3", Title: "same"},
+			},
+			query: "same",
+			want:  []string{"3", "2", "1"},
+		},
+		{
+			name: "cjk bigram match",
+			docs: []*Document{
+				{Id: "1", Title: "世界杯决赛"},
+				{Id: "2", Title: "世界地图"},
+				{Id: "3", Title: "界面设计"},
+			},
+			query: "世界杯",
+			want:  []string{"1", "2"},
+		},
+		{
+			name: "reindexed document uses new content",
+			docs: []*Document{
+				{Id: "1", Title: "golang"},
+				{Id: "1", Title: "rust"},
+			},
+			query: "golang",
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ii := newTestIndex(t, "", tt.docs)
+			hits, err := ii.Search(tt.query)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if got := hitIds(hits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchAfterDelete(t *testing.T) {
+	ii := newTestIndex(t, "", []*Document{
+		{Id: "1", Title: "golang"},
+		{Id: "2", Title: "golang"},
+	})
+	if err := ii.Delete("2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	hits, err := ii.Search("golang")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if got, want := hitIds(hits), []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Search after delete = %v, want %v", got, want)
+	}
+	if ii.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", ii.Len())
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		docs    []*Document
+		queries []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "latin and cjk",
+			docs: []*Document{
+				{Id: "1", Title: "Golang 入门", Description: "从零开始学习 Go"},
+				{Id: "2", Title: "世界杯集锦", Description: "football highlights"},
+				{Id: "3", Title: "rust", Description: "学习 rust"},
+			},
+			queries: []string{"golang", "学习", "世界杯", "football rust"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "index", "search.idx")
+			mark := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+			ii := newTestIndex(t, path, tt.docs)
+			ii.SetMark(mark)
+			if err := ii.Save(); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			loaded := newTestIndex(t, path, nil)
+			if loaded.Len() != ii.Len() {
+				t.Errorf("Len() = %d, want %d", loaded.Len(), ii.Len())
+			}
+			if !loaded.Mark().Equal(mark) {
+				t.Errorf("Mark() = %v, want %v", loaded.Mark(), mark)
+			}
+			for _, q := range tt.queries {
+				want, _ := ii.Search(q)
+				got, err := loaded.Search(q)
+				if err != nil {
+					t.Fatalf("Search(%q): %v", q, err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Search(%q) after load = %v, want %v", q, hitIds(got), hitIds(want))
+				}
+			}
+		})
+	}
+}
+
+func TestLoadLegacySnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.idx")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []*Document{{Id: "1", Title: "golang"}, {Id: "2", Title: "rust"}}
+	if err := gob.NewEncoder(f).Encode(docs); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	ii := newTestIndex(t, path, nil)
+	if ii.Len() != len(docs) {
+		t.Errorf("Len() = %d, want %d", ii.Len(), len(docs))
+	}
+	if !ii.Mark().IsZero() {
+		t.Errorf("Mark() = %v, want zero", ii.Mark())
+	}
+}
@@ -0,0 +1,71 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize 把文本切分为索引词：英文单词和数字整体保留并转为小写，连续的中日韩字符
+// 切分为单字和相互重叠的双字，查询任意一个或两个字都能命中
+func Tokenize(text string) []string {
+	return tokenize(text, true)
+}
+
+// TokenizeQuery 与 Tokenize 的切分方式相同，但连续的中日韩字符只切出双字；
+// 查询词之间是“或”的关系，包含任意一个词的视频都会命中，包含的词越多得分越高
+func TokenizeQuery(text string) []string {
+	return tokenize(text, false)
+}
+
+func tokenize(text string, unigrams bool) []string {
+	var terms []string
+	var word strings.Builder
+	var cjk []rune
+
+	flushWord := func() {
+		if word.Len() > 0 {
+			terms = append(terms, word.String())
+			word.Reset()
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			terms = append(terms, string(cjk))
+		case len(cjk) > 1:
+			if unigrams {
+				for _, r := range cjk {
+					terms = append(terms, string(r))
+				}
+			}
+			for i := 0; i+1 < len(cjk); i++ {
+				terms = append(terms, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return terms
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
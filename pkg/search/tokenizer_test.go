@@ -0,0 +1,53 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"punctuation only", "!!! ...", nil},
+		{"latin words are lowercased", "Hello, World", []string{"hello", "world"}},
+		{"digits stay with letters", "Go1.25 release", []string{"go1", "25", "release"}},
+		{"single cjk character", "猫", []string{"猫"}},
+		{"cjk unigrams and bigrams", "世界杯", []string{"世", "界", "杯", "世界", "界杯"}},
+		{"mixed latin and cjk", "Go语言", []string{"go", "语", "言", "语言"}},
+		{"cjk runs split by space", "你好 世界", []string{"你", "好", "你好", "世", "界", "世界"}},
+		{"cjk followed by digits", "第1名", []string{"第", "1", "名"}},
+		{"kana and hangul", "カナ한국", []string{"カ", "ナ", "한", "국", "カナ", "ナ한", "한국"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"latin words", "Golang Tutorial", []string{"golang", "tutorial"}},
+		{"single cjk character keeps the unigram", "猫", []string{"猫"}},
+		{"cjk run only emits bigrams", "世界杯", []string{"世界", "界杯"}},
+		{"mixed latin and cjk", "学习Go语言", []string{"学习", "go", "语言"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TokenizeQuery(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TokenizeQuery(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
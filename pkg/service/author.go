@@ -0,0 +1,57 @@
+package service
+
+import (
+	"log"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+)
+
+// getUsersById 用一次批量查询取出 ids 对应的用户，查不到的 id 不在结果中
+func getUsersById(ur repository.UserRepository, ids []string) (map[string]*model.User, error) {
+	users, err := ur.GetUsersByIds(ids)
+	if err != nil {
+		log.Printf("failed to get users by ids: ids: %v, err: %v", ids, err)
+		return nil, err
+	}
+	byId := make(map[string]*model.User, len(users))
+	for _, u := range users {
+		byId[u.Id] = u
+	}
+	return byId, nil
+}
+
+func fillVideoAuthors(ur repository.UserRepository, videos []*model.Video) error {
+	if len(videos) == 0 {
+		return nil
+	}
+	ids := make([]string, len(videos))
+	for i, v := range videos {
+		ids[i] = v.Uid
+	}
+	users, err := getUsersById(ur, ids)
+	if err != nil {
+		return err
+	}
+	for _, v := range videos {
+		v.Author = users[v.Uid]
+	}
+	return nil
+}
+
+func fillCommentAuthors(ur repository.UserRepository, comments []*model.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]string, len(comments))
+	for i, c := range comments {
+		ids[i] = c.Uid
+	}
+	users, err := getUsersById(ur, ids)
+	if err != nil {
+		return err
+	}
+	for _, c := range comments {
+		c.Author = users[c.Uid]
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"log"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+)
+
+// hiddenUids 返回 viewer 看不到其内容的用户：与 viewer 之间有屏蔽的用户，
+// withMuted 为 true 时再加上 viewer 静音的用户；未登录时返回 nil
+func hiddenUids(fr repository.FollowRepostory, viewerId string, withMuted bool) ([]string, error) {
+	if viewerId == "" {
+		return nil, nil
+	}
+	ids, err := fr.GetBlockedIds(viewerId)
+	if err != nil {
+		log.Printf("failed to get blocked ids: uid: %s, err: %v", viewerId, err)
+		return nil, err
+	}
+	if withMuted {
+		muted, err := fr.GetMutedIds(viewerId)
+		if err != nil {
+			log.Printf("failed to get muted ids: uid: %s, err: %v", viewerId, err)
+			return nil, err
+		}
+		ids = append(ids, muted...)
+	}
+	return ids, nil
+}
+
+// filterVideos 去掉 hiddenUids 中作者的视频以及 viewer 看不到的私密账号的视频
+func filterVideos(ur repository.UserRepository, fr repository.FollowRepostory, viewerId string, withMuted bool, videos []*model.Video) ([]*model.Video, error) {
+	if len(videos) == 0 {
+		return videos, nil
+	}
+	authorIds := make([]string, len(videos))
+	for i, v := range videos {
+		authorIds[i] = v.Uid
+	}
+	hidden, err := privateUids(ur, fr, viewerId, authorIds)
+	if err != nil {
+		return nil, err
+	}
+	uids, err := hiddenUids(fr, viewerId, withMuted)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range uids {
+		hidden[id] = true
+	}
+	if len(hidden) == 0 {
+		return videos, nil
+	}
+	filtered := videos[:0]
+	for _, v := range videos {
+		if !hidden[v.Uid] {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered, nil
+}
+
+// checkBlocked 双方之间有屏蔽时返回 ErrBlocked
+func checkBlocked(fr repository.FollowRepostory, uid, otherId string) error {
+	if uid == "" || otherId == "" || uid == otherId {
+		return nil
+	}
+	blocked, err := fr.IsBlocked(uid, otherId)
+	if err != nil {
+		log.Printf("failed to check block: uid: %s, otherId: %s, err: %v", uid, otherId, err)
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
@@ -1,43 +1,61 @@
 package service
 
 import (
-	"context"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"log"
-	"strconv"
+	"path"
 	"time"
-	"west2/database"
+	"unicode"
+	"unicode/utf8"
+	"west2/pkg/hub"
 	"west2/pkg/model"
+	"west2/pkg/moderation"
+	"west2/pkg/repository"
 	"west2/util"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultChatPageSize = 20
+	maxChatPageSize     = 100
+	defaultRecallWindow = 2 * time.Minute
+	maxEmojiLength      = 32
+
+	chatImageDir = "/static/chat"
+
+	legacyGroupTitle = "群聊"
+
+	maxChatImageBytes = 5 << 20
+	maxChatImageSide  = 4096
 )
 
-const setPrivateMessageScript = `
-	redis.call("ZADD", KEYS[1], ARGV[1], KEYS[3])
-	redis.call("ZADD", KEYS[2], ARGV[1], KEYS[3])
-	redis.call("HSET", KEYS[3], unpack(ARGV, 2))
-	return 1
-`
-
-const getUnreadHistoryMessageScript = `
-	local ids = redis.call("ZREVRANGE", KEYS[1], ARGV[1], ARGV[2])
-	local messages = {}
-	for i, id in ipairs(ids) do 
-		local message = redis.call('HGETALL', id)
-		messages[i] = message
-		redis.call('HSET', id, 'Status', 1)
-	end
-	redis.call('DEL', KEYS[1])
-	return messages
-`
-
-const setGroupMessageScript = `
-	redis.call("ZADD", KEYS[1], ARGV[1], KEYS[2])
-	redis.call("HSET", KEYS[2], unpack(ARGV, 2))
-	return 1
-`
-
-type chatService struct{}
+// chatImageExts 是 UploadImage 接受的图片格式及保存时使用的扩展名
+var chatImageExts = map[string]string{
+	"png":  ".png",
+	"jpeg": ".jpg",
+	"gif":  ".gif",
+}
+
+type chatService struct {
+	me moderation.Engine
+	fr repository.FollowRepostory
+	ur repository.UserRepository
+	mr repository.MessageRepository
+	mc repository.MessageCacheRepository
+	gr repository.GroupRepository
+	vr repository.VideoRepository
+	gs GroupService
+
+	recallWindow time.Duration
+}
 
 type ChatService interface {
 	Chat(uid string, reqMsg []byte) ([]byte, error)
@@ -46,10 +64,22 @@ type ChatService interface {
 	handlePrivateUnread(uid string, msg *model.WSMessage) ([]byte, error)
 	handleGroupMessage(uid string, msg *model.WSMessage) ([]byte, error)
 	handleGroupHistory(uid string, msg *model.WSMessage) ([]byte, error)
+	handleGroupAction(uid string, msg *model.WSMessage) ([]byte, error)
+	handleMessageAck(uid string, msg *model.WSMessage) ([]byte, error)
+	handleTyping(uid string, msg *model.WSMessage) ([]byte, error)
+	handleMessageRecall(uid string, msg *model.WSMessage) ([]byte, error)
+	handleMessageReaction(uid string, msg *model.WSMessage) ([]byte, error)
+	UploadImage(uid, data string) (string, error)
+	GetConversations(uid string, pageNum, pageSize int64) ([]*model.Conversation, int64, error)
+	ImportLegacyMessages() (int, error)
 }
 
-func NewChatService() ChatService {
-	return &chatService{}
+// NewChatService recallWindow 为发送后允许撤回的时长，不大于 0 时使用默认值
+func NewChatService(me moderation.Engine, fr repository.FollowRepostory, ur repository.UserRepository, mr repository.MessageRepository, mc repository.MessageCacheRepository, gr repository.GroupRepository, vr repository.VideoRepository, recallWindow time.Duration) ChatService {
+	if recallWindow <= 0 {
+		recallWindow = defaultRecallWindow
+	}
+	return &chatService{me: me, fr: fr, ur: ur, mr: mr, mc: mc, gr: gr, vr: vr, gs: NewGroupService(gr, ur, fr, me), recallWindow: recallWindow}
 }
 
 func (cs *chatService) Chat(uid string, reqMsg []byte) ([]byte, error) {
@@ -69,6 +99,17 @@ func (cs *chatService) Chat(uid string, reqMsg []byte) ([]byte, error) {
 		return cs.handleGroupMessage(uid, &msg)
 	case model.TypeGroupHistory:
 		return cs.handleGroupHistory(uid, &msg)
+	case model.TypeGroupCreate, model.TypeGroupInvite, model.TypeGroupJoin, model.TypeGroupLeave,
+		model.TypeGroupKick, model.TypeGroupTransfer, model.TypeGroupRename, model.TypeGroupSetRole:
+		return cs.handleGroupAction(uid, &msg)
+	case model.TypeMessageAck:
+		return cs.handleMessageAck(uid, &msg)
+	case model.TypeTyping:
+		return cs.handleTyping(uid, &msg)
+	case model.TypeMessageRecall:
+		return cs.handleMessageRecall(uid, &msg)
+	case model.TypeMessageReaction:
+		return cs.handleMessageReaction(uid, &msg)
 	default:
 		return cs.sendError(msg.Type, "unknown message type", nil)
 	}
@@ -84,26 +125,54 @@ func (cs *chatService) handlePrivateMessage(uid string, msg *model.WSMessage) ([
 	if err := json.Unmarshal(dataBytes, &privateMsg); err != nil {
 		return cs.sendError(msg.Type, "failed to unmarshal private message", err)
 	}
-
-	privateMsg.Id = util.GetID()
-	privateMsg.Status = 0
-	privateMsg.Time = time.Now().Unix()
-	privateMsg.UserId = uid
-	args := []interface{}{
-		strconv.FormatFloat(float64(privateMsg.Time), 'f', -1, 64), // ARGV[1]: score
-		"Id", privateMsg.Id,
-		"UserId", privateMsg.UserId,
-		"ToUserId", privateMsg.ToUserId,
-		"Content", privateMsg.Content,
-		"Time", strconv.FormatInt(privateMsg.Time, 10),
-		"Status", strconv.Itoa(privateMsg.Status),
+	if privateMsg.ToUserId == "" || privateMsg.ToUserId == uid {
+		return cs.sendRejected(msg.Type, ErrInvalidTarget)
 	}
-	instance := database.GetRedisInstance()
-	ctx := context.Background()
-	_, err = instance.Eval(ctx, setPrivateMessageScript, []string{"message:all:" + privateMsg.UserId + ":" + privateMsg.ToUserId, "message:unread:" + privateMsg.UserId + ":" + privateMsg.ToUserId, privateMsg.Id}, args)
+	users, err := getUsersById(cs.ur, []string{privateMsg.ToUserId})
 	if err != nil {
-		return cs.sendError(msg.Type, "failed to add private message to redis", err)
+		return cs.sendError(msg.Type, "failed to get receiver", err)
 	}
+	if users[privateMsg.ToUserId] == nil {
+		return cs.sendRejected(msg.Type, ErrInvalidTarget)
+	}
+	if err := checkBlocked(cs.fr, uid, privateMsg.ToUserId); err != nil {
+		if errors.Is(err, ErrBlocked) {
+			return cs.sendRejected(msg.Type, err)
+		}
+		return cs.sendError(msg.Type, "failed to check block", err)
+	}
+	if !cs.moderate(&privateMsg.Content) {
+		return cs.sendRejected(msg.Type, ErrContentRejected)
+	}
+
+	message := &model.Message{
+		Id:             util.GetID(),
+		ConversationId: model.PrivateConversationId(uid, privateMsg.ToUserId),
+		SenderId:       uid,
+		ReceiverId:     privateMsg.ToUserId,
+		CreatedAt:      time.Now(),
+	}
+	if err := cs.applyBody(message, &privateMsg.MessageBody); err != nil {
+		if isMessageRejection(err) {
+			return cs.sendRejected(msg.Type, err)
+		}
+		return cs.sendError(msg.Type, "failed to check message body", err)
+	}
+	userA, userB := uid, privateMsg.ToUserId
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	if err := cs.saveMessage(message, &model.Conversation{
+		Id:            message.ConversationId,
+		Type:          model.ConversationPrivate,
+		UserA:         userA,
+		UserB:         userB,
+		LastMessageId: message.Id,
+		LastMessageAt: message.CreatedAt,
+	}); err != nil {
+		return cs.sendError(msg.Type, "failed to save private message", err)
+	}
+	cs.pushPrivate(message)
 
 	resMsg, err := json.Marshal(&model.WSMessage{
 		Type: msg.Type,
@@ -126,52 +195,20 @@ func (cs *chatService) handlePrivateHistory(uid string, msg *model.WSMessage) ([
 		return cs.sendError(msg.Type, "failed to unmarshal history request", err)
 	}
 
-	instance := database.GetRedisInstance()
-	ctx := context.Background()
-	ids, err := instance.ZRevRange(ctx, "message:all:"+uid+":"+historyReq.TargetUserId, (historyReq.PageNum-1)*historyReq.PageSize-1, historyReq.PageNum*historyReq.PageSize)
+	messages, err := cs.getHistory(model.PrivateConversationId(uid, historyReq.TargetUserId), historyReq.BeforeMessageId, historyReq.PageSize)
 	if err != nil {
-		return cs.sendError(msg.Type, "failed to get history message ids from redis", err)
-	}
-	privateMsgs := make([]*model.PrivateMsg, len(ids))
-	for i, id := range ids {
-		hash, err := instance.HGetAll(ctx, id)
-		if err != nil {
-			return cs.sendError(msg.Type, "failed to get history message from redis by id", err)
-		}
-		var privateMsg model.PrivateMsg
-		privateMsg.Id = id
-		userId, ok := hash["UserId"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse message userId", err)
-		}
-		privateMsg.UserId = userId
-		toUserId, ok := hash["ToUserId"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse message toUserId", err)
-		}
-		privateMsg.ToUserId = toUserId
-		content, ok := hash["Content"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse message content", err)
-		}
-		privateMsg.Content = content
-		t1, ok := hash["Time"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse message time", err)
-		}
-		t2, err := strconv.ParseInt(t1, 10, 64)
-		if err != nil {
-			return cs.sendError(msg.Type, "failed to parse string to int64", err)
-		}
-		privateMsg.Time = t2
-		sts, ok := hash["Status"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse message status", err)
+		if errors.Is(err, ErrMessageNotFound) {
+			return cs.sendRejected(msg.Type, err)
 		}
-		status, err := strconv.ParseInt(sts, 10, 64)
-		privateMsg.Status = int(status)
+		return cs.sendError(msg.Type, "failed to get history messages", err)
+	}
+	privateMsgs, err := cs.toPrivateMsgs(uid, historyReq.TargetUserId, messages)
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to get message states", err)
+	}
 
-		privateMsgs[i] = &privateMsg
+	if err := cs.fillPrivateMsgs(uid, messages, privateMsgs); err != nil {
+		return cs.sendError(msg.Type, "failed to fill messages", err)
 	}
 
 	resMsg, err := json.Marshal(&model.WSMessage{
@@ -195,79 +232,18 @@ func (cs *chatService) handlePrivateUnread(uid string, msg *model.WSMessage) ([]
 		return cs.sendError(msg.Type, "failed to unmarshal history request", err)
 	}
 
-	instance := database.GetRedisInstance()
-	ctx := context.Background()
-	keys := []string{
-		"message:unread:" + uid + ":" + unreadHistoryReq.TargetUserId,
-	}
-	args := []interface{}{
-		0,
-		-1,
-	}
-	result, err := instance.Eval(ctx, getUnreadHistoryMessageScript, keys, args)
+	// 只返回未读消息，不移动已读位置，已读由客户端通过 TypeMessageAck 确认
+	messages, err := cs.mr.GetUnreadMessages(model.PrivateConversationId(uid, unreadHistoryReq.TargetUserId), uid)
 	if err != nil {
-		return cs.sendError(msg.Type, "failed to get unread history message ids from redis", err)
+		return cs.sendError(msg.Type, "failed to get unread messages", err)
 	}
-	list, ok := result.([]interface{})
-	if !ok {
-		return cs.sendError(msg.Type, "failed to parse unread history message list", err)
+	privateMsgs, err := cs.toPrivateMsgs(uid, unreadHistoryReq.TargetUserId, messages)
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to get message states", err)
 	}
-	privateMsgs := make([]*model.PrivateMsg, len(list))
-	for i, v := range list {
-		msgSlice, ok := v.([]interface{})
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse message list", err)
-		}
-		var privateMsg model.PrivateMsg
-		for j := 0; j < len(msgSlice); j++ {
-			switch j {
-			case 1:
-				id, ok := msgSlice[j].(string)
-				if !ok {
-					return cs.sendError(msg.Type, "failed to parse message id", err)
-				}
-				privateMsg.Id = id
-			case 3:
-				userId, ok := msgSlice[j].(string)
-				if !ok {
-					return cs.sendError(msg.Type, "failed to parse message userId", err)
-				}
-				privateMsg.UserId = userId
-			case 5:
-				toUserId, ok := msgSlice[j].(string)
-				if !ok {
-					cs.sendError(msg.Type, "failed to parse message toUserId", err)
-				}
-				privateMsg.ToUserId = toUserId
-			case 7:
-				content, ok := msgSlice[j].(string)
-				if !ok {
-					cs.sendError(msg.Type, "failed to parse message content", err)
-				}
-				privateMsg.Content = content
-			case 9:
-				t1, ok := msgSlice[j].(string)
-				if !ok {
-					cs.sendError(msg.Type, "failed to parse message time", err)
-				}
-				t2, err := strconv.ParseInt(t1, 10, 64)
-				if err != nil {
-					cs.sendError(msg.Type, "failed to parse time", err)
-				}
-				privateMsg.Time = t2
-			case 11:
-				sts, ok := msgSlice[j].(string)
-				if !ok {
-					cs.sendError(msg.Type, "failed to parse message status", err)
-				}
-				status, err := strconv.ParseInt(sts, 10, 64)
-				if err != nil {
-					cs.sendError(msg.Type, "failed to parse status", err)
-				}
-				privateMsg.Status = int(status)
-			}
-		}
-		privateMsgs[i] = &privateMsg
+
+	if err := cs.fillPrivateMsgs(uid, messages, privateMsgs); err != nil {
+		return cs.sendError(msg.Type, "failed to fill messages", err)
 	}
 
 	resMsg, err := json.Marshal(&model.WSMessage{
@@ -290,29 +266,39 @@ func (cs *chatService) handleGroupMessage(uid string, msg *model.WSMessage) ([]b
 	if err := json.Unmarshal(dataBytes, &groupMsg); err != nil {
 		return cs.sendError(msg.Type, "failed to unmarshal group message", err)
 	}
+	if _, err := getGroupMember(cs.gr, groupMsg.GroupId, uid); err != nil {
+		if errors.Is(err, ErrNotGroupMember) {
+			return cs.sendRejected(msg.Type, err)
+		}
+		return cs.sendError(msg.Type, "failed to check group member", err)
+	}
+	if !cs.moderate(&groupMsg.Content) {
+		return cs.sendRejected(msg.Type, ErrContentRejected)
+	}
 
-	groupMsg.Id = util.GetID()
-	groupMsg.UserId = uid
-	groupMsg.Time = time.Now().Unix()
-
-	instance := database.GetRedisInstance()
-	ctx := context.Background()
-	keys := []string{
-		"group:" + groupMsg.GroupId,
-		"group:message:" + groupMsg.Id,
+	message := &model.Message{
+		Id:             util.GetID(),
+		ConversationId: model.GroupConversationId(groupMsg.GroupId),
+		SenderId:       uid,
+		GroupId:        groupMsg.GroupId,
+		CreatedAt:      time.Now(),
 	}
-	args := []interface{}{
-		strconv.FormatFloat(float64(groupMsg.Time), 'f', -1, 64), // ARGV[1]: score
-		"Id", groupMsg.Id,
-		"GroupId", groupMsg.GroupId,
-		"UserId", groupMsg.UserId,
-		"Content", groupMsg.Content,
-		"Time", strconv.FormatInt(groupMsg.Time, 10),
+	if err := cs.applyBody(message, &groupMsg.MessageBody); err != nil {
+		if isMessageRejection(err) {
+			return cs.sendRejected(msg.Type, err)
+		}
+		return cs.sendError(msg.Type, "failed to check message body", err)
 	}
-	_, err = instance.Eval(ctx, setGroupMessageScript, keys, args)
-	if err != nil {
-		return cs.sendError(msg.Type, "failed to add group message to redis", err)
+	if err := cs.saveMessage(message, &model.Conversation{
+		Id:            message.ConversationId,
+		Type:          model.ConversationGroup,
+		GroupId:       groupMsg.GroupId,
+		LastMessageId: message.Id,
+		LastMessageAt: message.CreatedAt,
+	}); err != nil {
+		return cs.sendError(msg.Type, "failed to save group message", err)
 	}
+	cs.pushGroup(message)
 
 	resMsg, err := json.Marshal(&model.WSMessage{
 		Type: msg.Type,
@@ -335,55 +321,397 @@ func (cs *chatService) handleGroupHistory(uid string, msg *model.WSMessage) ([]b
 		return cs.sendError(msg.Type, "failed to unmarshal group history message", err)
 	}
 
-	instance := database.GetRedisInstance()
-	ctx := context.Background()
-	keys, err := instance.ZRevRange(ctx, "group:"+groupHistoryMsg.GroupId, (groupHistoryMsg.PageNum-1)*groupHistoryMsg.PageSize, groupHistoryMsg.PageNum*groupHistoryMsg.PageSize-1)
+	if _, err := getGroupMember(cs.gr, groupHistoryMsg.GroupId, uid); err != nil {
+		if errors.Is(err, ErrNotGroupMember) {
+			return cs.sendRejected(msg.Type, err)
+		}
+		return cs.sendError(msg.Type, "failed to check group member", err)
+	}
+
+	messages, err := cs.getHistory(model.GroupConversationId(groupHistoryMsg.GroupId), groupHistoryMsg.BeforeMessageId, groupHistoryMsg.PageSize)
+	if err != nil {
+		if errors.Is(err, ErrMessageNotFound) {
+			return cs.sendRejected(msg.Type, err)
+		}
+		return cs.sendError(msg.Type, "failed to get group history messages", err)
+	}
+	groupMsgs := make([]*model.GroupMessage, len(messages))
+	for i, m := range messages {
+		groupMsgs[i] = model.MessageToGroupMessage(m)
+	}
+
+	if err := cs.fillGroupMsgs(uid, messages, groupMsgs); err != nil {
+		return cs.sendError(msg.Type, "failed to fill messages", err)
+	}
+
+	resMsg, err := json.Marshal(&model.WSMessage{
+		Type: msg.Type,
+		Data: groupMsgs,
+	})
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message", err)
+	}
+	return resMsg, nil
+}
+
+// handleMessageAck 移动确认方在会话中的送达或已读位置，位置前进时把回执推送给消息发送方
+func (cs *chatService) handleMessageAck(uid string, msg *model.WSMessage) ([]byte, error) {
+	dataBytes, err := json.Marshal(msg.Data)
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message data", err)
+	}
+
+	var ack model.MessageAck
+	if err := json.Unmarshal(dataBytes, &ack); err != nil {
+		return cs.sendError(msg.Type, "failed to unmarshal message ack", err)
+	}
+	if ack.State != model.MessageStateDelivered && ack.State != model.MessageStateRead {
+		return cs.sendRejected(msg.Type, ErrInvalidAction)
+	}
+
+	message, err := cs.mr.GetMessageById(ack.MessageId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cs.sendRejected(msg.Type, ErrMessageNotFound)
+		}
+		return cs.sendError(msg.Type, "failed to get message", err)
+	}
+	if message.GroupId != "" {
+		if _, err := getGroupMember(cs.gr, message.GroupId, uid); err != nil {
+			if errors.Is(err, ErrNotGroupMember) {
+				return cs.sendRejected(msg.Type, err)
+			}
+			return cs.sendError(msg.Type, "failed to check group member", err)
+		}
+	} else if message.ReceiverId != uid {
+		return cs.sendRejected(msg.Type, ErrPermissionDenied)
+	}
+
+	read := &model.ConversationRead{
+		ConversationId:     message.ConversationId,
+		Uid:                uid,
+		DeliveredMessageId: message.Id,
+		DeliveredAt:        &message.CreatedAt,
+	}
+	if ack.State == model.MessageStateRead {
+		read.ReadMessageId = message.Id
+		read.ReadAt = &message.CreatedAt
+	}
+	advanced, err := cs.mr.MarkConversation(read)
 	if err != nil {
-		return cs.sendError(msg.Type, "failed to get group message keys from redis", err)
+		return cs.sendError(msg.Type, "failed to mark conversation", err)
+	}
+	if advanced && message.SenderId != uid {
+		cs.pushReceipt(uid, message, ack.State)
 	}
 
-	groupMsgs := make([]*model.GroupMessage, len(keys))
-	for i, id := range keys {
-		hash, err := instance.HGetAll(ctx, id)
+	resMsg, err := json.Marshal(&model.WSMessage{
+		Type: msg.Type,
+		Data: "success",
+	})
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message", err)
+	}
+	return resMsg, nil
+}
+
+// handleTyping 把输入状态转发给对方或群内其他成员，不回复发送方
+func (cs *chatService) handleTyping(uid string, msg *model.WSMessage) ([]byte, error) {
+	dataBytes, err := json.Marshal(msg.Data)
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message data", err)
+	}
+
+	var req model.TypingRequest
+	if err := json.Unmarshal(dataBytes, &req); err != nil {
+		return cs.sendError(msg.Type, "failed to unmarshal typing request", err)
+	}
+
+	event := &model.TypingEvent{UserId: uid, GroupId: req.GroupId, Typing: req.Typing}
+	var uids []string
+	if req.GroupId != "" {
+		if _, err := getGroupMember(cs.gr, req.GroupId, uid); err != nil {
+			if errors.Is(err, ErrNotGroupMember) {
+				return cs.sendRejected(msg.Type, err)
+			}
+			return cs.sendError(msg.Type, "failed to check group member", err)
+		}
+		members, err := cs.gr.GetMemberIds(req.GroupId)
 		if err != nil {
-			return cs.sendError(msg.Type, "failed to get group message by id from redis", err)
-		}
-		var groupMsg model.GroupMessage
-		id, ok := hash["Id"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse group history message id", err)
-		}
-		groupMsg.Id = id
-		groupId, ok := hash["GroupId"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse group history message groupId", err)
-		}
-		groupMsg.GroupId = groupId
-		userId, ok := hash["UserId"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse group history message userId", err)
-		}
-		groupMsg.UserId = userId
-		content, ok := hash["Content"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse group history message content", err)
-		}
-		groupMsg.Content = content
-		t1, ok := hash["Time"]
-		if !ok {
-			return cs.sendError(msg.Type, "failed to parse group history message time", err)
-		}
-		t2, err := strconv.ParseInt(t1, 10, 64)
+			return cs.sendError(msg.Type, "failed to get group member ids", err)
+		}
+		for _, m := range members {
+			if m != uid {
+				uids = append(uids, m)
+			}
+		}
+		event.ConversationId = model.GroupConversationId(req.GroupId)
+	} else {
+		if req.TargetUserId == "" || req.TargetUserId == uid {
+			return cs.sendRejected(msg.Type, ErrInvalidTarget)
+		}
+		if err := checkBlocked(cs.fr, uid, req.TargetUserId); err != nil {
+			if errors.Is(err, ErrBlocked) {
+				return cs.sendRejected(msg.Type, err)
+			}
+			return cs.sendError(msg.Type, "failed to check block", err)
+		}
+		uids = []string{req.TargetUserId}
+		event.ConversationId = model.PrivateConversationId(uid, req.TargetUserId)
+	}
+
+	data, err := json.Marshal(&model.WSMessage{
+		Type: msg.Type,
+		Data: event,
+	})
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message", err)
+	}
+	if len(uids) > 0 {
+		hub.GetHub().SendMany(uids, data)
+	}
+	return nil, nil
+}
+
+// pushReceipt 推送给消息发送方以及确认方自己的其他连接
+func (cs *chatService) pushReceipt(uid string, message *model.Message, state string) {
+	data, err := json.Marshal(&model.WSMessage{
+		Type: model.TypeMessageReceipt,
+		Data: &model.MessageReceipt{
+			ConversationId: message.ConversationId,
+			GroupId:        message.GroupId,
+			UserId:         uid,
+			MessageId:      message.Id,
+			State:          state,
+			Time:           time.Now().Unix(),
+		},
+	})
+	if err != nil {
+		log.Printf("failed to marshal receipt: id: %s, err: %v", message.Id, err)
+		return
+	}
+	hub.GetHub().SendMany([]string{message.SenderId, uid}, data)
+}
+
+// toPrivateMsgs 根据双方在会话中的位置计算每条消息的状态：
+// 自己发出的消息看对方的位置，对方发来的消息看自己的位置
+func (cs *chatService) toPrivateMsgs(uid, targetId string, messages []*model.Message) ([]*model.PrivateMsg, error) {
+	privateMsgs := make([]*model.PrivateMsg, len(messages))
+	if len(messages) == 0 {
+		return privateMsgs, nil
+	}
+	convId := model.PrivateConversationId(uid, targetId)
+	reads, err := cs.mr.GetConversationReads(convId, []string{uid, targetId})
+	if err != nil {
+		log.Printf("failed to get conversation reads: conversationId: %s, err: %v", convId, err)
+		return nil, err
+	}
+	for i, m := range messages {
+		privateMsgs[i] = model.MessageToPrivateMsg(m)
+		privateMsgs[i].State = model.MessageState(m, reads[m.ReceiverId])
+	}
+	return privateMsgs, nil
+}
+
+// handleMessageRecall 只有发送方可以撤回，撤回后通知会话中的所有成员
+func (cs *chatService) handleMessageRecall(uid string, msg *model.WSMessage) ([]byte, error) {
+	dataBytes, err := json.Marshal(msg.Data)
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message data", err)
+	}
+
+	var req model.MessageRecallRequest
+	if err := json.Unmarshal(dataBytes, &req); err != nil {
+		return cs.sendError(msg.Type, "failed to unmarshal recall request", err)
+	}
+
+	message, err := cs.mr.GetMessageById(req.MessageId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cs.sendRejected(msg.Type, ErrMessageNotFound)
+		}
+		return cs.sendError(msg.Type, "failed to get message", err)
+	}
+	if message.SenderId != uid {
+		return cs.sendRejected(msg.Type, ErrPermissionDenied)
+	}
+	if message.RecalledAt == nil && time.Since(message.CreatedAt) > cs.recallWindow {
+		return cs.sendRejected(msg.Type, ErrRecallExpired)
+	}
+
+	recalled, err := cs.mr.RecallMessage(message.Id, time.Now())
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to recall message", err)
+	}
+	// 重复撤回直接返回成功
+	if recalled {
+		// 窗口中保存的是撤回前的消息，清空后由下一次读取从 MySQL 重新加载
+		if err := cs.mc.ClearWindow(message.ConversationId); err != nil {
+			log.Printf("failed to clear chat window: key: %s, err: %v", message.ConversationId, err)
+		}
+		cs.pushToParticipants(message, model.TypeMessageRecall, &model.MessageRecallEvent{
+			ConversationId: message.ConversationId,
+			GroupId:        message.GroupId,
+			MessageId:      message.Id,
+			UserId:         uid,
+		})
+	}
+
+	resMsg, err := json.Marshal(&model.WSMessage{
+		Type: msg.Type,
+		Data: "success",
+	})
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message", err)
+	}
+	return resMsg, nil
+}
+
+// handleMessageReaction 会话中的成员可以对未撤回的消息添加或取消表情
+func (cs *chatService) handleMessageReaction(uid string, msg *model.WSMessage) ([]byte, error) {
+	dataBytes, err := json.Marshal(msg.Data)
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message data", err)
+	}
+
+	var req model.MessageReactionRequest
+	if err := json.Unmarshal(dataBytes, &req); err != nil {
+		return cs.sendError(msg.Type, "failed to unmarshal reaction request", err)
+	}
+	if !isEmoji(req.Emoji) {
+		return cs.sendRejected(msg.Type, ErrInvalidEmoji)
+	}
+
+	message, err := cs.mr.GetMessageById(req.MessageId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cs.sendRejected(msg.Type, ErrMessageNotFound)
+		}
+		return cs.sendError(msg.Type, "failed to get message", err)
+	}
+	if err := cs.checkParticipant(uid, message); err != nil {
+		if isMessageRejection(err) {
+			return cs.sendRejected(msg.Type, err)
+		}
+		return cs.sendError(msg.Type, "failed to check participant", err)
+	}
+	if message.RecalledAt != nil {
+		return cs.sendRejected(msg.Type, ErrMessageRecalled)
+	}
+
+	var changed bool
+	if req.Remove {
+		changed, err = cs.mr.RemoveReaction(message.Id, uid, req.Emoji)
+	} else {
+		changed, err = cs.mr.AddReaction(&model.MessageReaction{
+			MessageId: message.Id,
+			Uid:       uid,
+			Emoji:     req.Emoji,
+		})
+	}
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to update reaction", err)
+	}
+	if changed {
+		cs.pushToParticipants(message, model.TypeMessageReaction, &model.MessageReactionEvent{
+			ConversationId: message.ConversationId,
+			GroupId:        message.GroupId,
+			MessageId:      message.Id,
+			UserId:         uid,
+			Emoji:          req.Emoji,
+			Removed:        req.Remove,
+		})
+	}
+
+	resMsg, err := json.Marshal(&model.WSMessage{
+		Type: msg.Type,
+		Data: "success",
+	})
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message", err)
+	}
+	return resMsg, nil
+}
+
+// checkParticipant 私聊只有双方可以操作，群聊需要是群成员
+func (cs *chatService) checkParticipant(uid string, message *model.Message) error {
+	if message.GroupId != "" {
+		_, err := getGroupMember(cs.gr, message.GroupId, uid)
+		return err
+	}
+	if message.SenderId != uid && message.ReceiverId != uid {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// pushToParticipants 推送给私聊双方或群内所有成员的在线连接
+func (cs *chatService) pushToParticipants(message *model.Message, msgType int, event interface{}) {
+	uids := []string{message.ReceiverId, message.SenderId}
+	if message.GroupId != "" {
+		var err error
+		uids, err = cs.gr.GetMemberIds(message.GroupId)
 		if err != nil {
-			return cs.sendError(msg.Type, "failed to parse string to int64", err)
+			log.Printf("failed to get group member ids: groupId: %s, err: %v", message.GroupId, err)
+			return
+		}
+	}
+	data, err := json.Marshal(&model.WSMessage{
+		Type: msgType,
+		Data: event,
+	})
+	if err != nil {
+		log.Printf("failed to marshal message: id: %s, err: %v", message.Id, err)
+		return
+	}
+	hub.GetHub().SendMany(uids, data)
+}
+
+// handleGroupAction 处理群管理消息，创建和加入群返回群信息，其余返回 success
+func (cs *chatService) handleGroupAction(uid string, msg *model.WSMessage) ([]byte, error) {
+	dataBytes, err := json.Marshal(msg.Data)
+	if err != nil {
+		return cs.sendError(msg.Type, "failed to marshal message data", err)
+	}
+
+	var req model.GroupActionRequest
+	if err := json.Unmarshal(dataBytes, &req); err != nil {
+		return cs.sendError(msg.Type, "failed to unmarshal group action", err)
+	}
+
+	var data interface{} = "success"
+	var group *model.Group
+	switch msg.Type {
+	case model.TypeGroupCreate:
+		group, err = cs.gs.CreateGroup(uid, req.Title, req.UserIds)
+	case model.TypeGroupInvite:
+		err = cs.gs.Invite(uid, req.GroupId, req.UserIds)
+	case model.TypeGroupJoin:
+		group, err = cs.gs.Join(uid, req.InviteCode)
+	case model.TypeGroupLeave:
+		err = cs.gs.Leave(uid, req.GroupId)
+	case model.TypeGroupKick:
+		err = cs.gs.Kick(uid, req.GroupId, req.UserId)
+	case model.TypeGroupTransfer:
+		err = cs.gs.Transfer(uid, req.GroupId, req.UserId)
+	case model.TypeGroupRename:
+		err = cs.gs.Rename(uid, req.GroupId, req.Title)
+	case model.TypeGroupSetRole:
+		err = cs.gs.SetRole(uid, req.GroupId, req.UserId, req.Role)
+	}
+	if err != nil {
+		if isGroupRejection(err) {
+			return cs.sendRejected(msg.Type, err)
 		}
-		groupMsg.Time = t2
-		groupMsgs[i] = &groupMsg
+		return cs.sendError(msg.Type, "failed to handle group action", err)
+	}
+	if group != nil {
+		data = model.GroupToResGroup(group)
 	}
 
 	resMsg, err := json.Marshal(&model.WSMessage{
 		Type: msg.Type,
-		Data: groupMsgs,
+		Data: data,
 	})
 	if err != nil {
 		return cs.sendError(msg.Type, "failed to marshal message", err)
@@ -391,6 +719,509 @@ func (cs *chatService) handleGroupHistory(uid string, msg *model.WSMessage) ([]b
 	return resMsg, nil
 }
 
+// isGroupRejection 判断群管理的错误是否由请求本身导致，这类错误直接告知发送方
+func isGroupRejection(err error) bool {
+	for _, e := range []error{ErrGroupNotFound, ErrNotGroupMember, ErrInvalidGroupTitle, ErrInvalidGroupRole,
+		ErrOwnerCannotLeave, ErrPermissionDenied, ErrSelfRelation, ErrUserNotFound, ErrBlocked, ErrContentRejected} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveMessage 先写入 MySQL，再追加到 redis 窗口；追加失败只记录日志，窗口过期后会从 MySQL 重新加载。
+// 发送时间截断到毫秒，与 MySQL 中保存的精度一致，追加和加载的同一条消息序列化结果相同
+func (cs *chatService) saveMessage(message *model.Message, conv *model.Conversation) error {
+	message.CreatedAt = message.CreatedAt.Truncate(time.Millisecond)
+	conv.LastMessageAt = message.CreatedAt
+	if err := cs.mr.CreateMessage(message, conv); err != nil {
+		return err
+	}
+	if err := cs.mc.AppendWindow(message); err != nil {
+		log.Printf("failed to append chat window: key: %s, err: %v", message.ConversationId, err)
+	}
+	return nil
+}
+
+// pushPrivate 推送给接收方以及发送方的所有在线连接，用于多端同步
+func (cs *chatService) pushPrivate(message *model.Message) {
+	privateMsg := model.MessageToPrivateMsg(message)
+	if err := cs.fillPrivateMsgs("", []*model.Message{message}, []*model.PrivateMsg{privateMsg}); err != nil {
+		log.Printf("failed to fill message: id: %s, err: %v", message.Id, err)
+	}
+	data, err := json.Marshal(&model.WSMessage{
+		Type: model.TypePrivateMessage,
+		Data: privateMsg,
+	})
+	if err != nil {
+		log.Printf("failed to marshal message: id: %s, err: %v", message.Id, err)
+		return
+	}
+	hub.GetHub().SendMany([]string{message.ReceiverId, message.SenderId}, data)
+}
+
+// pushGroup 推送给群内所有成员的在线连接
+func (cs *chatService) pushGroup(message *model.Message) {
+	uids, err := cs.gr.GetMemberIds(message.GroupId)
+	if err != nil {
+		log.Printf("failed to get group member ids: groupId: %s, err: %v", message.GroupId, err)
+		return
+	}
+	groupMsg := model.MessageToGroupMessage(message)
+	if err := cs.fillGroupMsgs("", []*model.Message{message}, []*model.GroupMessage{groupMsg}); err != nil {
+		log.Printf("failed to fill message: id: %s, err: %v", message.Id, err)
+	}
+	data, err := json.Marshal(&model.WSMessage{
+		Type: model.TypeGroupMessage,
+		Data: groupMsg,
+	})
+	if err != nil {
+		log.Printf("failed to marshal message: id: %s, err: %v", message.Id, err)
+		return
+	}
+	hub.GetHub().SendMany(uids, data)
+}
+
+// getHistory 从新到旧返回早于 beforeId 的消息，beforeId 为空时从最新一条开始；
+// 窗口未加载时先从 MySQL 加载最新的一段，窗口中不够一页时直接查询 MySQL
+func (cs *chatService) getHistory(conversationId, beforeId string, pageSize int64) ([]*model.Message, error) {
+	if pageSize <= 0 {
+		pageSize = defaultChatPageSize
+	}
+	if pageSize > maxChatPageSize {
+		pageSize = maxChatPageSize
+	}
+
+	window, loaded, err := cs.mc.GetWindow(conversationId)
+	if err != nil {
+		log.Printf("failed to get chat window: key: %s, err: %v", conversationId, err)
+	}
+	if err == nil && !loaded {
+		window, err = cs.mr.GetMessages(conversationId, nil, repository.ChatWindowSize)
+		if err != nil {
+			log.Printf("failed to get messages: conversationId: %s, err: %v", conversationId, err)
+			return nil, err
+		}
+		loaded = true
+		if err := cs.mc.LoadWindow(conversationId, window); err != nil {
+			log.Printf("failed to load chat window: key: %s, err: %v", conversationId, err)
+		}
+	}
+
+	if loaded {
+		rest, found := window, beforeId == ""
+		for i := 0; !found && i < len(window); i++ {
+			if window[i].Id == beforeId {
+				rest, found = window[i+1:], true
+			}
+		}
+		// 窗口不满说明会话的全部消息都在窗口中
+		if found && (len(window) < repository.ChatWindowSize || int64(len(rest)) >= pageSize) {
+			return rest[:min(pageSize, int64(len(rest)))], nil
+		}
+	}
+
+	var before *model.Message
+	if beforeId != "" {
+		before, err = cs.mr.GetMessageById(beforeId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrMessageNotFound
+			}
+			log.Printf("failed to get message by id: id: %s, err: %v", beforeId, err)
+			return nil, err
+		}
+		if before.ConversationId != conversationId {
+			return nil, ErrMessageNotFound
+		}
+	}
+	msgs, err := cs.mr.GetMessages(conversationId, before, pageSize)
+	if err != nil {
+		log.Printf("failed to get messages: conversationId: %s, err: %v", conversationId, err)
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// UploadImage 与头像一样保存在 static 目录下，返回发送图片消息时使用的地址；
+// 只接受 png、jpeg、gif，文件大小和宽高超过限制或无法解析时返回 ErrInvalidImage
+func (cs *chatService) UploadImage(uid, data string) (string, error) {
+	if len(data) > maxChatImageBytes/3*4+1024 {
+		return "", ErrInvalidImage
+	}
+	raw, err := util.DecodeBase64Data(data)
+	if err != nil || len(raw) > maxChatImageBytes {
+		return "", ErrInvalidImage
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return "", ErrInvalidImage
+	}
+	ext, ok := chatImageExts[format]
+	if !ok || cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxChatImageSide || cfg.Height > maxChatImageSide {
+		return "", ErrInvalidImage
+	}
+
+	url := chatImageDir + "/" + util.GetID() + ext
+	if err := util.SaveFile(raw, "."+url); err != nil {
+		log.Printf("failed to save image file: uid: %s, error: %v", uid, err)
+		return "", err
+	}
+	return url, nil
+}
+
+// GetConversations 返回用户的私聊会话列表，按最近一条消息的时间从新到旧排列
+func (cs *chatService) GetConversations(uid string, pageNum, pageSize int64) ([]*model.Conversation, int64, error) {
+	convs, total, err := cs.mr.GetPrivateConversations(uid, pageNum, pageSize)
+	if err != nil {
+		log.Printf("failed to get conversations: uid: %s, err: %v", uid, err)
+		return nil, 0, err
+	}
+
+	convIds := make([]string, len(convs))
+	peerIds := make([]string, len(convs))
+	msgIds := make([]string, len(convs))
+	for i, c := range convs {
+		convIds[i] = c.Id
+		peerIds[i] = c.PeerId(uid)
+		msgIds[i] = c.LastMessageId
+	}
+	peers, err := getUsersById(cs.ur, peerIds)
+	if err != nil {
+		return nil, 0, err
+	}
+	msgs, err := cs.mr.GetMessagesByIds(msgIds)
+	if err != nil {
+		log.Printf("failed to get messages by ids: ids: %v, err: %v", msgIds, err)
+		return nil, 0, err
+	}
+	lastMsgs := make(map[string]*model.Message, len(msgs))
+	for _, m := range msgs {
+		lastMsgs[m.Id] = m
+	}
+	unread, err := cs.mr.CountUnread(uid, convIds)
+	if err != nil {
+		log.Printf("failed to count unread messages: uid: %s, err: %v", uid, err)
+		return nil, 0, err
+	}
+
+	for _, c := range convs {
+		c.Peer = peers[c.PeerId(uid)]
+		c.LastMessage = lastMsgs[c.LastMessageId]
+		c.UnreadCount = unread[c.Id]
+	}
+	return convs, total, nil
+}
+
+// ImportLegacyMessages 把旧版本只保存在 redis 中的消息导入 MySQL，返回读取到的消息数；
+// 旧版本没有群信息，群聊消息的发送者会被加为成员，最早发言的人成为群主，群名称使用 legacyGroupTitle
+func (cs *chatService) ImportLegacyMessages() (int, error) {
+	total := 0
+	err := cs.mc.ScanLegacyMessages(func(msgs []*model.Message) error {
+		if len(msgs) == 0 {
+			return nil
+		}
+		last := make(map[string]*model.Message)
+		first := make(map[string]*model.Message)
+		senders := make(map[string][]string)
+		seen := make(map[string]bool)
+		for _, m := range msgs {
+			if l, ok := last[m.ConversationId]; !ok || m.CreatedAt.After(l.CreatedAt) {
+				last[m.ConversationId] = m
+			}
+			if m.GroupId == "" {
+				continue
+			}
+			if f, ok := first[m.GroupId]; !ok || m.CreatedAt.Before(f.CreatedAt) {
+				first[m.GroupId] = m
+			}
+			if !seen[m.GroupId+":"+m.SenderId] {
+				seen[m.GroupId+":"+m.SenderId] = true
+				senders[m.GroupId] = append(senders[m.GroupId], m.SenderId)
+			}
+		}
+		for groupId, f := range first {
+			group := &model.Group{
+				Id:         groupId,
+				Title:      legacyGroupTitle,
+				OwnerId:    f.SenderId,
+				InviteCode: newInviteCode(),
+			}
+			if err := cs.gr.ImportGroup(group, senders[groupId]); err != nil {
+				log.Printf("failed to import group: groupId: %s, err: %v", groupId, err)
+				return err
+			}
+		}
+		convs := make([]*model.Conversation, 0, len(last))
+		// 旧版本的未读状态并不可靠，导入的私聊消息对双方都视为已读
+		var reads []*model.ConversationRead
+		for convId, m := range last {
+			conv := &model.Conversation{
+				Id:            convId,
+				LastMessageId: m.Id,
+				LastMessageAt: m.CreatedAt,
+			}
+			if m.GroupId != "" {
+				conv.Type = model.ConversationGroup
+				conv.GroupId = m.GroupId
+			} else {
+				conv.Type = model.ConversationPrivate
+				conv.UserA, conv.UserB = m.SenderId, m.ReceiverId
+				if conv.UserA > conv.UserB {
+					conv.UserA, conv.UserB = conv.UserB, conv.UserA
+				}
+				for _, uid := range []string{conv.UserA, conv.UserB} {
+					reads = append(reads, &model.ConversationRead{
+						ConversationId:     convId,
+						Uid:                uid,
+						DeliveredMessageId: m.Id,
+						DeliveredAt:        &m.CreatedAt,
+						ReadMessageId:      m.Id,
+						ReadAt:             &m.CreatedAt,
+					})
+				}
+			}
+			convs = append(convs, conv)
+		}
+		if err := cs.mr.ImportMessages(msgs, convs, reads); err != nil {
+			return err
+		}
+		for convId := range last {
+			if err := cs.mc.ClearWindow(convId); err != nil {
+				log.Printf("failed to clear chat window: key: %s, err: %v", convId, err)
+			}
+		}
+		total += len(msgs)
+		return nil
+	})
+	return total, err
+}
+
+// fillPrivateMsgs 一次批量查询为一页私聊消息填充发送者信息，再填充消息内容，msgs 与 messages 一一对应
+func (cs *chatService) fillPrivateMsgs(uid string, messages []*model.Message, msgs []*model.PrivateMsg) error {
+	uids := make([]string, len(msgs))
+	for i, m := range msgs {
+		uids[i] = m.UserId
+	}
+	users, err := getUsersById(cs.ur, uids)
+	if err != nil {
+		return err
+	}
+	bodies := make([]*model.MessageBody, len(msgs))
+	for i, m := range msgs {
+		m.Sender = model.UserToChatUser(users[m.UserId])
+		bodies[i] = &m.MessageBody
+	}
+	return cs.fillBodies(uid, messages, bodies)
+}
+
+func (cs *chatService) fillGroupMsgs(uid string, messages []*model.Message, msgs []*model.GroupMessage) error {
+	uids := make([]string, len(msgs))
+	for i, m := range msgs {
+		uids[i] = m.UserId
+	}
+	users, err := getUsersById(cs.ur, uids)
+	if err != nil {
+		return err
+	}
+	bodies := make([]*model.MessageBody, len(msgs))
+	for i, m := range msgs {
+		m.Sender = model.UserToChatUser(users[m.UserId])
+		bodies[i] = &m.MessageBody
+	}
+	return cs.fillBodies(uid, messages, bodies)
+}
+
+// fillBodies 为一页消息填充视频卡片、被回复的消息和表情回应，各用一次批量查询；
+// uid 用于标记当前用户添加过的表情，并去掉 uid 看不到的视频卡片；
+// 为空时（推送给多个连接）不标记表情，私密账号的视频卡片不填充
+func (cs *chatService) fillBodies(uid string, messages []*model.Message, bodies []*model.MessageBody) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	ids := make([]string, len(messages))
+	var videoIds, replyIds []string
+	for i, m := range messages {
+		ids[i] = m.Id
+		if bodies[i].VideoId != "" {
+			videoIds = append(videoIds, bodies[i].VideoId)
+		}
+		if bodies[i].ReplyToId != "" {
+			replyIds = append(replyIds, bodies[i].ReplyToId)
+		}
+	}
+
+	cards := make(map[string]*model.VideoCard, len(videoIds))
+	if len(videoIds) > 0 {
+		videos, err := cs.vr.GetVideosByIds(videoIds)
+		if err != nil {
+			log.Printf("failed to get videos by ids: ids: %v, err: %v", videoIds, err)
+			return err
+		}
+		videos, err = filterVideos(cs.ur, cs.fr, uid, false, videos)
+		if err != nil {
+			return err
+		}
+		if err := fillVideoAuthors(cs.ur, videos); err != nil {
+			return err
+		}
+		for _, v := range videos {
+			cards[v.Id] = model.VideoToVideoCard(v)
+		}
+	}
+	replies, err := cs.mr.GetMessagesByIds(replyIds)
+	if err != nil {
+		log.Printf("failed to get messages by ids: ids: %v, err: %v", replyIds, err)
+		return err
+	}
+	quotes := make(map[string]*model.MessageQuote, len(replies))
+	for _, r := range replies {
+		quotes[r.Id] = model.MessageToMessageQuote(r)
+	}
+	reactions, err := cs.mr.GetReactions(ids)
+	if err != nil {
+		log.Printf("failed to get reactions: ids: %v, err: %v", ids, err)
+		return err
+	}
+	counts := model.ReactionsToReactionCounts(reactions, uid)
+
+	for i, b := range bodies {
+		if b.Recalled {
+			continue
+		}
+		b.Video = cards[b.VideoId]
+		b.ReplyTo = quotes[b.ReplyToId]
+		b.Reactions = counts[messages[i].Id]
+	}
+	return nil
+}
+
+// applyBody 校验发送的消息内容并写入 message，内容需要先经过审核；
+// 分享的视频需要发送者可见，私聊时还需要接收者可见
+func (cs *chatService) applyBody(message *model.Message, body *model.MessageBody) error {
+	message.Content = body.Content
+	switch body.Kind {
+	case "", model.MessageKindText:
+		message.Kind = model.MessageKindText
+	case model.MessageKindImage:
+		if !isChatImageUrl(body.ImageUrl) {
+			return ErrInvalidImage
+		}
+		message.Kind = model.MessageKindImage
+		message.Content = ""
+		message.ImageUrl = body.ImageUrl
+	case model.MessageKindVideo:
+		v, err := cs.vr.GetVideoById(body.VideoId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVideoNotFound
+			}
+			log.Printf("failed to get video by id: id: %s, err: %v", body.VideoId, err)
+			return err
+		}
+		if v.Status != model.ContentNormal {
+			return ErrVideoNotFound
+		}
+		for _, viewerId := range []string{message.SenderId, message.ReceiverId} {
+			if viewerId == "" {
+				continue
+			}
+			if err := cs.checkVideoVisible(viewerId, v); err != nil {
+				return err
+			}
+		}
+		message.Kind = model.MessageKindVideo
+		message.VideoId = v.Id
+	default:
+		return ErrInvalidMessageKind
+	}
+
+	if body.ReplyToId != "" {
+		reply, err := cs.mr.GetMessageById(body.ReplyToId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMessageNotFound
+			}
+			log.Printf("failed to get message by id: id: %s, err: %v", body.ReplyToId, err)
+			return err
+		}
+		if reply.ConversationId != message.ConversationId {
+			return ErrMessageNotFound
+		}
+		message.ReplyToId = reply.Id
+	}
+	return nil
+}
+
+// checkVideoVisible 视频作者是私密账号且 viewerId 未关注，或双方之间有屏蔽时返回 ErrVideoNotFound
+func (cs *chatService) checkVideoVisible(viewerId string, v *model.Video) error {
+	err := checkVisible(cs.ur, cs.fr, viewerId, v.Uid)
+	if err == nil {
+		err = checkBlocked(cs.fr, viewerId, v.Uid)
+	}
+	if errors.Is(err, ErrPrivateAccount) || errors.Is(err, ErrBlocked) {
+		return ErrVideoNotFound
+	}
+	return err
+}
+
+// isMessageRejection 判断发送、撤回和表情回应的错误是否由请求本身导致
+func isMessageRejection(err error) bool {
+	for _, e := range []error{ErrInvalidMessageKind, ErrInvalidImage, ErrVideoNotFound, ErrMessageNotFound,
+		ErrMessageRecalled, ErrRecallExpired, ErrInvalidEmoji, ErrNotGroupMember, ErrPermissionDenied} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// isChatImageUrl 图片消息只能引用 UploadImage 保存的文件
+func isChatImageUrl(url string) bool {
+	if url == "" || path.Clean(url) != url || path.Dir(url) != chatImageDir {
+		return false
+	}
+	for _, ext := range chatImageExts {
+		if path.Ext(url) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmoji 只接受单个表情，可以带肤色、变体选择符和零宽连接符组合
+func isEmoji(s string) bool {
+	if s == "" || len(s) > maxEmojiLength || !utf8.ValidString(s) {
+		return false
+	}
+	symbol := false
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.So, r):
+			symbol = true
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Sk, unicode.Cf):
+		default:
+			return false
+		}
+	}
+	return symbol
+}
+
+// moderate 聊天消息无法等待人工审核，命中 review 的词与 reject 一样拒绝发送
+func (cs *chatService) moderate(content *string) bool {
+	review, _, err := moderate(cs.me, content)
+	return err == nil && !review
+}
+
+// sendRejected 只告知发送方消息被拒绝及原因，不断开连接
+func (cs *chatService) sendRejected(msgType int, reason error) ([]byte, error) {
+	return json.Marshal(&model.WSMessage{
+		Type: msgType,
+		Data: reason.Error(),
+	})
+}
+
 func (cs *chatService) sendError(msgType int, msg string, err error) ([]byte, error) {
 	log.Printf(msg+": msgType: %d, err: %v", msgType, err)
 	resMsg, _ := json.Marshal(&model.WSMessage{
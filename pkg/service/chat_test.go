@@ -0,0 +1,36 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want bool
+	}{
+		{"empty", "", false},
+		{"single emoji", "😀", true},
+		{"emoji with skin tone", "👍🏽", true},
+		{"emoji with variation selector", "❤️", true},
+		{"zwj sequence", "👨‍👩‍👧", true},
+		{"flag", "🇨🇳", true},
+		{"several emojis", "🎉🎉", true},
+		{"letter", "a", false},
+		{"digit", "1", false},
+		{"cjk character", "好", false},
+		{"emoji with text", "😀a", false},
+		{"combining mark only", "́", false},
+		{"invalid utf8", "\xff", false},
+		{"too long", strings.Repeat("😀", maxEmojiLength/4+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEmoji(tt.s); got != tt.want {
+				t.Errorf("isEmoji(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,52 +1,361 @@
 package service
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 	"west2/pkg/model"
+	"west2/pkg/moderation"
 	"west2/pkg/repository"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxReplyDepth = 3
+	defaultTreeReplies   = 3
+	maxTreeReplies       = 20
+	defaultCommentPage   = 20
+	maxCommentPage       = 100
 )
 
+type CommentListParams struct {
+	Uid       string
+	VideoId   string
+	CommentId string
+	SortBy    string
+	Cursor    string
+	PageNum   int64
+	PageSize  int64
+}
+
 type commentService struct {
-	cr repository.CommentRepository
+	cr          repository.CommentRepository
+	vr          repository.VideoRepository
+	lr          repository.LikeRepository
+	ur          repository.UserRepository
+	fr          repository.FollowRepostory
+	nr          repository.NotificationRepository
+	rr          repository.ReviewRepository
+	me          moderation.Engine
+	maxDepth    int64
+	treeReplies int64
 }
 
 type CommentService interface {
 	Publish(comment *model.Comment) error
-	GetCommentList(videoId, commentId string, pageNum, pageSize int64) ([]*model.Comment, error)
-	DeleteById(id string) error
-	DeleteByVideoId(videoId string) error
+	GetCommentList(params *CommentListParams) ([]*model.Comment, string, error)
+	GetCommentTree(params *CommentListParams, replies int64) ([]*model.Comment, string, error)
+	Pin(commentId, uid string) error
+	Unpin(videoId, uid string) error
+	DeleteById(id, uid string) error
+	DeleteByVideoId(videoId, uid string) error
 }
 
-func NewCommentService(cr repository.CommentRepository) CommentService {
-	return &commentService{cr: cr}
+// NewCommentService maxDepth 为允许的最大回复层级，treeReplies 为评论树中每条评论默认内嵌的回复数，传 0 使用默认值
+func NewCommentService(cr repository.CommentRepository, vr repository.VideoRepository, lr repository.LikeRepository, ur repository.UserRepository, fr repository.FollowRepostory, nr repository.NotificationRepository, rr repository.ReviewRepository, me moderation.Engine, maxDepth, treeReplies int64) CommentService {
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxReplyDepth
+	}
+	if treeReplies <= 0 {
+		treeReplies = defaultTreeReplies
+	}
+	return &commentService{cr: cr, vr: vr, lr: lr, ur: ur, fr: fr, nr: nr, rr: rr, me: me, maxDepth: maxDepth, treeReplies: treeReplies}
 }
 
+// Publish 命中需要人工审核的敏感词时评论以待审核状态写入，通过审核后才公开并发送通知
 func (cs *commentService) Publish(comment *model.Comment) error {
-	err := cs.cr.CreateComment(comment)
+	var parent *model.Comment
+	if comment.ParentId != "" {
+		var err error
+		parent, err = cs.getComment(comment.ParentId)
+		if err != nil {
+			return err
+		}
+		if parent.Status != model.ContentNormal {
+			return ErrCommentNotFound
+		}
+		if comment.VideoId == "" {
+			comment.VideoId = parent.VideoId
+		} else if comment.VideoId != parent.VideoId {
+			return ErrParentMismatch
+		}
+		comment.Depth = parent.Depth + 1
+		if comment.Depth > cs.maxDepth {
+			return ErrReplyTooDeep
+		}
+		comment.RootId = parent.RootId
+		if comment.RootId == "" {
+			comment.RootId = parent.Id
+		}
+	}
+
+	v, err := cs.getVideo(comment.VideoId)
+	if err != nil {
+		return err
+	}
+	if err := checkBlocked(cs.fr, comment.Uid, v.Uid); err != nil {
+		return err
+	}
+	if err := checkVisible(cs.ur, cs.fr, comment.Uid, v.Uid); err != nil {
+		return err
+	}
+	if parent != nil {
+		if err := checkBlocked(cs.fr, comment.Uid, parent.Uid); err != nil {
+			return err
+		}
+	}
+
+	review, words, err := moderate(cs.me, &comment.Content)
+	if err != nil {
+		return err
+	}
+	if review {
+		comment.Status = model.ContentPending
+	}
+
+	mentions, err := resolveMentions(cs.ur, cs.fr, comment.Uid, comment.Content)
+	if err != nil {
+		return err
+	}
+	comment.Mentions = mentions
+
+	err = cs.cr.CreateComment(comment)
 	if err != nil {
 		log.Printf("failed to create comment: comment: %v, err: %v", comment, err)
 		return err
 	}
+	if review {
+		return submitReview(cs.rr, model.TargetComment, comment.Id, comment.Uid, comment.Content, words)
+	}
+	notifyComment(cs.nr, comment, parent, v)
 	return nil
 }
 
-func (cs *commentService) GetCommentList(videoId, commentId string, pageNum, pageSize int64) ([]*model.Comment, error) {
-	var comments []*model.Comment
-	var err error
-	if videoId != "" {
-		comments, err = cs.cr.GetCommentListByVideoId(videoId, pageNum, pageSize)
+// notifyComment 通知被回复的评论作者或视频作者，以及评论中 @ 到的用户
+func notifyComment(nr repository.NotificationRepository, comment, parent *model.Comment, v *model.Video) {
+	if parent != nil {
+		notify(nr, &model.NotificationEvent{
+			Uid:        parent.Uid,
+			ActorId:    comment.Uid,
+			Type:       model.NotificationReply,
+			TargetType: model.TargetComment,
+			TargetId:   parent.Id,
+		})
 	} else {
-		comments, err = cs.cr.GetCommentListByCommentId(commentId, pageNum, pageSize)
+		notify(nr, &model.NotificationEvent{
+			Uid:        v.Uid,
+			ActorId:    comment.Uid,
+			Type:       model.NotificationComment,
+			TargetType: model.TargetVideo,
+			TargetId:   v.Id,
+		})
+	}
+	notifyMentions(nr, comment.Uid, model.TargetComment, comment.Id, comment.Mentions, nil)
+}
+
+// GetCommentList 按 videoId 取一级评论或按 commentId 取回复；一级评论的第一页把置顶评论放在最前
+func (cs *commentService) GetCommentList(params *CommentListParams) ([]*model.Comment, string, error) {
+	sortBy := params.SortBy
+	if sortBy == "" {
+		sortBy = repository.CommentSortNewest
+		if params.CommentId != "" {
+			sortBy = repository.CommentSortOldest
+		}
+	}
+	if sortBy != repository.CommentSortNewest && sortBy != repository.CommentSortOldest && sortBy != repository.CommentSortHot {
+		return nil, "", ErrInvalidSort
 	}
+
+	cursor, err := decodeCommentCursor(params.Cursor, sortBy)
 	if err != nil {
-		log.Printf("failed to get comments: videoId: %s, commentId: %s, err: %v", videoId, commentId, err)
-		return nil, err
+		return nil, "", err
+	}
+
+	hidden, err := hiddenUids(cs.fr, params.Uid, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limit := clampLimit(params.PageSize, defaultCommentPage, maxCommentPage)
+	query := &repository.CommentQuery{
+		VideoId:     params.VideoId,
+		ParentId:    params.CommentId,
+		SortBy:      sortBy,
+		Cursor:      cursor,
+		ExcludeUids: hidden,
+		Limit:       limit,
+	}
+	// 兼容旧客户端的 pageNum 翻页
+	if cursor == nil && params.PageNum > 1 {
+		query.Offset = (int(params.PageNum) - 1) * limit
+	}
+
+	// 回复列表同样按所属视频的状态、隐私和屏蔽关系判断是否可见
+	videoId := params.VideoId
+	if params.CommentId != "" {
+		parent, err := cs.getComment(params.CommentId)
+		if err != nil {
+			return nil, "", err
+		}
+		if parent.Status != model.ContentNormal || slices.Contains(hidden, parent.Uid) {
+			return nil, "", ErrCommentNotFound
+		}
+		videoId = parent.VideoId
+	}
+	v, err := cs.getVideo(videoId)
+	if err != nil {
+		return nil, "", err
+	}
+	if slices.Contains(hidden, v.Uid) {
+		return nil, "", ErrVideoNotFound
+	}
+	if err := checkVisible(cs.ur, cs.fr, params.Uid, v.Uid); err != nil {
+		return nil, "", err
+	}
+
+	var pinned *model.Comment
+	if params.CommentId == "" {
+		if v.PinnedCommentId != "" {
+			query.ExcludeId = v.PinnedCommentId
+			if cursor == nil && query.Offset == 0 {
+				pinned, err = cs.cr.GetCommentById(v.PinnedCommentId)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					log.Printf("failed to get pinned comment: id: %s, err: %v", v.PinnedCommentId, err)
+					return nil, "", err
+				}
+			}
+		}
+	}
+
+	comments, err := cs.cr.ListComments(query)
+	if err != nil {
+		log.Printf("failed to get comments: videoId: %s, commentId: %s, err: %v", params.VideoId, params.CommentId, err)
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(comments) == limit {
+		nextCursor = encodeCommentCursor(comments[len(comments)-1], sortBy)
+	}
+	if pinned != nil && pinned.Status == model.ContentNormal && !slices.Contains(hidden, pinned.Uid) {
+		pinned.Pinned = true
+		comments = append([]*model.Comment{pinned}, comments...)
+	}
+	if err := cs.markLiked(params.Uid, comments); err != nil {
+		return nil, "", err
+	}
+	if err := fillCommentAuthors(cs.ur, comments); err != nil {
+		return nil, "", err
+	}
+	return comments, nextCursor, nil
+}
+
+func (cs *commentService) GetCommentTree(params *CommentListParams, replies int64) ([]*model.Comment, string, error) {
+	params.CommentId = ""
+	comments, nextCursor, err := cs.GetCommentList(params)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ids := make([]string, len(comments))
+	byId := make(map[string]*model.Comment, len(comments))
+	for i, c := range comments {
+		ids[i] = c.Id
+		byId[c.Id] = c
+	}
+
+	hidden, err := hiddenUids(cs.fr, params.Uid, false)
+	if err != nil {
+		return nil, "", err
+	}
+	limit := clampLimit(replies, int(cs.treeReplies), maxTreeReplies)
+	children, err := cs.cr.GetFirstReplies(ids, hidden, int64(limit))
+	if err != nil {
+		log.Printf("failed to get replies: videoId: %s, err: %v", params.VideoId, err)
+		return nil, "", err
+	}
+	if err := cs.markLiked(params.Uid, children); err != nil {
+		return nil, "", err
+	}
+	if err := fillCommentAuthors(cs.ur, children); err != nil {
+		return nil, "", err
+	}
+	for _, c := range children {
+		if parent, ok := byId[c.ParentId]; ok {
+			parent.Replies = append(parent.Replies, c)
+		}
+	}
+	return comments, nextCursor, nil
+}
+
+// Pin 视频作者置顶一条一级评论，会替换之前的置顶
+func (cs *commentService) Pin(commentId, uid string) error {
+	c, err := cs.getComment(commentId)
+	if err != nil {
+		return err
+	}
+	if c.Status != model.ContentNormal {
+		return ErrCommentNotFound
+	}
+	if c.ParentId != "" {
+		return ErrPinReply
+	}
+	v, err := cs.getVideo(c.VideoId)
+	if err != nil {
+		return err
+	}
+	if v.Uid != uid {
+		return ErrPermissionDenied
+	}
+
+	err = cs.cr.SetPinnedComment(v.Id, c.Id)
+	if err != nil {
+		log.Printf("failed to pin comment: videoId: %s, commentId: %s, err: %v", v.Id, c.Id, err)
+		return err
+	}
+	return nil
+}
+
+func (cs *commentService) Unpin(videoId, uid string) error {
+	v, err := cs.getVideo(videoId)
+	if err != nil {
+		return err
+	}
+	if v.Uid != uid {
+		return ErrPermissionDenied
 	}
-	return comments, nil
+
+	err = cs.cr.SetPinnedComment(v.Id, "")
+	if err != nil {
+		log.Printf("failed to unpin comment: videoId: %s, err: %v", v.Id, err)
+		return err
+	}
+	return nil
 }
 
-func (cs *commentService) DeleteById(id string) error {
-	err := cs.cr.DeleteCommentById(id)
+// DeleteById 评论作者或视频作者可以删除评论
+func (cs *commentService) DeleteById(id, uid string) error {
+	c, err := cs.getComment(id)
+	if err != nil {
+		return err
+	}
+	if c.Uid != uid {
+		v, err := cs.getVideo(c.VideoId)
+		if err != nil {
+			return err
+		}
+		if v.Uid != uid {
+			return ErrPermissionDenied
+		}
+	}
+
+	err = cs.cr.DeleteComment(c)
 	if err != nil {
 		log.Printf("failed to delete comment by id: id: %s, err: %v", id, err)
 		return err
@@ -54,11 +363,105 @@ func (cs *commentService) DeleteById(id string) error {
 	return nil
 }
 
-func (cs *commentService) DeleteByVideoId(videoId string) error {
-	err := cs.cr.DeleteCommentsByVideoId(videoId)
+func (cs *commentService) DeleteByVideoId(videoId, uid string) error {
+	v, err := cs.getVideo(videoId)
+	if err != nil {
+		return err
+	}
+	if v.Uid != uid {
+		return ErrPermissionDenied
+	}
+
+	err = cs.cr.DeleteCommentsByVideoId(videoId)
 	if err != nil {
 		log.Printf("failed to delete comment by videoId: videoId: %s, err: %v", videoId, err)
 		return err
 	}
 	return nil
 }
+
+// markLiked 一次查询标记当前用户点过赞的评论
+func (cs *commentService) markLiked(uid string, comments []*model.Comment) error {
+	if uid == "" || len(comments) == 0 {
+		return nil
+	}
+	ids := make([]string, len(comments))
+	for i, c := range comments {
+		ids[i] = c.Id
+	}
+	liked, err := cs.lr.GetLikedCommentIds(uid, ids)
+	if err != nil {
+		log.Printf("failed to get liked comments: uid: %s, err: %v", uid, err)
+		return err
+	}
+	for _, c := range comments {
+		c.Liked = liked[c.Id]
+	}
+	return nil
+}
+
+func (cs *commentService) getComment(id string) (*model.Comment, error) {
+	c, err := cs.cr.GetCommentById(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		log.Printf("failed to get comment by id: id: %s, err: %v", id, err)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (cs *commentService) getVideo(id string) (*model.Video, error) {
+	v, err := cs.vr.GetVideoById(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVideoNotFound
+		}
+		log.Printf("failed to get video by id: id: %s, err: %v", id, err)
+		return nil, err
+	}
+	if v.Status != model.ContentNormal {
+		return nil, ErrVideoNotFound
+	}
+	return v, nil
+}
+
+// 游标格式为 base64("排序方式:排序键:id")，排序方式不一致的游标视为无效
+func encodeCommentCursor(c *model.Comment, sortBy string) string {
+	var key string
+	if sortBy == repository.CommentSortHot {
+		key = strconv.FormatFloat(c.HotScore, 'g', -1, 64)
+	} else {
+		key = strconv.FormatInt(c.CreatedAt.UnixNano(), 10)
+	}
+	raw := fmt.Sprintf("%s:%s:%s", sortBy, key, c.Id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCommentCursor(cursor, sortBy string) (*repository.CommentCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 || parts[0] != sortBy || parts[2] == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &repository.CommentCursor{Id: parts[2]}
+	if sortBy == repository.CommentSortHot {
+		c.HotScore, err = strconv.ParseFloat(parts[1], 64)
+	} else {
+		var nanos int64
+		nanos, err = strconv.ParseInt(parts[1], 10, 64)
+		c.CreatedAt = time.Unix(0, nanos)
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
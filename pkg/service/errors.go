@@ -0,0 +1,45 @@
+package service
+
+import "errors"
+
+var (
+	ErrVideoNotFound         = errors.New("video is not exists")
+	ErrPermissionDenied      = errors.New("permission denied")
+	ErrInvalidTimestamp      = errors.New("invalid timestamp")
+	ErrInvalidSort           = errors.New("invalid sort option")
+	ErrCommentNotFound       = errors.New("comment is not exists")
+	ErrParentMismatch        = errors.New("parent comment belongs to another video")
+	ErrReplyTooDeep          = errors.New("reply depth exceeds limit")
+	ErrPinReply              = errors.New("only top-level comments can be pinned")
+	ErrInvalidCursor         = errors.New("invalid cursor")
+	ErrInvalidAction         = errors.New("invalid action type")
+	ErrInvalidMentionPolicy  = errors.New("invalid mention policy")
+	ErrContentRejected       = errors.New("content contains sensitive words")
+	ErrReviewNotFound        = errors.New("review item is not exists")
+	ErrReviewResolved        = errors.New("review item has been resolved")
+	ErrInvalidReviewStatus   = errors.New("invalid review status")
+	ErrUserNotFound          = errors.New("user is not exists")
+	ErrMessageNotFound       = errors.New("message is not exists")
+	ErrInvalidTarget         = errors.New("invalid target type")
+	ErrInvalidReason         = errors.New("invalid report reason")
+	ErrReportSelf            = errors.New("cannot report your own content")
+	ErrReportExists          = errors.New("already reported")
+	ErrCaseNotFound          = errors.New("case is not exists")
+	ErrCaseResolved          = errors.New("case has been resolved")
+	ErrInvalidCaseStatus     = errors.New("invalid case status")
+	ErrAppealNotAllowed      = errors.New("case cannot be appealed")
+	ErrBlocked               = errors.New("user is blocked")
+	ErrSelfRelation          = errors.New("cannot perform this action on yourself")
+	ErrPrivateAccount        = errors.New("account is private")
+	ErrFollowRequestNotFound = errors.New("follow request is not exists")
+	ErrGroupNotFound         = errors.New("group is not exists")
+	ErrNotGroupMember        = errors.New("not a member of the group")
+	ErrInvalidGroupTitle     = errors.New("invalid group title")
+	ErrInvalidGroupRole      = errors.New("invalid group role")
+	ErrOwnerCannotLeave      = errors.New("owner must transfer the group before leaving")
+	ErrInvalidMessageKind    = errors.New("invalid message kind")
+	ErrInvalidImage          = errors.New("invalid image")
+	ErrMessageRecalled       = errors.New("message has been recalled")
+	ErrRecallExpired         = errors.New("message can no longer be recalled")
+	ErrInvalidEmoji          = errors.New("invalid emoji")
+)
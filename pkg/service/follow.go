@@ -13,95 +13,301 @@ import (
 type followService struct {
 	fr repository.FollowRepostory
 	ur repository.UserRepository
+	nr repository.NotificationRepository
+	rc repository.RelationCacheRepository
 }
 
 type FollowerService interface {
 	FollowAction(follow *model.Follow) error
-	GetFollowingList(followerId string, pageNum, pageSize int64) ([]*model.User, int64, error)
-	GetFollowerList(followerId string, pageNum, pageSize int64) ([]*model.User, int64, error)
+	GetFollowingList(viewerId, followerId string, pageNum, pageSize int64) ([]*model.User, int64, error)
+	GetFollowerList(viewerId, followerId string, pageNum, pageSize int64) ([]*model.User, int64, error)
+	GetFriendList(followerId string, pageNum, pageSize int64) ([]*model.User, int64, error)
+	ReconcileFollows() error
+	BlockAction(uid, targetId string, actionType int64) error
+	MuteAction(uid, targetId string, actionType int64) error
+	GetBlockList(uid string, pageNum, pageSize int64) ([]*model.User, int64, error)
+	GetMuteList(uid string, pageNum, pageSize int64) ([]*model.User, int64, error)
+	GetFollowRequestList(uid string, pageNum, pageSize int64) ([]*model.User, int64, error)
+	HandleFollowRequest(uid, fromUserId string, actionType int64) error
+	SuggestUsers(uid string, limit int64) ([]*model.User, error)
 }
 
-func NewFollowService(fr repository.FollowRepostory, ur repository.UserRepository) FollowerService {
-	return &followService{fr: fr, ur: ur}
+func NewFollowService(fr repository.FollowRepostory, ur repository.UserRepository, nr repository.NotificationRepository, rc repository.RelationCacheRepository) FollowerService {
+	return &followService{fr: fr, ur: ur, nr: nr, rc: rc}
 }
 
+// FollowAction 关注私密账号时先成为待处理的申请，由对方通过后才生效；
+// 对待处理的申请取消关注即撤回申请
 func (fs *followService) FollowAction(follow *model.Follow) error {
-	f, err := fs.fr.GetFollowById(follow.FollowerId, follow.FollowingId)
+	target, err := fs.checkRelationTarget(follow.FollowerId, follow.FollowingId, follow.Status)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			follow.Id = util.GetID()
-			err = fs.fr.Create(follow)
-			if err != nil {
-				log.Printf("failed to create follow: follow: %v, err: %v", follow, err)
-				return err
-			}
-			return nil
+		return err
+	}
+	if follow.Status == model.FollowStatusActive {
+		if err := checkBlocked(fs.fr, follow.FollowerId, follow.FollowingId); err != nil {
+			return err
 		}
+	}
+
+	f, err := fs.fr.GetFollowById(follow.FollowerId, follow.FollowingId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		log.Printf("failed to get follow by followerId and followingId: followerId: %s, followingId: %s, err: %v", follow.FollowerId, follow.FollowingId, err)
 		return err
 	}
+	if follow.Status == model.FollowStatusActive && target.IsPrivate && (f == nil || f.Status != model.FollowStatusActive) {
+		follow.Status = model.FollowStatusPending
+	}
+
+	if f == nil {
+		follow.Id = util.GetID()
+		err = fs.fr.Create(follow)
+		if err != nil {
+			log.Printf("failed to create follow: follow: %v, err: %v", follow, err)
+			return err
+		}
+		fs.notifyFollow(follow)
+		return nil
+	}
 
 	err = fs.fr.SetStatus(follow.Status, f.Id)
 	if err != nil {
 		log.Printf("failed to set follow's status by id: id: %s, status: %d, err: %v", f.Id, follow.Status, err)
 		return err
 	}
+	if follow.Status != f.Status {
+		fs.notifyFollow(follow)
+	}
 	return nil
 }
 
-func (fs *followService) GetFollowingList(followerId string, pageNum, pageSize int64) ([]*model.User, int64, error) {
-	var users []*model.User
+// notifyFollow 按关注状态通知被关注的用户：新关注或新的关注申请
+func (fs *followService) notifyFollow(follow *model.Follow) {
+	var typ string
+	switch follow.Status {
+	case model.FollowStatusActive:
+		typ = model.NotificationFollow
+	case model.FollowStatusPending:
+		typ = model.NotificationFollowRequest
+	default:
+		return
+	}
+	notify(fs.nr, &model.NotificationEvent{
+		Uid:        follow.FollowingId,
+		ActorId:    follow.FollowerId,
+		Type:       typ,
+		TargetType: model.TargetUser,
+		TargetId:   follow.FollowingId,
+	})
+}
+
+func (fs *followService) GetFollowingList(viewerId, followerId string, pageNum, pageSize int64) ([]*model.User, int64, error) {
+	if err := checkVisible(fs.ur, fs.fr, viewerId, followerId); err != nil {
+		return nil, 0, err
+	}
 	follows, total, err := fs.fr.GetFollowingList(followerId, pageNum, pageSize)
 	if err != nil {
 		log.Printf("failed to get following list by follower id: followerId: %s, err: %v", followerId, err)
 		return nil, 0, err
 	}
 
-	for _, f := range follows {
-		u, err := fs.ur.GetUserById(f.FollowingId)
-		if err != nil {
-			log.Printf("failed to get user by id: uid: %s, err: %v", f.FollowingId, err)
-			return nil, 0, err
-		}
-		users = append(users, u)
+	ids := make([]string, len(follows))
+	for i, f := range follows {
+		ids[i] = f.FollowingId
+	}
+	users, err := fs.ur.GetUsersByIds(ids)
+	if err != nil {
+		log.Printf("failed to get users by ids: ids: %v, err: %v", ids, err)
+		return nil, 0, err
 	}
 	return users, total, nil
 }
 
-func (fs *followService) GetFollowerList(followerId string, pageNum, pageSize int64) ([]*model.User, int64, error) {
-	var users []*model.User
+func (fs *followService) GetFollowerList(viewerId, followerId string, pageNum, pageSize int64) ([]*model.User, int64, error) {
+	if err := checkVisible(fs.ur, fs.fr, viewerId, followerId); err != nil {
+		return nil, 0, err
+	}
 	follows, total, err := fs.fr.GetFollowerList(followerId, pageNum, pageSize)
 	if err != nil {
-		log.Printf("failed to get following list by follower id: followerId: %s, err: %v", followerId, err)
+		log.Printf("failed to get follower list by following id: followingId: %s, err: %v", followerId, err)
 		return nil, 0, err
 	}
 
-	for _, f := range follows {
-		u, err := fs.ur.GetUserById(f.FollowerId)
-		if err != nil {
-			log.Printf("failed to get user by id: uid: %s, err: %v", f.FollowingId, err)
-			return nil, 0, err
-		}
-		users = append(users, u)
+	ids := make([]string, len(follows))
+	for i, f := range follows {
+		ids[i] = f.FollowerId
+	}
+	users, err := fs.ur.GetUsersByIds(ids)
+	if err != nil {
+		log.Printf("failed to get users by ids: ids: %v, err: %v", ids, err)
+		return nil, 0, err
 	}
 	return users, total, nil
 }
 
 func (fs *followService) GetFriendList(followerId string, pageNum, pageSize int64) ([]*model.User, int64, error) {
-	var users []*model.User
 	follows, total, err := fs.fr.GetFriendList(followerId, pageNum, pageSize)
 	if err != nil {
-		log.Printf("failed to get following list by follower id: followerId: %s, err: %v", followerId, err)
+		log.Printf("failed to get friend list by follower id: followerId: %s, err: %v", followerId, err)
 		return nil, 0, err
 	}
 
-	for _, f := range follows {
-		u, err := fs.ur.GetUserById(f.FollowerId)
-		if err != nil {
-			log.Printf("failed to get user by id: uid: %s, err: %v", f.FollowingId, err)
-			return nil, 0, err
-		}
-		users = append(users, u)
+	ids := make([]string, len(follows))
+	for i, f := range follows {
+		ids[i] = f.FollowingId
+	}
+	users, err := fs.ur.GetUsersByIds(ids)
+	if err != nil {
+		log.Printf("failed to get users by ids: ids: %v, err: %v", ids, err)
+		return nil, 0, err
 	}
 	return users, total, nil
 }
+
+func (fs *followService) ReconcileFollows() error {
+	if err := fs.fr.RecountFollows(); err != nil {
+		log.Printf("failed to recount follows: %v", err)
+		return err
+	}
+	return nil
+}
+
+// BlockAction actionType 为 1 时屏蔽，为 0 时取消屏蔽；屏蔽会同时取消双方之间的关注
+func (fs *followService) BlockAction(uid, targetId string, actionType int64) error {
+	if _, err := fs.checkRelationTarget(uid, targetId, actionType); err != nil {
+		return err
+	}
+
+	var err error
+	if actionType == 1 {
+		err = fs.fr.Block(uid, targetId)
+	} else {
+		err = fs.fr.Unblock(uid, targetId)
+	}
+	if err != nil {
+		log.Printf("failed to set block: uid: %s, targetId: %s, actionType: %d, err: %v", uid, targetId, actionType, err)
+	}
+	return err
+}
+
+// MuteAction actionType 为 1 时静音，为 0 时取消静音
+func (fs *followService) MuteAction(uid, targetId string, actionType int64) error {
+	if _, err := fs.checkRelationTarget(uid, targetId, actionType); err != nil {
+		return err
+	}
+
+	var err error
+	if actionType == 1 {
+		err = fs.fr.Mute(uid, targetId)
+	} else {
+		err = fs.fr.Unmute(uid, targetId)
+	}
+	if err != nil {
+		log.Printf("failed to set mute: uid: %s, targetId: %s, actionType: %d, err: %v", uid, targetId, actionType, err)
+	}
+	return err
+}
+
+func (fs *followService) GetBlockList(uid string, pageNum, pageSize int64) ([]*model.User, int64, error) {
+	blocks, total, err := fs.fr.GetBlockList(uid, pageNum, pageSize)
+	if err != nil {
+		log.Printf("failed to get block list: uid: %s, err: %v", uid, err)
+		return nil, 0, err
+	}
+
+	ids := make([]string, len(blocks))
+	for i, b := range blocks {
+		ids[i] = b.BlockedId
+	}
+	users, err := fs.ur.GetUsersByIds(ids)
+	if err != nil {
+		log.Printf("failed to get users by ids: ids: %v, err: %v", ids, err)
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (fs *followService) GetMuteList(uid string, pageNum, pageSize int64) ([]*model.User, int64, error) {
+	mutes, total, err := fs.fr.GetMuteList(uid, pageNum, pageSize)
+	if err != nil {
+		log.Printf("failed to get mute list: uid: %s, err: %v", uid, err)
+		return nil, 0, err
+	}
+
+	ids := make([]string, len(mutes))
+	for i, m := range mutes {
+		ids[i] = m.MutedId
+	}
+	users, err := fs.ur.GetUsersByIds(ids)
+	if err != nil {
+		log.Printf("failed to get users by ids: ids: %v, err: %v", ids, err)
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (fs *followService) GetFollowRequestList(uid string, pageNum, pageSize int64) ([]*model.User, int64, error) {
+	follows, total, err := fs.fr.GetFollowRequestList(uid, pageNum, pageSize)
+	if err != nil {
+		log.Printf("failed to get follow request list: uid: %s, err: %v", uid, err)
+		return nil, 0, err
+	}
+
+	ids := make([]string, len(follows))
+	for i, f := range follows {
+		ids[i] = f.FollowerId
+	}
+	users, err := fs.ur.GetUsersByIds(ids)
+	if err != nil {
+		log.Printf("failed to get users by ids: ids: %v, err: %v", ids, err)
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// HandleFollowRequest actionType 为 1 时通过 fromUserId 的关注申请，为 0 时拒绝
+func (fs *followService) HandleFollowRequest(uid, fromUserId string, actionType int64) error {
+	if actionType != 0 && actionType != 1 {
+		return ErrInvalidAction
+	}
+	approve := actionType == 1
+	ok, err := fs.fr.ResolveFollowRequest(fromUserId, uid, approve)
+	if err != nil {
+		log.Printf("failed to resolve follow request: uid: %s, fromUserId: %s, err: %v", uid, fromUserId, err)
+		return err
+	}
+	if !ok {
+		return ErrFollowRequestNotFound
+	}
+	if approve {
+		notify(fs.nr, &model.NotificationEvent{
+			Uid:        fromUserId,
+			ActorId:    uid,
+			Type:       model.NotificationFollowAccept,
+			TargetType: model.TargetUser,
+			TargetId:   uid,
+		})
+	}
+	return nil
+}
+
+// checkRelationTarget 校验关系操作的对象，建立关系时返回对方的用户信息
+func (fs *followService) checkRelationTarget(uid, targetId string, actionType int64) (*model.User, error) {
+	if actionType != 0 && actionType != 1 {
+		return nil, ErrInvalidAction
+	}
+	if uid == targetId {
+		return nil, ErrSelfRelation
+	}
+	if actionType == 0 {
+		return nil, nil
+	}
+	u, err := fs.ur.GetUserById(targetId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		log.Printf("failed to get user by id: uid: %s, err: %v", targetId, err)
+		return nil, err
+	}
+	return u, nil
+}
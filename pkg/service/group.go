@@ -0,0 +1,414 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"unicode/utf8"
+	"west2/pkg/hub"
+	"west2/pkg/model"
+	"west2/pkg/moderation"
+	"west2/pkg/repository"
+	"west2/util"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxGroupTitleLength = 50
+
+type groupService struct {
+	gr repository.GroupRepository
+	ur repository.UserRepository
+	fr repository.FollowRepostory
+	me moderation.Engine
+}
+
+type GroupService interface {
+	CreateGroup(uid, title string, memberIds []string) (*model.Group, error)
+	GetGroup(uid, groupId string) (*model.Group, error)
+	GetUserGroups(uid string, pageNum, pageSize int64) ([]*model.Group, int64, error)
+	GetMembers(uid, groupId string, pageNum, pageSize int64) ([]*model.GroupMember, int64, error)
+	Invite(uid, groupId string, userIds []string) error
+	Join(uid, inviteCode string) (*model.Group, error)
+	Leave(uid, groupId string) error
+	Kick(uid, groupId, targetId string) error
+	Transfer(uid, groupId, targetId string) error
+	Rename(uid, groupId, title string) error
+	SetRole(uid, groupId, targetId string, role int64) error
+}
+
+func NewGroupService(gr repository.GroupRepository, ur repository.UserRepository, fr repository.FollowRepostory, me moderation.Engine) GroupService {
+	return &groupService{gr: gr, ur: ur, fr: fr, me: me}
+}
+
+// CreateGroup 创建者成为群主，memberIds 中的用户直接成为普通成员
+func (gs *groupService) CreateGroup(uid, title string, memberIds []string) (*model.Group, error) {
+	if err := gs.checkTitle(&title); err != nil {
+		return nil, err
+	}
+	ids, err := gs.checkInvitees(uid, memberIds)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &model.Group{
+		Id:         util.GetID(),
+		Title:      title,
+		OwnerId:    uid,
+		InviteCode: newInviteCode(),
+		Role:       model.GroupRoleOwner,
+	}
+	members := make([]*model.GroupMember, 0, len(ids)+1)
+	members = append(members, &model.GroupMember{Id: util.GetID(), GroupId: group.Id, Uid: uid, Role: model.GroupRoleOwner})
+	for _, id := range ids {
+		members = append(members, &model.GroupMember{Id: util.GetID(), GroupId: group.Id, Uid: id, Role: model.GroupRoleMember})
+	}
+	if err := gs.gr.CreateGroup(group, members); err != nil {
+		log.Printf("failed to create group: uid: %s, err: %v", uid, err)
+		return nil, err
+	}
+
+	gs.pushEvent(group.Id, nil, &model.GroupEvent{GroupId: group.Id, Event: model.GroupEventCreate, OperatorId: uid, UserIds: ids, Title: title})
+	return group, nil
+}
+
+func (gs *groupService) GetGroup(uid, groupId string) (*model.Group, error) {
+	member, err := gs.getMember(groupId, uid)
+	if err != nil {
+		return nil, err
+	}
+	group, err := gs.getGroup(groupId)
+	if err != nil {
+		return nil, err
+	}
+	group.Role = member.Role
+	return group, nil
+}
+
+func (gs *groupService) GetUserGroups(uid string, pageNum, pageSize int64) ([]*model.Group, int64, error) {
+	groups, total, err := gs.gr.GetUserGroups(uid, pageNum, pageSize)
+	if err != nil {
+		log.Printf("failed to get user groups: uid: %s, err: %v", uid, err)
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+func (gs *groupService) GetMembers(uid, groupId string, pageNum, pageSize int64) ([]*model.GroupMember, int64, error) {
+	if _, err := gs.getMember(groupId, uid); err != nil {
+		return nil, 0, err
+	}
+	members, total, err := gs.gr.GetMembers(groupId, pageNum, pageSize)
+	if err != nil {
+		log.Printf("failed to get group members: groupId: %s, err: %v", groupId, err)
+		return nil, 0, err
+	}
+
+	ids := make([]string, len(members))
+	for i, m := range members {
+		ids[i] = m.Uid
+	}
+	users, err := getUsersById(gs.ur, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, m := range members {
+		m.User = users[m.Uid]
+	}
+	return members, total, nil
+}
+
+// Invite 只有群主和管理员可以直接拉人入群或分享邀请码
+func (gs *groupService) Invite(uid, groupId string, userIds []string) error {
+	if _, err := gs.requireRole(groupId, uid, model.GroupRoleAdmin); err != nil {
+		return err
+	}
+	ids, err := gs.checkInvitees(uid, userIds)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	members := make([]*model.GroupMember, len(ids))
+	for i, id := range ids {
+		members[i] = &model.GroupMember{Id: util.GetID(), GroupId: groupId, Uid: id, Role: model.GroupRoleMember}
+	}
+	added, err := gs.gr.AddMembers(groupId, members)
+	if err != nil {
+		log.Printf("failed to add group members: groupId: %s, err: %v", groupId, err)
+		return err
+	}
+	if len(added) > 0 {
+		gs.pushEvent(groupId, nil, &model.GroupEvent{GroupId: groupId, Event: model.GroupEventInvite, OperatorId: uid, UserIds: added})
+	}
+	return nil
+}
+
+func (gs *groupService) Join(uid, inviteCode string) (*model.Group, error) {
+	if inviteCode == "" {
+		return nil, ErrGroupNotFound
+	}
+	group, err := gs.gr.GetGroupByInviteCode(inviteCode)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		log.Printf("failed to get group by invite code: err: %v", err)
+		return nil, err
+	}
+
+	added, err := gs.gr.AddMembers(group.Id, []*model.GroupMember{{Id: util.GetID(), GroupId: group.Id, Uid: uid, Role: model.GroupRoleMember}})
+	if err != nil {
+		log.Printf("failed to add group member: groupId: %s, uid: %s, err: %v", group.Id, uid, err)
+		return nil, err
+	}
+	if len(added) > 0 {
+		gs.pushEvent(group.Id, nil, &model.GroupEvent{GroupId: group.Id, Event: model.GroupEventJoin, OperatorId: uid, UserIds: added})
+	}
+	return gs.GetGroup(uid, group.Id)
+}
+
+func (gs *groupService) Leave(uid, groupId string) error {
+	member, err := gs.getMember(groupId, uid)
+	if err != nil {
+		return err
+	}
+	if member.Role == model.GroupRoleOwner {
+		return ErrOwnerCannotLeave
+	}
+	return gs.removeMember(uid, groupId, uid, model.GroupEventLeave)
+}
+
+// Kick 只能移出角色比自己低的成员，移出后更换邀请码，被移出的用户无法用旧的邀请码重新加入
+func (gs *groupService) Kick(uid, groupId, targetId string) error {
+	if uid == targetId {
+		return ErrSelfRelation
+	}
+	member, err := gs.requireRole(groupId, uid, model.GroupRoleAdmin)
+	if err != nil {
+		return err
+	}
+	target, err := gs.getMember(groupId, targetId)
+	if err != nil {
+		if errors.Is(err, ErrNotGroupMember) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if target.Role >= member.Role {
+		return ErrPermissionDenied
+	}
+	// 移出的同时更换邀请码，角色在事务中重新检查
+	removed, err := gs.gr.KickMember(groupId, uid, targetId, newInviteCode())
+	if err != nil {
+		log.Printf("failed to kick group member: groupId: %s, uid: %s, err: %v", groupId, targetId, err)
+		return err
+	}
+	if !removed {
+		return ErrPermissionDenied
+	}
+	gs.pushEvent(groupId, []string{targetId}, &model.GroupEvent{GroupId: groupId, Event: model.GroupEventKick, OperatorId: uid, UserIds: []string{targetId}})
+	return nil
+}
+
+func (gs *groupService) Transfer(uid, groupId, targetId string) error {
+	if uid == targetId {
+		return ErrSelfRelation
+	}
+	if _, err := gs.requireRole(groupId, uid, model.GroupRoleOwner); err != nil {
+		return err
+	}
+	if _, err := gs.getMember(groupId, targetId); err != nil {
+		if errors.Is(err, ErrNotGroupMember) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	transferred, err := gs.gr.TransferOwner(groupId, uid, targetId)
+	if err != nil {
+		log.Printf("failed to transfer group: groupId: %s, to: %s, err: %v", groupId, targetId, err)
+		return err
+	}
+	if !transferred {
+		return ErrPermissionDenied
+	}
+	gs.pushEvent(groupId, nil, &model.GroupEvent{GroupId: groupId, Event: model.GroupEventTransfer, OperatorId: uid, UserIds: []string{targetId}})
+	return nil
+}
+
+func (gs *groupService) Rename(uid, groupId, title string) error {
+	if _, err := gs.requireRole(groupId, uid, model.GroupRoleAdmin); err != nil {
+		return err
+	}
+	if err := gs.checkTitle(&title); err != nil {
+		return err
+	}
+	if err := gs.gr.Rename(groupId, title); err != nil {
+		log.Printf("failed to rename group: groupId: %s, err: %v", groupId, err)
+		return err
+	}
+	gs.pushEvent(groupId, nil, &model.GroupEvent{GroupId: groupId, Event: model.GroupEventRename, OperatorId: uid, Title: title})
+	return nil
+}
+
+// SetRole 群主把成员设为管理员或把管理员降为普通成员，群主身份只能通过转让改变
+func (gs *groupService) SetRole(uid, groupId, targetId string, role int64) error {
+	if role != model.GroupRoleAdmin && role != model.GroupRoleMember {
+		return ErrInvalidGroupRole
+	}
+	if uid == targetId {
+		return ErrSelfRelation
+	}
+	if _, err := gs.requireRole(groupId, uid, model.GroupRoleOwner); err != nil {
+		return err
+	}
+	target, err := gs.getMember(groupId, targetId)
+	if err != nil {
+		if errors.Is(err, ErrNotGroupMember) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if target.Role == role {
+		return nil
+	}
+	updated, err := gs.gr.SetRole(groupId, uid, targetId, role)
+	if err != nil {
+		log.Printf("failed to set group role: groupId: %s, uid: %s, err: %v", groupId, targetId, err)
+		return err
+	}
+	if !updated {
+		return ErrPermissionDenied
+	}
+	gs.pushEvent(groupId, nil, &model.GroupEvent{GroupId: groupId, Event: model.GroupEventSetRole, OperatorId: uid, UserIds: []string{targetId}, Role: role})
+	return nil
+}
+
+// removeMember 被移出的用户也会收到这次变动
+func (gs *groupService) removeMember(uid, groupId, targetId, event string) error {
+	removed, err := gs.gr.RemoveMember(groupId, targetId)
+	if err != nil {
+		log.Printf("failed to remove group member: groupId: %s, uid: %s, err: %v", groupId, targetId, err)
+		return err
+	}
+	if removed {
+		gs.pushEvent(groupId, []string{targetId}, &model.GroupEvent{GroupId: groupId, Event: event, OperatorId: uid, UserIds: []string{targetId}})
+	}
+	return nil
+}
+
+func (gs *groupService) getGroup(groupId string) (*model.Group, error) {
+	group, err := gs.gr.GetGroupById(groupId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		log.Printf("failed to get group by id: id: %s, err: %v", groupId, err)
+		return nil, err
+	}
+	return group, nil
+}
+
+func (gs *groupService) getMember(groupId, uid string) (*model.GroupMember, error) {
+	return getGroupMember(gs.gr, groupId, uid)
+}
+
+// requireRole 角色低于 role 时返回 ErrPermissionDenied
+func (gs *groupService) requireRole(groupId, uid string, role int64) (*model.GroupMember, error) {
+	member, err := gs.getMember(groupId, uid)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role < role {
+		return nil, ErrPermissionDenied
+	}
+	return member, nil
+}
+
+func (gs *groupService) checkTitle(title *string) error {
+	*title = strings.TrimSpace(*title)
+	if *title == "" || utf8.RuneCountInString(*title) > maxGroupTitleLength {
+		return ErrInvalidGroupTitle
+	}
+	review, _, err := moderate(gs.me, title)
+	if err != nil {
+		return err
+	}
+	// 群名称对所有成员立即可见，命中 review 的词同样拒绝
+	if review {
+		return ErrContentRejected
+	}
+	return nil
+}
+
+// checkInvitees 去重并去掉邀请者自己，被邀请的用户必须存在且与邀请者之间没有屏蔽
+func (gs *groupService) checkInvitees(uid string, userIds []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(userIds))
+	ids := make([]string, 0, len(userIds))
+	for _, id := range userIds {
+		if id == "" || id == uid {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	users, err := getUsersById(gs.ur, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if users[id] == nil {
+			return nil, ErrUserNotFound
+		}
+		if err := checkBlocked(gs.fr, uid, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// pushEvent 推送给当前的全部成员以及 extra 中的用户
+func (gs *groupService) pushEvent(groupId string, extra []string, event *model.GroupEvent) {
+	uids, err := gs.gr.GetMemberIds(groupId)
+	if err != nil {
+		log.Printf("failed to get group member ids: groupId: %s, err: %v", groupId, err)
+		return
+	}
+	msg, err := json.Marshal(&model.WSMessage{
+		Type: model.TypeGroupEvent,
+		Data: event,
+	})
+	if err != nil {
+		log.Printf("failed to marshal group event: groupId: %s, err: %v", groupId, err)
+		return
+	}
+	hub.GetHub().SendMany(append(uids, extra...), msg)
+}
+
+// getGroupMember 用户不在群中（包括群不存在）时返回 ErrNotGroupMember
+func getGroupMember(gr repository.GroupRepository, groupId, uid string) (*model.GroupMember, error) {
+	member, err := gr.GetMember(groupId, uid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotGroupMember
+		}
+		log.Printf("failed to get group member: groupId: %s, uid: %s, err: %v", groupId, uid, err)
+		return nil, err
+	}
+	return member, nil
+}
+
+func newInviteCode() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")
+}
@@ -1,8 +1,12 @@
 package service
 
 import (
+	"encoding/base64"
 	"errors"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 	"west2/pkg/model"
 	"west2/pkg/repository"
 	"west2/util"
@@ -10,73 +14,247 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	defaultLikePageSize = 20
+	maxLikePageSize     = 100
+)
+
 type likeService struct {
 	lr repository.LikeRepository
+	lc repository.LikeCacheRepository
 	vr repository.VideoRepository
+	cr repository.CommentRepository
+	ur repository.UserRepository
+	fr repository.FollowRepostory
+	nr repository.NotificationRepository
 }
 
 type LikeService interface {
 	LikeAction(like *model.Like) error
-	GetVideoListByLike(uid string, pageNum, pageSize int64) ([]*model.Video, error)
+	GetVideoListByLike(viewerId, uid, cursor string, pageNum, pageSize int64) ([]*model.Video, string, int64, error)
+	FlushLikes() (int, error)
+	ReconcileLikes() error
 }
 
-func NewLikeService(lr repository.LikeRepository, vr repository.VideoRepository) LikeService {
-	return &likeService{lr: lr, vr: vr}
+func NewLikeService(lr repository.LikeRepository, lc repository.LikeCacheRepository, vr repository.VideoRepository, cr repository.CommentRepository, ur repository.UserRepository, fr repository.FollowRepostory, nr repository.NotificationRepository) LikeService {
+	return &likeService{lr: lr, lc: lc, vr: vr, cr: cr, ur: ur, fr: fr, nr: nr}
 }
 
+// LikeAction 只修改 Redis 中的点赞集合，重复点赞或取消不会产生变更，计数由 FlushLikes 写回 MySQL
 func (ls *likeService) LikeAction(like *model.Like) error {
-	l, err := ls.lr.GetLike(like.CommentId, like.VideoId, like.Uid)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			if like.VideoId != "" {
-				if like.Status == 1 {
-					if err := ls.vr.AddLikeCount(like.VideoId); err != nil {
-						log.Printf("failed to add like count: videoId: %s, error: %v", like.VideoId, err)
-						return err
-					}
-				} else {
-					if err := ls.vr.SubtractLikeCount(like.VideoId); err != nil {
-						log.Printf("failed to suntract like count: videoId: %s, error: %v", like.VideoId, err)
-						return err
-					}
-				}
-			}
-
-			like.Id = util.GetID()
-			return ls.lr.CreateLike(like)
-		}
-		log.Printf("failed to get like by ids: commentId: %s, videoId: %s, error: %v", like.CommentId, like.VideoId, err)
-		return err
+	if like.Status != model.LikeStatusLiked && like.Status != model.LikeStatusCanceled {
+		return ErrInvalidAction
 	}
 
-	if like.Status == 1 {
-		if err := ls.vr.AddLikeCount(like.VideoId); err != nil {
-			log.Printf("failed to add like count: videoId: %s, error: %v", like.VideoId, err)
+	// owner 为被点赞内容的作者，用于发送通知
+	var owner string
+	notFound := ErrVideoNotFound
+	target, targetId := repository.LikeTargetVideo, like.VideoId
+	if like.CommentId != "" {
+		target, targetId = repository.LikeTargetComment, like.CommentId
+		notFound = ErrCommentNotFound
+		c, err := ls.cr.GetCommentById(like.CommentId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCommentNotFound
+			}
+			log.Printf("failed to get comment by id: id: %s, error: %v", like.CommentId, err)
 			return err
 		}
+		if c.Status != model.ContentNormal {
+			return ErrCommentNotFound
+		}
+		owner = c.Uid
 	} else {
-		if err := ls.vr.SubtractLikeCount(like.VideoId); err != nil {
-			log.Printf("failed to suntract like count: videoId: %s, error: %v", like.VideoId, err)
+		v, err := ls.vr.GetVideoById(like.VideoId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVideoNotFound
+			}
+			log.Printf("failed to get video by id: id: %s, error: %v", like.VideoId, err)
 			return err
 		}
+		if v.Status != model.ContentNormal {
+			return ErrVideoNotFound
+		}
+		owner = v.Uid
+	}
+	// 被屏蔽或无权查看作者内容时与内容不存在一样处理
+	if err := checkBlocked(ls.fr, like.Uid, owner); err != nil {
+		if errors.Is(err, ErrBlocked) {
+			return notFound
+		}
+		return err
+	}
+	if err := checkVisible(ls.ur, ls.fr, like.Uid, owner); err != nil {
+		if errors.Is(err, ErrPrivateAccount) {
+			return notFound
+		}
+		return err
+	}
+
+	liked := like.Status == model.LikeStatusLiked
+	changed, loaded, err := ls.lc.SetLike(target, targetId, like.Uid, liked)
+	if err == nil && !loaded {
+		if err = ls.loadLikes(like.CommentId, like.VideoId, target, targetId); err == nil {
+			changed, _, err = ls.lc.SetLike(target, targetId, like.Uid, liked)
+		}
 	}
-	err = ls.lr.SetLikeStatus(l.Id, like.Status)
 	if err != nil {
-		log.Printf("failed to set like status: likeId: %s, %v", l.Id, err)
+		log.Printf("failed to set like: target: %s, id: %s, uid: %s, error: %v", target, targetId, like.Uid, err)
+		return err
 	}
-	return err
+
+	if changed && liked {
+		notify(ls.nr, &model.NotificationEvent{
+			Uid:        owner,
+			ActorId:    like.Uid,
+			Type:       model.NotificationLike,
+			TargetType: target,
+			TargetId:   targetId,
+		})
+	}
+	return nil
 }
 
-func (ls *likeService) GetVideoListByLike(uid string, pageNum, pageSize int64) ([]*model.Video, error) {
-	ids, err := ls.lr.GetVideoLikeList(uid, pageNum, pageSize)
+// GetVideoListByLike 按点赞时间倒序返回 uid 点赞的视频，并附带 viewerId 的点赞与关注状态
+func (ls *likeService) GetVideoListByLike(viewerId, uid, cursor string, pageNum, pageSize int64) ([]*model.Video, string, int64, error) {
+	if err := checkBlocked(ls.fr, viewerId, uid); err != nil {
+		if errors.Is(err, ErrBlocked) {
+			return nil, "", 0, nil
+		}
+		return nil, "", 0, err
+	}
+	if err := checkVisible(ls.ur, ls.fr, viewerId, uid); err != nil {
+		return nil, "", 0, err
+	}
+
+	c, err := decodeLikeCursor(cursor)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	limit := clampLimit(pageSize, defaultLikePageSize, maxLikePageSize)
+	offset := 0
+	if c == nil && pageNum > 1 {
+		offset = (int(pageNum) - 1) * limit
+	}
+
+	likes, err := ls.lr.GetVideoLikeList(uid, c, offset, limit)
 	if err != nil {
 		log.Printf("failed to get video ids by user like: uid: %s, %v", uid, err)
+		return nil, "", 0, err
+	}
+	total, err := ls.lr.CountVideoLikes(uid)
+	if err != nil {
+		log.Printf("failed to count user likes: uid: %s, %v", uid, err)
+		return nil, "", 0, err
+	}
+
+	ids := make([]string, len(likes))
+	for i, l := range likes {
+		ids[i] = l.VideoId
+	}
+	videos, err := getVideosInOrder(ls.vr, ids)
+	if err != nil {
+		return nil, "", 0, err
 	}
+	if videos, err = filterVideos(ls.ur, ls.fr, viewerId, false, videos); err != nil {
+		return nil, "", 0, err
+	}
+	if err := markVideoStates(ls.ur, ls.lr, ls.fr, viewerId, videos); err != nil {
+		return nil, "", 0, err
+	}
+
+	next := ""
+	if len(likes) == limit {
+		next = encodeLikeCursor(likes[len(likes)-1])
+	}
+	return videos, next, total, nil
+}
 
-	videos, err := ls.vr.GetVideosByIds(ids)
+// FlushLikes 把 Redis 中累积的点赞变更写回 MySQL，失败时保留变更等待下一轮重试
+func (ls *likeService) FlushLikes() (int, error) {
+	changes, token, err := ls.lc.PullChanges()
 	if err != nil {
-		log.Printf("failed to get video by ids: uid: %v, %v", uid, err)
-		return nil, err
+		log.Printf("failed to pull like changes: %v", err)
+		return 0, err
+	}
+	if token == "" {
+		return 0, nil
+	}
+
+	applied := 0
+	for _, c := range changes {
+		like := &model.Like{
+			Id:     util.GetID(),
+			Uid:    c.Uid,
+			Status: c.Status,
+		}
+		if c.Target == repository.LikeTargetComment {
+			like.CommentId = c.TargetId
+		} else {
+			like.VideoId = c.TargetId
+		}
+
+		changed, err := ls.lr.ApplyLike(like)
+		if err != nil {
+			log.Printf("failed to apply like: target: %s, id: %s, uid: %s, error: %v", c.Target, c.TargetId, c.Uid, err)
+			if err := ls.lc.ReleaseChanges(token); err != nil {
+				log.Printf("failed to release like changes: %v", err)
+			}
+			return applied, err
+		}
+		if changed {
+			applied++
+		}
+	}
+
+	if err := ls.lc.AckChanges(token); err != nil {
+		log.Printf("failed to ack like changes: %v", err)
+		return applied, err
+	}
+	return applied, nil
+}
+
+func (ls *likeService) ReconcileLikes() error {
+	if _, err := ls.FlushLikes(); err != nil {
+		return err
+	}
+	if err := ls.lr.RecountLikes(); err != nil {
+		log.Printf("failed to recount likes: %v", err)
+		return err
+	}
+	return nil
+}
+
+func encodeLikeCursor(l *model.Like) string {
+	raw := strconv.FormatInt(l.UpdatedAt.UnixNano(), 10) + ":" + l.Id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeLikeCursor(cursor string) (*repository.LikeCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &repository.LikeCursor{UpdatedAt: time.Unix(0, nanos), Id: parts[1]}, nil
+}
+
+func (ls *likeService) loadLikes(commentId, videoId, target, targetId string) error {
+	uids, err := ls.lr.GetLikerIds(commentId, videoId)
+	if err != nil {
+		return err
 	}
-	return videos, err
+	return ls.lc.LoadLikes(target, targetId, uids)
 }
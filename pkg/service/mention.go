@@ -0,0 +1,105 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+	"west2/util"
+)
+
+// 单段文本最多解析的不同用户数，超出的 @ 按普通文本处理
+const maxMentions = 20
+
+// resolveMentions 在写入时把 @用户名 解析为提及实体；
+// 用户不存在或被提及者的设置不允许时，该 @ 保持普通文本
+func resolveMentions(ur repository.UserRepository, fr repository.FollowRepostory, authorId, text string) (model.Mentions, error) {
+	tokens := util.ParseMentions(text)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, t := range tokens {
+		name := strings.ToLower(t.Username)
+		if seen[name] || len(names) >= maxMentions {
+			continue
+		}
+		seen[name] = true
+		names = append(names, t.Username)
+	}
+
+	users, err := ur.GetUsersByUsernames(names)
+	if err != nil {
+		log.Printf("failed to get mentioned users: usernames: %v, error: %v", names, err)
+		return nil, err
+	}
+
+	var followingOnly []string
+	for _, u := range users {
+		if u.MentionPolicy == model.MentionFollowing && u.Id != authorId {
+			followingOnly = append(followingOnly, u.Id)
+		}
+	}
+	followers := make(map[string]bool)
+	if len(followingOnly) > 0 {
+		ids, err := fr.GetFollowerIds(authorId, followingOnly)
+		if err != nil {
+			log.Printf("failed to get followers of author: authorId: %s, error: %v", authorId, err)
+			return nil, err
+		}
+		for _, id := range ids {
+			followers[id] = true
+		}
+	}
+
+	allowed := make(map[string]*model.User, len(users))
+	for _, u := range users {
+		switch {
+		case u.Id == authorId:
+		case u.MentionPolicy == model.MentionNobody:
+			continue
+		case u.MentionPolicy == model.MentionFollowing && !followers[u.Id]:
+			continue
+		}
+		allowed[strings.ToLower(u.Username)] = u
+	}
+
+	var mentions model.Mentions
+	for _, t := range tokens {
+		if u, ok := allowed[strings.ToLower(t.Username)]; ok {
+			mentions = append(mentions, &model.Mention{
+				Uid:      u.Id,
+				Username: u.Username,
+				Offset:   t.Offset,
+				Length:   t.Length,
+			})
+		}
+	}
+	return mentions, nil
+}
+
+// notifyMentions 给被提及的用户各发一条通知，notified 中已通知过的用户除外
+func notifyMentions(nr repository.NotificationRepository, authorId, targetType, targetId string, mentions, notified model.Mentions) {
+	skip := make(map[string]bool)
+	for _, m := range notified {
+		skip[m.Uid] = true
+	}
+
+	var events []*model.NotificationEvent
+	for _, m := range mentions {
+		if skip[m.Uid] {
+			continue
+		}
+		skip[m.Uid] = true
+		events = append(events, &model.NotificationEvent{
+			Uid:        m.Uid,
+			ActorId:    authorId,
+			Type:       model.NotificationMention,
+			TargetType: targetType,
+			TargetId:   targetId,
+		})
+	}
+	notify(nr, events...)
+}
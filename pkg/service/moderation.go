@@ -0,0 +1,226 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"west2/pkg/model"
+	"west2/pkg/moderation"
+	"west2/pkg/repository"
+	"west2/util"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultReviewPage = 20
+	maxReviewPage     = 100
+)
+
+type moderationService struct {
+	rr repository.ReviewRepository
+	ur repository.UserRepository
+	cr repository.CommentRepository
+	vr repository.VideoRepository
+	nr repository.NotificationRepository
+	sr repository.SuggestRepository
+	me moderation.Engine
+}
+
+type ModerationService interface {
+	ListReviews(uid string, status int64, cursor string, pageSize int64) ([]*model.ReviewItem, string, error)
+	Approve(uid, id string) error
+	Reject(uid, id string) error
+	ReloadWords(uid string) (int, error)
+}
+
+func NewModerationService(rr repository.ReviewRepository, ur repository.UserRepository, cr repository.CommentRepository, vr repository.VideoRepository, nr repository.NotificationRepository, sr repository.SuggestRepository, me moderation.Engine) ModerationService {
+	return &moderationService{rr: rr, ur: ur, cr: cr, vr: vr, nr: nr, sr: sr, me: me}
+}
+
+func (ms *moderationService) ListReviews(uid string, status int64, cursor string, pageSize int64) ([]*model.ReviewItem, string, error) {
+	if err := checkModerator(ms.ur, uid); err != nil {
+		return nil, "", err
+	}
+	if status != model.ReviewPending && status != model.ReviewApproved && status != model.ReviewRejected {
+		return nil, "", ErrInvalidReviewStatus
+	}
+	c, err := decodeReviewCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	limit := clampLimit(pageSize, defaultReviewPage, maxReviewPage)
+
+	items, err := ms.rr.ListReviewItems(status, c, limit)
+	if err != nil {
+		log.Printf("failed to list review items: status: %d, err: %v", status, err)
+		return nil, "", err
+	}
+
+	next := ""
+	if len(items) == limit {
+		last := items[len(items)-1]
+		raw := strconv.FormatInt(last.CreatedAt.UnixNano(), 10) + ":" + last.Id
+		next = base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	return items, next, nil
+}
+
+// Approve 公开内容，并补发发布时因待审核而暂缓的通知和视频标题联想词
+func (ms *moderationService) Approve(uid, id string) error {
+	item, err := ms.resolve(uid, id, true)
+	if err != nil {
+		return err
+	}
+
+	switch item.TargetType {
+	case model.TargetComment:
+		comment, err := ms.cr.GetCommentById(item.TargetId)
+		if err != nil {
+			log.Printf("failed to get approved comment: id: %s, err: %v", item.TargetId, err)
+			return nil
+		}
+		var parent *model.Comment
+		if comment.ParentId != "" {
+			if parent, err = ms.cr.GetCommentById(comment.ParentId); err != nil {
+				log.Printf("failed to get parent comment: id: %s, err: %v", comment.ParentId, err)
+				return nil
+			}
+		}
+		v, err := ms.vr.GetVideoById(comment.VideoId)
+		if err != nil {
+			log.Printf("failed to get video: id: %s, err: %v", comment.VideoId, err)
+			return nil
+		}
+		notifyComment(ms.nr, comment, parent, v)
+	case model.TargetVideo:
+		v, err := ms.vr.GetVideoById(item.TargetId)
+		if err != nil {
+			log.Printf("failed to get approved video: id: %s, err: %v", item.TargetId, err)
+			return nil
+		}
+		notifyMentions(ms.nr, v.Uid, model.TargetVideo, v.Id, v.Mentions, nil)
+		addSuggestTerm(ms.sr, v.Title)
+	}
+	return nil
+}
+
+func (ms *moderationService) Reject(uid, id string) error {
+	_, err := ms.resolve(uid, id, false)
+	return err
+}
+
+func (ms *moderationService) ReloadWords(uid string) (int, error) {
+	if err := checkModerator(ms.ur, uid); err != nil {
+		return 0, err
+	}
+	n, err := ms.me.Reload()
+	if err != nil {
+		log.Printf("failed to reload sensitive words: err: %v", err)
+		return 0, err
+	}
+	return n, nil
+}
+
+func (ms *moderationService) resolve(uid, id string, approved bool) (*model.ReviewItem, error) {
+	if err := checkModerator(ms.ur, uid); err != nil {
+		return nil, err
+	}
+	item, err := ms.rr.GetReviewItem(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReviewNotFound
+		}
+		log.Printf("failed to get review item: id: %s, err: %v", id, err)
+		return nil, err
+	}
+
+	resolved, err := ms.rr.ResolveReview(item, uid, approved)
+	if err != nil {
+		log.Printf("failed to resolve review item: id: %s, err: %v", id, err)
+		return nil, err
+	}
+	if !resolved {
+		return nil, ErrReviewResolved
+	}
+	return item, nil
+}
+
+// checkModerator 只有 role 为 RoleModerator 的用户可以处理审核与举报
+func checkModerator(ur repository.UserRepository, uid string) error {
+	u, err := ur.GetUserById(uid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPermissionDenied
+		}
+		log.Printf("failed to get user: id: %s, err: %v", uid, err)
+		return err
+	}
+	if u.Role != model.RoleModerator {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// moderate 依次检查 texts：命中 reject 返回 ErrContentRejected，命中 mask 或 review 时原地替换为打码后的文本，
+// 命中 review 时另外返回 true 与命中的词，由调用方把内容置为待审核
+func moderate(me moderation.Engine, texts ...*string) (bool, []string, error) {
+	if me == nil {
+		return false, nil, nil
+	}
+	review := false
+	var words []string
+	for _, t := range texts {
+		res := me.Check(*t)
+		if res.Action == moderation.ActionReject {
+			return false, nil, ErrContentRejected
+		}
+		if res.Action == moderation.ActionReview {
+			review = true
+		}
+		if res.Action >= moderation.ActionMask {
+			*t = res.Text
+		}
+		words = append(words, res.Words...)
+	}
+	return review, words, nil
+}
+
+// submitReview 把待审核的内容放入审核队列
+func submitReview(rr repository.ReviewRepository, targetType, targetId, uid, content string, words []string) error {
+	err := rr.CreateReviewItem(&model.ReviewItem{
+		Id:         util.GetID(),
+		TargetType: targetType,
+		TargetId:   targetId,
+		Uid:        uid,
+		Content:    content,
+		Words:      strings.Join(words, ","),
+		Status:     model.ReviewPending,
+	})
+	if err != nil {
+		log.Printf("failed to create review item: targetType: %s, targetId: %s, err: %v", targetType, targetId, err)
+	}
+	return err
+}
+
+func decodeReviewCursor(cursor string) (*repository.ReviewCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &repository.ReviewCursor{CreatedAt: time.Unix(0, nanos), Id: parts[1]}, nil
+}
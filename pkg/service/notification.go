@@ -0,0 +1,161 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"west2/pkg/hub"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+)
+
+const (
+	defaultNotificationPage = 20
+	maxNotificationPage     = 100
+
+	notifyQueueSize = 4096
+)
+
+type notifyTask struct {
+	nr    repository.NotificationRepository
+	event *model.NotificationEvent
+}
+
+var (
+	notifyOnce  sync.Once
+	notifyQueue chan *notifyTask
+)
+
+type notificationService struct {
+	nr repository.NotificationRepository
+}
+
+type NotificationService interface {
+	List(uid, cursor string, pageSize int64) ([]*model.Notification, string, error)
+	UnreadCount(uid string) (int64, error)
+	MarkRead(uid string, ids []string, all bool) error
+}
+
+func NewNotificationService(nr repository.NotificationRepository) NotificationService {
+	return &notificationService{nr: nr}
+}
+
+func (ns *notificationService) List(uid, cursor string, pageSize int64) ([]*model.Notification, string, error) {
+	c, err := decodeNotificationCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	limit := clampLimit(pageSize, defaultNotificationPage, maxNotificationPage)
+
+	notifications, err := ns.nr.ListNotifications(uid, c, limit)
+	if err != nil {
+		log.Printf("failed to list notifications: uid: %s, err: %v", uid, err)
+		return nil, "", err
+	}
+
+	next := ""
+	if len(notifications) == limit {
+		last := notifications[len(notifications)-1]
+		raw := strconv.FormatInt(last.UpdatedAt.UnixNano(), 10) + ":" + last.Id
+		next = base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	return notifications, next, nil
+}
+
+func (ns *notificationService) UnreadCount(uid string) (int64, error) {
+	count, err := ns.nr.CountUnread(uid)
+	if err != nil {
+		log.Printf("failed to count unread notifications: uid: %s, err: %v", uid, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (ns *notificationService) MarkRead(uid string, ids []string, all bool) error {
+	var err error
+	if all {
+		err = ns.nr.MarkAllRead(uid)
+	} else {
+		err = ns.nr.MarkRead(uid, ids)
+	}
+	if err != nil {
+		log.Printf("failed to mark notifications read: uid: %s, err: %v", uid, err)
+	}
+	return err
+}
+
+// notify 把事件放入队列，由后台协程记录并推送给在线的接收者，不占用请求的处理时间；
+// 自己触发自己的事件会被忽略，队列已满或写入失败只记录日志，不影响触发事件的业务操作
+func notify(nr repository.NotificationRepository, events ...*model.NotificationEvent) {
+	notifyOnce.Do(func() {
+		notifyQueue = make(chan *notifyTask, notifyQueueSize)
+		go runNotifyQueue()
+	})
+	for _, e := range events {
+		if e.Uid == "" || e.Uid == e.ActorId {
+			continue
+		}
+		select {
+		case notifyQueue <- &notifyTask{nr: nr, event: e}:
+		default:
+			log.Printf("failed to queue notification: uid: %s, type: %s, targetId: %s, err: queue is full", e.Uid, e.Type, e.TargetId)
+		}
+	}
+}
+
+func runNotifyQueue() {
+	for t := range notifyQueue {
+		e := t.event
+		n, changed, err := t.nr.AddEvent(e)
+		if err != nil {
+			log.Printf("failed to add notification: uid: %s, type: %s, targetId: %s, err: %v", e.Uid, e.Type, e.TargetId, err)
+			continue
+		}
+		if changed {
+			push(t.nr, n)
+		}
+	}
+}
+
+func push(nr repository.NotificationRepository, n *model.Notification) {
+	unread, err := nr.CountUnread(n.Uid)
+	if err != nil {
+		log.Printf("failed to count unread notifications: uid: %s, err: %v", n.Uid, err)
+		return
+	}
+	msg, err := json.Marshal(&model.WSMessage{
+		Type: model.TypeNotification,
+		Data: &model.NotificationPush{
+			Notification: model.NotificationToResNotification(n),
+			UnreadCount:  unread,
+		},
+	})
+	if err != nil {
+		log.Printf("failed to marshal notification: id: %s, err: %v", n.Id, err)
+		return
+	}
+	hub.GetHub().Send(n.Uid, msg)
+}
+
+func decodeNotificationCursor(cursor string) (*repository.NotificationCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &repository.NotificationCursor{UpdatedAt: time.Unix(0, nanos), Id: parts[1]}, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"west2/pkg/repository"
+
+	"gorm.io/gorm"
+)
+
+// checkVisible 私密账号的视频和列表只对本人和已关注的用户可见，其他人返回 ErrPrivateAccount；
+// 用户不存在时不拦截，由后续查询返回空结果
+func checkVisible(ur repository.UserRepository, fr repository.FollowRepostory, viewerId, ownerId string) error {
+	if viewerId != "" && viewerId == ownerId {
+		return nil
+	}
+	owner, err := ur.GetUserById(ownerId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		log.Printf("failed to get user by id: uid: %s, err: %v", ownerId, err)
+		return err
+	}
+	if !owner.IsPrivate {
+		return nil
+	}
+	if viewerId == "" {
+		return ErrPrivateAccount
+	}
+	following, err := fr.GetFollowingIds(viewerId, []string{ownerId})
+	if err != nil {
+		log.Printf("failed to get following ids: uid: %s, err: %v", viewerId, err)
+		return err
+	}
+	if len(following) == 0 {
+		return ErrPrivateAccount
+	}
+	return nil
+}
+
+// privateUids 返回 uids 中 viewer 看不到的私密账号
+func privateUids(ur repository.UserRepository, fr repository.FollowRepostory, viewerId string, uids []string) (map[string]bool, error) {
+	users, err := getUsersById(ur, uids)
+	if err != nil {
+		return nil, err
+	}
+	hidden := make(map[string]bool)
+	var ids []string
+	for id, u := range users {
+		if u.IsPrivate && id != viewerId {
+			hidden[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || viewerId == "" {
+		return hidden, nil
+	}
+	following, err := fr.GetFollowingIds(viewerId, ids)
+	if err != nil {
+		log.Printf("failed to get following ids: uid: %s, err: %v", viewerId, err)
+		return nil, err
+	}
+	for _, id := range following {
+		delete(hidden, id)
+	}
+	return hidden, nil
+}
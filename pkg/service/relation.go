@@ -0,0 +1,130 @@
+package service
+
+import (
+	"log"
+	"sort"
+	"time"
+	"west2/pkg/model"
+)
+
+const (
+	defaultRelationSuggest = 20
+	maxRelationSuggest     = 50
+	relationCandidates     = 200
+	relationRecentLikes    = 100
+	// 二度关注和共同点赞每个关注的人、每个视频最多展开的记录数
+	relationFanout        = 100
+	relationNetworkWindow = 30 * 24 * time.Hour
+	relationSuggestTTL    = 10 * time.Minute
+
+	// 各推荐来源中每一次计数的权重
+	weightTwoHop         = 3
+	weightNetworkPopular = 2
+	weightSharedLike     = 1
+)
+
+// SuggestUsers 推荐 uid 可能认识的用户：关注的人所关注的用户、点赞过相同视频的用户，
+// 以及关注的人最近点赞过视频的作者，按加权得分排序。完整的排序结果按用户缓存，
+// 每次读取时再去掉已关注、已申请、屏蔽和静音的用户，因此关系变化无需清理缓存
+func (fs *followService) SuggestUsers(uid string, limit int64) ([]*model.User, error) {
+	ids, ok, err := fs.rc.GetSuggestions(uid)
+	if err != nil {
+		log.Printf("failed to get cached relation suggestions: uid: %s, err: %v", uid, err)
+		ok = false
+	}
+	if !ok {
+		if ids, err = fs.rankSuggestions(uid); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	excluded := make(map[string]bool)
+	followed, err := fs.fr.GetFollowedOrRequestedIds(uid, ids)
+	if err != nil {
+		log.Printf("failed to get followed ids: uid: %s, err: %v", uid, err)
+		return nil, err
+	}
+	hidden, err := hiddenUids(fs.fr, uid, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range append(followed, hidden...) {
+		excluded[id] = true
+	}
+
+	n := clampLimit(limit, defaultRelationSuggest, maxRelationSuggest)
+	picked := make([]string, 0, n)
+	for _, id := range ids {
+		if len(picked) == n {
+			break
+		}
+		if !excluded[id] {
+			picked = append(picked, id)
+		}
+	}
+	users, err := fs.ur.GetUsersByIds(picked)
+	if err != nil {
+		log.Printf("failed to get users by ids: ids: %v, err: %v", picked, err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// rankSuggestions 汇总三种来源的候选用户并写入缓存，返回按得分从高到低排列的 id
+func (fs *followService) rankSuggestions(uid string) ([]string, error) {
+	scores := make(map[string]float64)
+
+	twoHop, err := fs.fr.GetTwoHopFollows(uid, relationFanout, relationCandidates)
+	if err != nil {
+		log.Printf("failed to get two-hop follows: uid: %s, err: %v", uid, err)
+		return nil, err
+	}
+	for _, s := range twoHop {
+		scores[s.Uid] += float64(weightTwoHop * s.Count)
+	}
+
+	shared, err := fs.fr.GetSharedLikeUsers(uid, relationRecentLikes, relationFanout, relationCandidates)
+	if err != nil {
+		log.Printf("failed to get shared like users: uid: %s, err: %v", uid, err)
+		return nil, err
+	}
+	for _, s := range shared {
+		scores[s.Uid] += float64(weightSharedLike * s.Count)
+	}
+
+	popular, err := fs.fr.GetNetworkPopularAuthors(uid, time.Now().Add(-relationNetworkWindow), relationCandidates)
+	if err != nil {
+		log.Printf("failed to get network popular authors: uid: %s, err: %v", uid, err)
+		return nil, err
+	}
+	for _, s := range popular {
+		scores[s.Uid] += float64(weightNetworkPopular * s.Count)
+	}
+	delete(scores, uid)
+
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > relationCandidates {
+		ids = ids[:relationCandidates]
+	}
+
+	values := make([]float64, len(ids))
+	for i, id := range ids {
+		values[i] = scores[id]
+	}
+	if err := fs.rc.SetSuggestions(uid, ids, values, relationSuggestTTL); err != nil {
+		log.Printf("failed to cache relation suggestions: uid: %s, err: %v", uid, err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+	"west2/util"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHideThreshold = 10
+	defaultCasePage      = 20
+	maxCasePage          = 100
+)
+
+type reportService struct {
+	rr        repository.ReportRepository
+	ur        repository.UserRepository
+	vr        repository.VideoRepository
+	cr        repository.CommentRepository
+	mr        repository.MessageRepository
+	gr        repository.GroupRepository
+	threshold int64
+}
+
+type ReportService interface {
+	Report(report *model.Report) error
+	Appeal(uid, caseId, reason string) error
+	ListCases(uid string, status int64, cursor string, pageSize int64) ([]*model.ReportCase, string, error)
+	ResolveCase(uid, caseId string, takedown bool, note string) error
+}
+
+// NewReportService threshold 为自动隐藏内容的严重度阈值，传 0 使用默认值
+func NewReportService(rr repository.ReportRepository, ur repository.UserRepository, vr repository.VideoRepository, cr repository.CommentRepository, mr repository.MessageRepository, gr repository.GroupRepository, threshold int64) ReportService {
+	if threshold <= 0 {
+		threshold = defaultHideThreshold
+	}
+	return &reportService{rr: rr, ur: ur, vr: vr, cr: cr, mr: mr, gr: gr, threshold: threshold}
+}
+
+func (rs *reportService) Report(report *model.Report) error {
+	weight, ok := model.ReportReasons[report.Reason]
+	if !ok {
+		return ErrInvalidReason
+	}
+	ownerId, err := rs.getOwner(report.ReporterId, report.TargetType, report.TargetId)
+	if err != nil {
+		return err
+	}
+	if ownerId == report.ReporterId {
+		return ErrReportSelf
+	}
+
+	report.Id = util.GetID()
+	added, err := rs.rr.AddReport(report, ownerId, weight, rs.threshold)
+	if err != nil {
+		log.Printf("failed to add report: targetType: %s, targetId: %s, err: %v", report.TargetType, report.TargetId, err)
+		return err
+	}
+	if !added {
+		return ErrReportExists
+	}
+	return nil
+}
+
+func (rs *reportService) Appeal(uid, caseId, reason string) error {
+	c, err := rs.getCase(caseId)
+	if err != nil {
+		return err
+	}
+	if c.OwnerId != uid {
+		return ErrPermissionDenied
+	}
+
+	ok, err := rs.rr.Appeal(c, reason)
+	if err != nil {
+		log.Printf("failed to appeal case: id: %s, err: %v", caseId, err)
+		return err
+	}
+	if !ok {
+		return ErrAppealNotAllowed
+	}
+	return nil
+}
+
+func (rs *reportService) ListCases(uid string, status int64, cursor string, pageSize int64) ([]*model.ReportCase, string, error) {
+	if err := checkModerator(rs.ur, uid); err != nil {
+		return nil, "", err
+	}
+	if status < model.CaseOpen || status > model.CaseAppealed {
+		return nil, "", ErrInvalidCaseStatus
+	}
+	c, err := decodeCaseCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	limit := clampLimit(pageSize, defaultCasePage, maxCasePage)
+
+	cases, err := rs.rr.ListCases(status, c, limit)
+	if err != nil {
+		log.Printf("failed to list cases: status: %d, err: %v", status, err)
+		return nil, "", err
+	}
+
+	next := ""
+	if len(cases) == limit {
+		last := cases[len(cases)-1]
+		raw := strconv.FormatInt(last.Severity, 10) + ":" + last.Id
+		next = base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	return cases, next, nil
+}
+
+// ResolveCase takedown 为 true 时下架内容，否则驳回举报并恢复被自动隐藏的内容
+func (rs *reportService) ResolveCase(uid, caseId string, takedown bool, note string) error {
+	if err := checkModerator(rs.ur, uid); err != nil {
+		return err
+	}
+	c, err := rs.getCase(caseId)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := rs.rr.ResolveCase(c, uid, takedown, note)
+	if err != nil {
+		log.Printf("failed to resolve case: id: %s, err: %v", caseId, err)
+		return err
+	}
+	if !resolved {
+		return ErrCaseResolved
+	}
+	return nil
+}
+
+func (rs *reportService) getCase(id string) (*model.ReportCase, error) {
+	c, err := rs.rr.GetCase(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCaseNotFound
+		}
+		log.Printf("failed to get case: id: %s, err: %v", id, err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// getOwner 校验被举报的目标存在并返回其作者，只有公开的视频与评论可以被举报，
+// 消息只能由私聊双方或群成员举报
+func (rs *reportService) getOwner(uid, targetType, targetId string) (string, error) {
+	switch targetType {
+	case model.TargetVideo:
+		v, err := rs.vr.GetVideoById(targetId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", ErrVideoNotFound
+			}
+			log.Printf("failed to get video by id: id: %s, err: %v", targetId, err)
+			return "", err
+		}
+		if v.Status != model.ContentNormal {
+			return "", ErrVideoNotFound
+		}
+		return v.Uid, nil
+	case model.TargetComment:
+		c, err := rs.cr.GetCommentById(targetId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", ErrCommentNotFound
+			}
+			log.Printf("failed to get comment by id: id: %s, err: %v", targetId, err)
+			return "", err
+		}
+		if c.Status != model.ContentNormal {
+			return "", ErrCommentNotFound
+		}
+		return c.Uid, nil
+	case model.TargetUser:
+		u, err := rs.ur.GetUserById(targetId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", ErrUserNotFound
+			}
+			log.Printf("failed to get user by id: id: %s, err: %v", targetId, err)
+			return "", err
+		}
+		return u.Id, nil
+	case model.TargetMessage:
+		m, err := rs.mr.GetMessageById(targetId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", ErrMessageNotFound
+			}
+			log.Printf("failed to get message by id: id: %s, err: %v", targetId, err)
+			return "", err
+		}
+		if m.GroupId != "" {
+			if _, err := getGroupMember(rs.gr, m.GroupId, uid); err != nil {
+				if errors.Is(err, ErrNotGroupMember) {
+					return "", ErrMessageNotFound
+				}
+				return "", err
+			}
+		} else if m.SenderId != uid && m.ReceiverId != uid {
+			return "", ErrMessageNotFound
+		}
+		return m.SenderId, nil
+	default:
+		return "", ErrInvalidTarget
+	}
+}
+
+func decodeCaseCursor(cursor string) (*repository.CaseCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrInvalidCursor
+	}
+	severity, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &repository.CaseCursor{Severity: severity, Id: parts[1]}, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"west2/pkg/repository"
+)
+
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 20
+	maxSuggestTermLen   = 100
+)
+
+type suggestService struct {
+	sr repository.SuggestRepository
+}
+
+type SuggestService interface {
+	Suggest(uid, prefix string, limit int64) ([]string, []string, error)
+	ClearHistory(uid string) error
+}
+
+func NewSuggestService(sr repository.SuggestRepository) SuggestService {
+	return &suggestService{sr: sr}
+}
+
+// Suggest returns completions for prefix and, for a signed-in user, their
+// recent searches that start with it.
+func (ss *suggestService) Suggest(uid, prefix string, limit int64) ([]string, []string, error) {
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	prefix = normalizeTerm(prefix)
+	var suggestions []string
+	if prefix != "" {
+		var err error
+		suggestions, err = ss.sr.GetSuggestions(prefix, limit)
+		if err != nil {
+			log.Printf("failed to get search suggestions: prefix: %s, err: %v", prefix, err)
+			return nil, nil, err
+		}
+	}
+
+	var history []string
+	if uid != "" {
+		recent, err := ss.sr.GetHistory(uid)
+		if err != nil {
+			log.Printf("failed to get search history: uid: %s, err: %v", uid, err)
+			return nil, nil, err
+		}
+		for _, q := range recent {
+			if strings.HasPrefix(q, prefix) {
+				history = append(history, q)
+			}
+			if int64(len(history)) == limit {
+				break
+			}
+		}
+	}
+
+	return suggestions, history, nil
+}
+
+func (ss *suggestService) ClearHistory(uid string) error {
+	if err := ss.sr.ClearHistory(uid); err != nil {
+		log.Printf("failed to clear search history: uid: %s, err: %v", uid, err)
+		return err
+	}
+	return nil
+}
+
+// recordSearch feeds a query into the suggestion index and the user's history.
+func recordSearch(sr repository.SuggestRepository, uid, query string) {
+	query = normalizeTerm(query)
+	if query == "" {
+		return
+	}
+	if err := sr.AddTerm(query, 1); err != nil {
+		log.Printf("failed to add search suggestion: query: %s, err: %v", query, err)
+	}
+	if uid == "" {
+		return
+	}
+	if err := sr.AddHistory(uid, query); err != nil {
+		log.Printf("failed to add search history: uid: %s, err: %v", uid, err)
+	}
+}
+
+// addSuggestTerm feeds a video title or username into the suggestion index.
+func addSuggestTerm(sr repository.SuggestRepository, term string) {
+	term = normalizeTerm(term)
+	if term == "" {
+		return
+	}
+	if err := sr.AddTerm(term, 1); err != nil {
+		log.Printf("failed to add search suggestion: term: %s, err: %v", term, err)
+	}
+}
+
+// removeSuggestTerm takes back what addSuggestTerm added once the title or
+// name is deleted, renamed or no longer public.
+func removeSuggestTerm(sr repository.SuggestRepository, term string) {
+	term = normalizeTerm(term)
+	if term == "" {
+		return
+	}
+	if err := sr.RemoveTerm(term, 1); err != nil {
+		log.Printf("failed to remove search suggestion: term: %s, err: %v", term, err)
+	}
+}
+
+func normalizeTerm(s string) string {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	if r := []rune(s); len(r) > maxSuggestTermLen {
+		s = string(r[:maxSuggestTermLen])
+	}
+	return s
+}
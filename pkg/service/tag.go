@@ -0,0 +1,96 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"time"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+)
+
+const (
+	defaultTagPageSize = 20
+	maxTagPageSize     = 50
+	trendingWindow     = 7 * 24 * time.Hour
+)
+
+type tagService struct {
+	tr repository.TagRepository
+	ur repository.UserRepository
+	lr repository.LikeRepository
+	fr repository.FollowRepostory
+}
+
+type TagService interface {
+	GetVideosByTag(viewerId, tag, cursor string, pageSize int64) ([]*model.Video, string, error)
+	GetTrendingTags(limit int64) ([]*model.TagStat, error)
+	SuggestTags(prefix string, limit int64) ([]*model.Tag, error)
+}
+
+func NewTagService(tr repository.TagRepository, ur repository.UserRepository, lr repository.LikeRepository, fr repository.FollowRepostory) TagService {
+	return &tagService{tr: tr, ur: ur, lr: lr, fr: fr}
+}
+
+// GetVideosByTag returns a page of videos and the cursor for the next page,
+// which is empty once the last page has been reached.
+func (ts *tagService) GetVideosByTag(viewerId, tag, cursor string, pageSize int64) ([]*model.Video, string, error) {
+	tag = normalizeTag(tag)
+	if tag == "" {
+		return nil, "", nil
+	}
+	limit := clampLimit(pageSize, defaultTagPageSize, maxTagPageSize)
+
+	videos, err := ts.tr.GetVideosByTag(tag, cursor, limit)
+	if err != nil {
+		log.Printf("failed to get videos by tag: tag: %s, cursor: %s, err: %v", tag, cursor, err)
+		return nil, "", err
+	}
+	var next string
+	if len(videos) == limit {
+		next = videos[len(videos)-1].Id
+	}
+
+	if videos, err = filterVideos(ts.ur, ts.fr, viewerId, false, videos); err != nil {
+		return nil, "", err
+	}
+	if err := markVideoStates(ts.ur, ts.lr, ts.fr, viewerId, videos); err != nil {
+		return nil, "", err
+	}
+	return videos, next, nil
+}
+
+func (ts *tagService) GetTrendingTags(limit int64) ([]*model.TagStat, error) {
+	stats, err := ts.tr.GetTrendingTags(time.Now().Add(-trendingWindow), clampLimit(limit, defaultTagPageSize, maxTagPageSize))
+	if err != nil {
+		log.Printf("failed to get trending tags: err: %v", err)
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (ts *tagService) SuggestTags(prefix string, limit int64) ([]*model.Tag, error) {
+	prefix = normalizeTag(prefix)
+	if prefix == "" {
+		return nil, nil
+	}
+	tags, err := ts.tr.GetTagsByPrefix(prefix, clampLimit(limit, defaultSuggestLimit, maxSuggestLimit))
+	if err != nil {
+		log.Printf("failed to get tags by prefix: prefix: %s, err: %v", prefix, err)
+		return nil, err
+	}
+	return tags, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+func clampLimit(limit int64, def, max int) int {
+	if limit <= 0 {
+		return def
+	}
+	if limit > int64(max) {
+		return max
+	}
+	return int(limit)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"errors"
 	"log"
+	"strings"
 	"west2/pkg/model"
 	"west2/pkg/repository"
 	"west2/util"
@@ -12,6 +13,8 @@ import (
 
 type userService struct {
 	ur repository.UserRepository
+	fr repository.FollowRepostory
+	sr repository.SuggestRepository
 }
 
 type UserService interface {
@@ -19,10 +22,13 @@ type UserService interface {
 	Register(username, password string) (bool, error)
 	GetUserInfoById(id string) (*model.User, error)
 	UploadAvatar(id string, data string) (*model.User, error)
+	UpdateProfile(id, displayName string) (*model.User, error)
+	UpdateSettings(id string, mentionPolicy *int64, isPrivate *bool) (*model.User, error)
+	Search(uid, keywords string, pageNum, pageSize int64) ([]*model.User, map[string]bool, int64, error)
 }
 
-func NewUserService(ur repository.UserRepository) UserService {
-	return &userService{ur: ur}
+func NewUserService(ur repository.UserRepository, fr repository.FollowRepostory, sr repository.SuggestRepository) UserService {
+	return &userService{ur: ur, fr: fr, sr: sr}
 }
 
 func (us *userService) Login(username, password, code string) (*model.User, error) {
@@ -78,6 +84,7 @@ func (us *userService) Register(username, password string) (bool, error) {
 		log.Printf("failed to create a user: user: %+v, error: %v", user, err)
 		return false, err
 	}
+	addSuggestTerm(us.sr, username)
 
 	return true, nil
 }
@@ -112,3 +119,101 @@ func (us *userService) UploadAvatar(id string, data string) (*model.User, error)
 	u.Password = ""
 	return u, nil
 }
+
+func (us *userService) UpdateProfile(id, displayName string) (*model.User, error) {
+	old, err := us.ur.GetUserById(id)
+	if err != nil {
+		log.Printf("failed to get user info by id: id: %s, error: %v", id, err)
+		return nil, err
+	}
+	if err := us.ur.SetDisplayName(id, displayName); err != nil {
+		log.Printf("failed to set user's display name: id: %s, error: %v", id, err)
+		return nil, err
+	}
+	if normalizeTerm(old.DisplayName) != normalizeTerm(displayName) {
+		removeSuggestTerm(us.sr, old.DisplayName)
+		addSuggestTerm(us.sr, displayName)
+	}
+
+	u, err := us.ur.GetUserById(id)
+	if err != nil {
+		log.Printf("failed to get user info by id: id: %s, error: %v", id, err)
+		return nil, err
+	}
+
+	u.Password = ""
+	return u, nil
+}
+
+// UpdateSettings 为 nil 的设置项不修改；私密账号改为公开时会通过所有待处理的关注申请
+func (us *userService) UpdateSettings(id string, mentionPolicy *int64, isPrivate *bool) (*model.User, error) {
+	if mentionPolicy != nil {
+		if *mentionPolicy < model.MentionEveryone || *mentionPolicy > model.MentionNobody {
+			return nil, ErrInvalidMentionPolicy
+		}
+		if err := us.ur.SetMentionPolicy(id, *mentionPolicy); err != nil {
+			log.Printf("failed to set user's mention policy: id: %s, error: %v", id, err)
+			return nil, err
+		}
+	}
+	if isPrivate != nil {
+		if err := us.ur.SetPrivate(id, *isPrivate); err != nil {
+			log.Printf("failed to set user's privacy: id: %s, error: %v", id, err)
+			return nil, err
+		}
+		if !*isPrivate {
+			if err := us.fr.ApproveFollowRequests(id); err != nil {
+				log.Printf("failed to approve follow requests: id: %s, error: %v", id, err)
+				return nil, err
+			}
+		}
+	}
+
+	u, err := us.ur.GetUserById(id)
+	if err != nil {
+		log.Printf("failed to get user info by id: id: %s, error: %v", id, err)
+		return nil, err
+	}
+
+	u.Password = ""
+	return u, nil
+}
+
+// Search 返回匹配的用户、当前用户已关注的用户 id 集合以及总数
+func (us *userService) Search(uid, keywords string, pageNum, pageSize int64) ([]*model.User, map[string]bool, int64, error) {
+	keywords = strings.TrimSpace(keywords)
+	if keywords == "" {
+		return nil, nil, 0, nil
+	}
+
+	hidden, err := hiddenUids(us.fr, uid, false)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	users, total, err := us.ur.SearchUsers(keywords, hidden, pageNum, pageSize)
+	if err != nil {
+		log.Printf("failed to search users: keywords: %s, error: %v", keywords, err)
+		return nil, nil, 0, err
+	}
+
+	following := make(map[string]bool)
+	if uid != "" && len(users) > 0 {
+		ids := make([]string, len(users))
+		for i, u := range users {
+			ids[i] = u.Id
+		}
+		followingIds, err := us.fr.GetFollowingIds(uid, ids)
+		if err != nil {
+			log.Printf("failed to get following ids: uid: %s, error: %v", uid, err)
+			return nil, nil, 0, err
+		}
+		for _, id := range followingIds {
+			following[id] = true
+		}
+	}
+
+	for _, u := range users {
+		u.Password = ""
+	}
+	return users, following, total, nil
+}
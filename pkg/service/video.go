@@ -3,46 +3,114 @@ package service
 import (
 	"errors"
 	"log"
+	"strconv"
+	"time"
 	"west2/pkg/model"
+	"west2/pkg/moderation"
 	"west2/pkg/repository"
+	"west2/pkg/search"
 	"west2/util"
 
 	"gorm.io/gorm"
 )
 
+const (
+	rebuildBatchSize = 500
+	// 同步索引时从同步点往前多取这么长时间的变更，避免漏掉同步时尚未提交的事务
+	indexSyncSkew = time.Minute
+	// 关键词搜索的结果先分批经 MySQL 过滤，过滤后只保留相关度最高的这么多条再排序，避免 id IN 列表过长
+	maxSearchHits = 1000
+)
+
+type SearchParams struct {
+	Uid          string
+	Keywords     string
+	FromDate     string
+	ToDate       string
+	Usernames    []string
+	Tag          string
+	SortBy       string
+	MinLikeCount int64
+	MinDuration  int64
+	MaxDuration  int64
+	PageNum      int64
+	PageSize     int64
+}
+
 type videoService struct {
 	vr repository.VideoRepository
 	ur repository.UserRepository
+	tr repository.TagRepository
+	sr repository.SuggestRepository
+	si search.SearchIndex
+	lr repository.LikeRepository
+	fr repository.FollowRepostory
+	nr repository.NotificationRepository
+	rr repository.ReviewRepository
+	me moderation.Engine
 }
 
 type VideoService interface {
-	GetVideoStream(latestTime string) ([]*model.Video, error)
-	Publish(title, description, data, uid string) error
-	GetVideosByUid(uid string, pageNum, pageSize int64) ([]*model.Video, int64, error)
-	GetVideosByVisitCount(pageNum, pageSize int64) ([]*model.Video, error)
-	Search(keywords, fromDate, toDate, username string, pageNum, pageSize int64) ([]*model.Video, int64, error)
+	GetVideoStream(viewerId, latestTime string) ([]*model.Video, error)
+	Publish(title, description, data, uid string) (int64, error)
+	GetVideosByUid(viewerId, uid string, pageNum, pageSize int64) ([]*model.Video, int64, error)
+	GetVideosByVisitCount(viewerId string, pageNum, pageSize int64) ([]*model.Video, error)
+	Search(params *SearchParams) ([]*model.Video, int64, error)
+	Edit(id, uid string, title, description *string) (int64, error)
+	Delete(id, uid string) error
+	RebuildIndex() (int, error)
+	SyncIndex() (int, error)
+	BackfillSuggestions() (int, error)
+	BackfillDurations() (int, error)
+	BackfillTags() (int, error)
 }
 
-func NewVideoService(vr repository.VideoRepository, ur repository.UserRepository) VideoService {
-	return &videoService{vr: vr, ur: ur}
+func NewVideoService(vr repository.VideoRepository, ur repository.UserRepository, tr repository.TagRepository, sr repository.SuggestRepository, si search.SearchIndex, lr repository.LikeRepository, fr repository.FollowRepostory, nr repository.NotificationRepository, rr repository.ReviewRepository, me moderation.Engine) VideoService {
+	return &videoService{vr: vr, ur: ur, tr: tr, sr: sr, si: si, lr: lr, fr: fr, nr: nr, rr: rr, me: me}
 }
 
-func (vs *videoService) GetVideoStream(latestTime string) ([]*model.Video, error) {
+func (vs *videoService) GetVideoStream(viewerId, latestTime string) ([]*model.Video, error) {
 	videos, err := vs.vr.GetVideosByLatestTime(latestTime)
 	if err != nil {
 		log.Printf("failed to get video steam: latestTime: %s, error: %v", latestTime, err)
 		return nil, err
 	}
+	if videos, err = filterVideos(vs.ur, vs.fr, viewerId, true, videos); err != nil {
+		return nil, err
+	}
 
+	if err := markVideoStates(vs.ur, vs.lr, vs.fr, viewerId, videos); err != nil {
+		return nil, err
+	}
 	return videos, nil
 }
 
-func (vs *videoService) Publish(title, description, data, uid string) error {
+// Publish 返回视频的审核状态，命中需要人工审核的敏感词时为 model.ContentPending，通过审核后才公开
+func (vs *videoService) Publish(title, description, data, uid string) (int64, error) {
+	review, words, err := moderate(vs.me, &title, &description)
+	if err != nil {
+		return 0, err
+	}
+	status := model.ContentNormal
+	if review {
+		status = model.ContentPending
+	}
+
 	id := util.GetID()
 
 	if err := util.Base64ToVideo(data, "./static/video/"+id+".mp4"); err != nil {
 		log.Printf("failed to save video file: id: %s, error: %v", id, err)
-		return err
+		return 0, err
+	}
+
+	duration, err := util.Mp4Duration("./static/video/" + id + ".mp4")
+	if err != nil {
+		log.Printf("failed to read video duration: id: %s, error: %v", id, err)
+	}
+
+	mentions, err := resolveMentions(vs.ur, vs.fr, uid, description)
+	if err != nil {
+		return 0, err
 	}
 
 	if err := vs.vr.CreateVideo(&model.Video{
@@ -51,57 +119,525 @@ func (vs *videoService) Publish(title, description, data, uid string) error {
 		Title:       title,
 		Description: description,
 		VideoUrl:    "/static/video/" + id + ".mp4",
-	}); err != nil {
+		Duration:    duration,
+		Mentions:    mentions,
+		Status:      status,
+	}, util.ParseHashtags(title, description)); err != nil {
 		log.Printf("failed to create video: error: %v", err)
+		return 0, err
+	}
+	if review {
+		if err := submitReview(vs.rr, model.TargetVideo, id, uid, title+"\n"+description, words); err != nil {
+			return 0, err
+		}
+	} else {
+		notifyMentions(vs.nr, uid, model.TargetVideo, id, mentions, nil)
+	}
+
+	if err := vs.si.Index(&search.Document{Id: id, Title: title, Description: description}); err != nil {
+		log.Printf("failed to index video: id: %s, error: %v", id, err)
+		return 0, err
+	}
+	// 待审核的标题通过审核后才加入联想词
+	if status == model.ContentNormal {
+		addSuggestTerm(vs.sr, title)
+	}
+
+	return status, nil
+}
+
+// Edit 与 Publish 一样返回审核状态，修改后的内容需要人工审核时视频会重新进入待审核状态；
+// title、description 为 nil 时保留原来的内容
+func (vs *videoService) Edit(id, uid string, titlePtr, descriptionPtr *string) (int64, error) {
+	v, err := vs.getOwnVideo(id, uid)
+	if err != nil {
+		return 0, err
+	}
+	title, description := v.Title, v.Description
+	if titlePtr != nil {
+		title = *titlePtr
+	}
+	if descriptionPtr != nil {
+		description = *descriptionPtr
+	}
+
+	review, words, err := moderate(vs.me, &title, &description)
+	if err != nil {
+		return 0, err
+	}
+
+	mentions, err := resolveMentions(vs.ur, vs.fr, uid, description)
+	if err != nil {
+		return 0, err
+	}
+
+	// 被驳回或下架的视频修改后仍保持原状态，不能借修改重新进入审核；
+	// 待审核的视频修改后用新内容重新提交，替代之前的审核记录
+	status := v.Status
+	resubmit := status == model.ContentPending || (review && status == model.ContentNormal)
+	if resubmit {
+		status = model.ContentPending
+	}
+	if err := vs.vr.UpdateVideo(v.Id, title, description, mentions, status, util.ParseHashtags(title, description)); err != nil {
+		log.Printf("failed to update video: id: %s, error: %v", id, err)
+		return 0, err
+	}
+	if resubmit {
+		if err := submitReview(vs.rr, model.TargetVideo, id, uid, title+"\n"+description, words); err != nil {
+			return 0, err
+		}
+	} else if status == model.ContentNormal {
+		notifyMentions(vs.nr, uid, model.TargetVideo, id, mentions, v.Mentions)
+	}
+
+	if err := vs.si.Index(&search.Document{Id: id, Title: title, Description: description}); err != nil {
+		log.Printf("failed to index video: id: %s, error: %v", id, err)
+		return 0, err
+	}
+	// 联想词只包含公开视频的标题，标题未变时不重复计入
+	wasPublic, isPublic := v.Status == model.ContentNormal, status == model.ContentNormal
+	titleChanged := normalizeTerm(v.Title) != normalizeTerm(title)
+	if wasPublic && (!isPublic || titleChanged) {
+		removeSuggestTerm(vs.sr, v.Title)
+	}
+	if isPublic && (!wasPublic || titleChanged) {
+		addSuggestTerm(vs.sr, title)
+	}
+
+	return status, nil
+}
+
+func (vs *videoService) Delete(id, uid string) error {
+	v, err := vs.getOwnVideo(id, uid)
+	if err != nil {
+		return err
+	}
+
+	if err := vs.vr.DeleteVideo(v.Id); err != nil {
+		log.Printf("failed to delete video: id: %s, error: %v", id, err)
 		return err
 	}
 
+	if err := vs.si.Delete(id); err != nil {
+		log.Printf("failed to remove video from index: id: %s, error: %v", id, err)
+		return err
+	}
+	if v.Status == model.ContentNormal {
+		removeSuggestTerm(vs.sr, v.Title)
+	}
+
 	return nil
 }
 
-func (vs *videoService) GetVideosByUid(uid string, pageNum, pageSize int64) ([]*model.Video, int64, error) {
+func (vs *videoService) getOwnVideo(id, uid string) (*model.Video, error) {
+	v, err := vs.vr.GetVideoById(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVideoNotFound
+		}
+		log.Printf("failed to get video by id: id: %s, error: %v", id, err)
+		return nil, err
+	}
+	if v.Uid != uid {
+		return nil, ErrPermissionDenied
+	}
+	return v, nil
+}
+
+func (vs *videoService) RebuildIndex() (int, error) {
+	start := time.Now()
+	var docs []*search.Document
+	lastId := ""
+	for {
+		videos, err := vs.vr.GetVideosAfterId(lastId, rebuildBatchSize)
+		if err != nil {
+			log.Printf("failed to get videos for index rebuild: lastId: %s, error: %v", lastId, err)
+			return 0, err
+		}
+		for _, v := range videos {
+			docs = append(docs, &search.Document{Id: v.Id, Title: v.Title, Description: v.Description})
+		}
+		if len(videos) < rebuildBatchSize {
+			break
+		}
+		lastId = videos[len(videos)-1].Id
+	}
+
+	if err := vs.si.Reset(docs); err != nil {
+		log.Printf("failed to reset search index: error: %v", err)
+		return 0, err
+	}
+	vs.si.SetMark(start)
+	return len(docs), nil
+}
+
+// SyncIndex 把同步点之后 MySQL 中修改或删除的视频重放到索引，返回重放的视频数；
+// 启动时补上快照之后的修改，多实例部署时定期调用以同步其它实例的修改
+func (vs *videoService) SyncIndex() (int, error) {
+	start := time.Now()
+	since := vs.si.Mark()
+	if !since.IsZero() {
+		since = since.Add(-indexSyncSkew)
+	}
+
+	n, lastId := 0, ""
+	for {
+		videos, err := vs.vr.GetVideosUpdatedAfter(since, lastId, rebuildBatchSize)
+		if err != nil {
+			log.Printf("failed to get updated videos for index sync: since: %v, lastId: %s, error: %v", since, lastId, err)
+			return n, err
+		}
+		for _, v := range videos {
+			if v.DeletedAt.IsZero() {
+				err = vs.si.Index(&search.Document{Id: v.Id, Title: v.Title, Description: v.Description})
+			} else {
+				err = vs.si.Delete(v.Id)
+			}
+			if err != nil {
+				log.Printf("failed to sync video to index: id: %s, error: %v", v.Id, err)
+				return n, err
+			}
+		}
+		n += len(videos)
+		if len(videos) < rebuildBatchSize {
+			break
+		}
+		lastId = videos[len(videos)-1].Id
+	}
+
+	vs.si.SetMark(start)
+	return n, nil
+}
+
+// BackfillSuggestions 把联想词上线前已有的公开视频标题、用户名和昵称写入联想词，只在第一次调用时执行，
+// 返回写入的词数；失败时清除标记，下一次调用重新回填
+func (vs *videoService) BackfillSuggestions() (int, error) {
+	claimed, err := vs.sr.ClaimBackfill()
+	if err != nil {
+		log.Printf("failed to claim suggestion backfill: error: %v", err)
+		return 0, err
+	}
+	if !claimed {
+		return 0, nil
+	}
+	n, err := vs.backfillSuggestions()
+	if err != nil {
+		if err := vs.sr.ResetBackfill(); err != nil {
+			log.Printf("failed to reset suggestion backfill: error: %v", err)
+		}
+		return 0, err
+	}
+	return n, nil
+}
+
+// BackfillTags 标签上线前发布的视频没有标签，没有任何视频有标签时从标题和简介中解析话题并写入，返回处理的视频数
+func (vs *videoService) BackfillTags() (int, error) {
+	tagged, err := vs.tr.HasVideoTags()
+	if err != nil {
+		log.Printf("failed to check video tags: error: %v", err)
+		return 0, err
+	}
+	if tagged {
+		return 0, nil
+	}
+
+	n := 0
+	lastId := ""
+	for {
+		videos, err := vs.vr.GetVideosAfterId(lastId, rebuildBatchSize)
+		if err != nil {
+			log.Printf("failed to get videos for tag backfill: lastId: %s, error: %v", lastId, err)
+			return 0, err
+		}
+		for _, v := range videos {
+			tags := util.ParseHashtags(v.Title, v.Description)
+			if len(tags) == 0 {
+				continue
+			}
+			if err := vs.tr.SetVideoTags(v.Id, tags); err != nil {
+				log.Printf("failed to set video tags: id: %s, error: %v", v.Id, err)
+				return 0, err
+			}
+			n++
+		}
+		if len(videos) < rebuildBatchSize {
+			break
+		}
+		lastId = videos[len(videos)-1].Id
+	}
+	return n, nil
+}
+
+// BackfillDurations 从视频文件中读出时长为 0 的视频的时长，返回更新的视频数；
+// 文件缺失或无法解析的视频保持为 0，下一次调用时重试
+func (vs *videoService) BackfillDurations() (int, error) {
+	n := 0
+	lastId := ""
+	for {
+		videos, err := vs.vr.GetVideosWithoutDuration(lastId, rebuildBatchSize)
+		if err != nil {
+			log.Printf("failed to get videos for duration backfill: lastId: %s, error: %v", lastId, err)
+			return 0, err
+		}
+		for _, v := range videos {
+			duration, err := util.Mp4Duration("." + v.VideoUrl)
+			if err != nil || duration <= 0 {
+				log.Printf("failed to read video duration: id: %s, error: %v", v.Id, err)
+				continue
+			}
+			if err := vs.vr.SetDuration(v.Id, duration); err != nil {
+				log.Printf("failed to set video duration: id: %s, error: %v", v.Id, err)
+				return 0, err
+			}
+			n++
+		}
+		if len(videos) < rebuildBatchSize {
+			break
+		}
+		lastId = videos[len(videos)-1].Id
+	}
+	return n, nil
+}
+
+func (vs *videoService) backfillSuggestions() (int, error) {
+	n := 0
+	lastId := ""
+	for {
+		videos, err := vs.vr.GetVideosAfterId(lastId, rebuildBatchSize)
+		if err != nil {
+			log.Printf("failed to get videos for suggestion backfill: lastId: %s, error: %v", lastId, err)
+			return 0, err
+		}
+		for _, v := range videos {
+			if v.Status == model.ContentNormal {
+				addSuggestTerm(vs.sr, v.Title)
+				n++
+			}
+		}
+		if len(videos) < rebuildBatchSize {
+			break
+		}
+		lastId = videos[len(videos)-1].Id
+	}
+
+	lastId = ""
+	for {
+		users, err := vs.ur.GetUsersAfterId(lastId, rebuildBatchSize)
+		if err != nil {
+			log.Printf("failed to get users for suggestion backfill: lastId: %s, error: %v", lastId, err)
+			return 0, err
+		}
+		for _, u := range users {
+			addSuggestTerm(vs.sr, u.Username)
+			n++
+			if u.DisplayName != "" {
+				addSuggestTerm(vs.sr, u.DisplayName)
+				n++
+			}
+		}
+		if len(users) < rebuildBatchSize {
+			break
+		}
+		lastId = users[len(users)-1].Id
+	}
+	return n, nil
+}
+
+func (vs *videoService) GetVideosByUid(viewerId, uid string, pageNum, pageSize int64) ([]*model.Video, int64, error) {
+	// 与作者之间有屏蔽时当作没有视频
+	if err := checkBlocked(vs.fr, viewerId, uid); err != nil {
+		if errors.Is(err, ErrBlocked) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	if err := checkVisible(vs.ur, vs.fr, viewerId, uid); err != nil {
+		return nil, 0, err
+	}
+
 	videos, total, err := vs.vr.GetVideosByUid(uid, pageNum, pageSize)
 	if err != nil {
 		log.Printf("failed to get videos by uid: %s, error: %v", uid, err)
 		return nil, 0, err
 	}
 
+	if err := markVideoStates(vs.ur, vs.lr, vs.fr, viewerId, videos); err != nil {
+		return nil, 0, err
+	}
 	return videos, total, nil
 }
 
-func (vs *videoService) GetVideosByVisitCount(pageNum, pageSize int64) ([]*model.Video, error) {
+func (vs *videoService) GetVideosByVisitCount(viewerId string, pageNum, pageSize int64) ([]*model.Video, error) {
 	videos, err := vs.vr.GetVideosGroupByVisitCount(pageNum, pageSize)
 	if err != nil {
 		log.Printf("failed to get videos by visit count: error: %v", err)
 		return nil, err
 	}
+	if videos, err = filterVideos(vs.ur, vs.fr, viewerId, true, videos); err != nil {
+		return nil, err
+	}
 
+	if err := markVideoStates(vs.ur, vs.lr, vs.fr, viewerId, videos); err != nil {
+		return nil, err
+	}
 	return videos, nil
 }
 
-func (vs *videoService) Search(keywords, fromDate, toDate, username string, pageNum, pageSize int64) ([]*model.Video, int64, error) {
-	var u *model.User
-	var err error
-	if username != "" {
-		u, err = vs.ur.GetUserByUsername(username)
+func (vs *videoService) Search(params *SearchParams) ([]*model.Video, int64, error) {
+	videos, total, err := vs.search(params)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := markVideoStates(vs.ur, vs.lr, vs.fr, params.Uid, videos); err != nil {
+		return nil, 0, err
+	}
+	return videos, total, nil
+}
+
+func (vs *videoService) search(params *SearchParams) ([]*model.Video, int64, error) {
+	filter, err := vs.buildFilter(params)
+	if err != nil {
+		return nil, 0, err
+	}
+	if filter == nil {
+		return nil, 0, nil
+	}
+
+	sortBy := params.SortBy
+	switch sortBy {
+	case "":
+		sortBy = repository.SortByRelevance
+	case repository.SortByRelevance, repository.SortByNewest, repository.SortByMostLiked, repository.SortByMostViewed:
+	default:
+		return nil, 0, ErrInvalidSort
+	}
+
+	if params.PageNum <= 1 {
+		recordSearch(vs.sr, params.Uid, params.Keywords)
+	}
+
+	if params.Keywords == "" {
+		videos, total, err := vs.vr.SearchVideos(filter, sortBy, params.PageNum, params.PageSize)
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return nil, 0, nil
-			}
-			log.Printf("failed to get user by username: %v", err)
+			log.Printf("failed to search videos: error: %v", err)
 			return nil, 0, err
 		}
+		return videos, total, nil
+	}
+
+	hits, err := vs.si.Search(params.Keywords)
+	if err != nil {
+		log.Printf("failed to search index: keywords: %s, error: %v", params.Keywords, err)
+		return nil, 0, err
+	}
+	filter.Ids = make([]string, len(hits))
+	for i, h := range hits {
+		filter.Ids[i] = h.Id
+	}
+
+	matched, err := vs.vr.FilterVideoIds(filter)
+	if err != nil {
+		log.Printf("failed to filter searched videos: error: %v", err)
+		return nil, 0, err
+	}
+	keep := make(map[string]bool, len(matched))
+	for _, id := range matched {
+		keep[id] = true
+	}
+	ranked := make([]string, 0, len(matched))
+	for _, id := range filter.Ids {
+		if keep[id] {
+			ranked = append(ranked, id)
+		}
+	}
+	if len(ranked) > maxSearchHits {
+		ranked = ranked[:maxSearchHits]
 	}
 
-	var uid string
-	if u != nil {
-		uid = u.Id
+	if sortBy != repository.SortByRelevance {
+		filter.Ids = ranked
+		videos, total, err := vs.vr.SearchVideos(filter, sortBy, params.PageNum, params.PageSize)
+		if err != nil {
+			log.Printf("failed to search videos: error: %v", err)
+			return nil, 0, err
+		}
+		return videos, total, nil
 	}
-	videos, total, err := vs.vr.GetVideosByKeywords(keywords, fromDate, toDate, uid, pageNum, pageSize)
+
+	total := int64(len(ranked))
+	page := paginate(ranked, params.PageNum, params.PageSize)
+	videos, err := getVideosInOrder(vs.vr, page)
 	if err != nil {
-		log.Printf("failed to search videos: error: %v", err)
 		return nil, 0, err
 	}
 
 	return videos, total, nil
 }
+
+// buildFilter returns a nil filter when the usernames match nobody.
+func (vs *videoService) buildFilter(params *SearchParams) (*repository.VideoFilter, error) {
+	filter := &repository.VideoFilter{
+		Tag:          normalizeTag(params.Tag),
+		MinLikeCount: params.MinLikeCount,
+		MinDuration:  params.MinDuration,
+		MaxDuration:  params.MaxDuration,
+	}
+
+	var err error
+	if filter.FromDate, err = parseTimestamp(params.FromDate); err != nil {
+		return nil, err
+	}
+	if filter.ToDate, err = parseTimestamp(params.ToDate); err != nil {
+		return nil, err
+	}
+
+	if len(params.Usernames) > 0 {
+		users, err := vs.ur.GetUsersByUsernames(params.Usernames)
+		if err != nil {
+			log.Printf("failed to get users by usernames: %v", err)
+			return nil, err
+		}
+		if len(users) == 0 {
+			return nil, nil
+		}
+		for _, u := range users {
+			filter.Uids = append(filter.Uids, u.Id)
+		}
+	}
+
+	// 搜索结果中不出现与当前用户互相屏蔽的作者，以及当前用户看不到的私密账号
+	if filter.ExcludeUids, err = hiddenUids(vs.fr, params.Uid, false); err != nil {
+		return nil, err
+	}
+	filter.HidePrivate = true
+	filter.ViewerId = params.Uid
+
+	return filter, nil
+}
+
+// parseTimestamp 解析与 feed 相同格式的时间戳（Unix 秒），空字符串表示不限制
+func parseTimestamp(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidTimestamp
+	}
+	return time.Unix(t, 0), nil
+}
+
+func paginate(ids []string, pageNum, pageSize int64) []string {
+	if pageNum < 1 || pageSize < 1 {
+		return nil
+	}
+	first := (pageNum - 1) * pageSize
+	if first >= int64(len(ids)) {
+		return nil
+	}
+	end := first + pageSize
+	if end > int64(len(ids)) {
+		end = int64(len(ids))
+	}
+	return ids[first:end]
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"log"
+	"west2/pkg/model"
+	"west2/pkg/repository"
+)
+
+// markVideoStates 为一页视频填充作者信息以及当前用户的点赞和关注作者状态，各用一次批量查询
+func markVideoStates(ur repository.UserRepository, lr repository.LikeRepository, fr repository.FollowRepostory, uid string, videos []*model.Video) error {
+	if err := fillVideoAuthors(ur, videos); err != nil {
+		return err
+	}
+	if uid == "" || len(videos) == 0 {
+		return nil
+	}
+
+	videoIds := make([]string, 0, len(videos))
+	authorIds := make([]string, 0, len(videos))
+	seen := make(map[string]bool, len(videos))
+	for _, v := range videos {
+		videoIds = append(videoIds, v.Id)
+		if !seen[v.Uid] {
+			seen[v.Uid] = true
+			authorIds = append(authorIds, v.Uid)
+		}
+	}
+
+	liked, err := lr.GetLikedVideoIds(uid, videoIds)
+	if err != nil {
+		log.Printf("failed to get liked videos: uid: %s, error: %v", uid, err)
+		return err
+	}
+	followingIds, err := fr.GetFollowingIds(uid, authorIds)
+	if err != nil {
+		log.Printf("failed to get following authors: uid: %s, error: %v", uid, err)
+		return err
+	}
+	following := make(map[string]bool, len(followingIds))
+	for _, id := range followingIds {
+		following[id] = true
+	}
+
+	for _, v := range videos {
+		v.Liked = liked[v.Id]
+		v.FollowingAuthor = following[v.Uid]
+	}
+	return nil
+}
+
+// getVideosInOrder 按 ids 的顺序返回视频，查不到的 id 会被跳过
+func getVideosInOrder(vr repository.VideoRepository, ids []string) ([]*model.Video, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	videos, err := vr.GetVideosByIds(ids)
+	if err != nil {
+		log.Printf("failed to get videos by ids: error: %v", err)
+		return nil, err
+	}
+	byId := make(map[string]*model.Video, len(videos))
+	for _, v := range videos {
+		byId[v.Id] = v
+	}
+	ordered := make([]*model.Video, 0, len(ids))
+	for _, id := range ids {
+		if v, ok := byId[id]; ok {
+			ordered = append(ordered, v)
+		}
+	}
+	return ordered, nil
+}
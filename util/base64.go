@@ -9,18 +9,31 @@ import (
 )
 
 func SaveBase64Image(base64Data, savePath string) error {
+	imageData, err := DecodeBase64Data(base64Data)
+	if err != nil {
+		return err
+	}
+	return SaveFile(imageData, savePath)
+}
+
+// DecodeBase64Data 解码可能带有 Data URL 前缀的 Base64 数据
+func DecodeBase64Data(base64Data string) ([]byte, error) {
 	// 清理Base64数据（移除Data URL前缀）
 	cleanData := cleanBase64Data(base64Data)
 	if cleanData == "" {
-		return fmt.Errorf("无效的Base64数据")
+		return nil, fmt.Errorf("无效的Base64数据")
 	}
 
 	// 解码Base64
-	imageData, err := base64.StdEncoding.DecodeString(cleanData)
+	data, err := base64.StdEncoding.DecodeString(cleanData)
 	if err != nil {
-		return fmt.Errorf("Base64解码失败: %v", err)
+		return nil, fmt.Errorf("Base64解码失败: %v", err)
 	}
+	return data, nil
+}
 
+// SaveFile 写入文件，目录不存在时先创建
+func SaveFile(data []byte, savePath string) error {
 	// 确保目录存在
 	dir := filepath.Dir(savePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -28,7 +41,7 @@ func SaveBase64Image(base64Data, savePath string) error {
 	}
 
 	// 写入文件
-	if err := os.WriteFile(savePath, imageData, 0644); err != nil {
+	if err := os.WriteFile(savePath, data, 0644); err != nil {
 		return fmt.Errorf("写入文件失败: %v", err)
 	}
 
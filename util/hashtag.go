@@ -0,0 +1,30 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+const maxHashtagLen = 50
+
+var hashtagPattern = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+
+// ParseHashtags 从文本中提取 #话题，统一转为小写并去重，保持首次出现的顺序
+func ParseHashtags(texts ...string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, m := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+			tag := strings.ToLower(m[1])
+			if r := []rune(tag); len(r) > maxHashtagLen {
+				tag = string(r[:maxHashtagLen])
+			}
+			if seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,30 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHashtags(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  []string
+	}{
+		{"no texts", nil, nil},
+		{"no hashtag", []string{"hello world"}, nil},
+		{"lowercased and deduplicated", []string{"#Go is #go and #GO"}, []string{"go"}},
+		{"keeps first appearance order", []string{"#b #a", "#c #a"}, []string{"b", "a", "c"}},
+		{"cjk and underscore", []string{"今天的#话题 #测试_1。"}, []string{"话题", "测试_1"}},
+		{"lone hash", []string{"# not a tag"}, nil},
+		{"long tag is truncated", []string{"#" + strings.Repeat("长", 60)}, []string{strings.Repeat("长", maxHashtagLen)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseHashtags(tt.texts...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHashtags(%q) = %q, want %q", tt.texts, got, tt.want)
+			}
+		})
+	}
+}
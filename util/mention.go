@@ -0,0 +1,35 @@
+package util
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+var mentionPattern = regexp.MustCompile(`@([\p{L}\p{N}_]+)`)
+
+type MentionToken struct {
+	Username string
+	// Offset 与 Length 以字符（rune）计，覆盖包括 @ 在内的整段文本
+	Offset int64
+	Length int64
+}
+
+// ParseMentions 提取文本中的 @用户名，紧跟在字母或数字后面的 @（如邮箱地址）不算提及
+func ParseMentions(text string) []*MentionToken {
+	var tokens []*MentionToken
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+			if unicode.IsLetter(prev) || unicode.IsNumber(prev) || prev == '_' {
+				continue
+			}
+		}
+		tokens = append(tokens, &MentionToken{
+			Username: text[loc[2]:loc[3]],
+			Offset:   int64(utf8.RuneCountInString(text[:loc[0]])),
+			Length:   int64(utf8.RuneCountInString(text[loc[0]:loc[1]])),
+		})
+	}
+	return tokens
+}
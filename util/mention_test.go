@@ -0,0 +1,35 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []*MentionToken
+	}{
+		{"empty", "", nil},
+		{"no mention", "hello world", nil},
+		{"at start", "@alice hi", []*MentionToken{{Username: "alice", Offset: 0, Length: 6}}},
+		{"several mentions", "hi @bob and @carol_1!", []*MentionToken{
+			{Username: "bob", Offset: 3, Length: 4},
+			{Username: "carol_1", Offset: 12, Length: 8},
+		}},
+		{"offsets count runes", "你好 @小明，吃了吗", []*MentionToken{{Username: "小明", Offset: 3, Length: 3}}},
+		{"after cjk letter is not a mention", "你好@小明", nil},
+		{"email address is not a mention", "mail me at a@b.com", nil},
+		{"after underscore is not a mention", "x_@bob", nil},
+		{"double at", "@@bob", []*MentionToken{{Username: "bob", Offset: 1, Length: 4}}},
+		{"lone at", "@ nobody", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
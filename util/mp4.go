@@ -0,0 +1,78 @@
+package util
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Mp4Duration 读取 mp4 文件的 moov/mvhd box，返回视频时长（秒）
+func Mp4Duration(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	moov, moovSize, err := findBox(f, 0, info.Size(), "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, _, err := findBox(f, moov, moov+moovSize, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 32)
+	if _, err := f.ReadAt(header, mvhd); err != nil && err != io.EOF {
+		return 0, err
+	}
+	var timescale, duration uint64
+	if header[0] == 1 {
+		timescale = uint64(binary.BigEndian.Uint32(header[20:24]))
+		duration = binary.BigEndian.Uint64(header[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(header[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(header[16:20]))
+	}
+	if timescale == 0 {
+		return 0, fmt.Errorf("mp4 文件时间刻度为 0")
+	}
+	return int64(duration / timescale), nil
+}
+
+// findBox 在 [start, end) 中查找指定类型的 box，返回其内容的偏移和长度
+func findBox(r io.ReaderAt, start, end int64, boxType string) (int64, int64, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return 0, 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, 0, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return 0, 0, fmt.Errorf("mp4 box 长度无效: %d", size)
+		}
+		if string(header[4:8]) == boxType {
+			return offset + headerSize, size - headerSize, nil
+		}
+		offset += size
+	}
+	return 0, 0, fmt.Errorf("未找到 mp4 box: %s", boxType)
+}